@@ -27,8 +27,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/text/language"
-	"golang.org/x/text/message"
-	"golang.org/x/text/message/catalog"
 	kingpin "gopkg.in/alecthomas/kingpin.v2"
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
@@ -65,6 +63,7 @@ func main() {
 	config := struct {
 		alertmanager     *url.URL
 		boltPath         string
+		configFile       string
 		consul           *url.URL
 		listenAddr       string
 		logLevel         string
@@ -76,6 +75,15 @@ func main() {
 		telegramVerbose  bool
 		templatesPaths   []string
 		translationsPath string
+		fallbacks        []string
+		historyRetention time.Duration
+		flapWindow       time.Duration
+		flapThreshold    int
+		silenceWarning   time.Duration
+		probeInterval    time.Duration
+		prometheus       *url.URL
+		heartbeat        string
+		heartbeatTimeout time.Duration
 	}{}
 
 	a := kingpin.New("alertmanager-bot", "Bot for Prometheus' Alertmanager")
@@ -86,16 +94,49 @@ func main() {
 		Default("http://localhost:9093/").
 		URLVar(&config.alertmanager)
 
+	a.Flag("alertmanager.probe", "How often the status of Alertmanager is probed to alarm the admins when it is unreachable, 0 disables it").
+		Envar("ALERTMANAGER_PROBE").
+		Default("1m").
+		DurationVar(&config.probeInterval)
+
 	a.Flag("bolt.path", "The path to the file where bolt persists its data").
 		Envar("BOLT_PATH").
 		Default("/tmp/bot.db").
 		StringVar(&config.boltPath)
 
+	a.Flag("config.file", "The path to the (optional) YAML config of escalation policies").
+		Envar("CONFIG_FILE").
+		StringVar(&config.configFile)
+
 	a.Flag("consul.url", "The URL that's used to connect to the consul store").
 		Envar("CONSUL_URL").
 		Default("localhost:8500").
 		URLVar(&config.consul)
 
+	a.Flag("flapping.window", "The sliding window the status changes of an alert are counted in to detect flapping").
+		Envar("FLAPPING_WINDOW").
+		Default("1h").
+		DurationVar(&config.flapWindow)
+
+	a.Flag("flapping.threshold", "How many status changes within the window make an alert flapping, 0 disables it").
+		Envar("FLAPPING_THRESHOLD").
+		Default("6").
+		IntVar(&config.flapThreshold)
+
+	a.Flag("heartbeat.matchers", "The matchers of the heartbeat alert, e.g. alertname=\"Watchdog\", empty disables the heartbeat check").
+		Envar("HEARTBEAT_MATCHERS").
+		StringVar(&config.heartbeat)
+
+	a.Flag("heartbeat.timeout", "How long the heartbeat alert may not be received before the admins are alarmed").
+		Envar("HEARTBEAT_TIMEOUT").
+		Default("10m").
+		DurationVar(&config.heartbeatTimeout)
+
+	a.Flag("history.retention", "How long resolved alerts are kept in the history for reports, /history and /top and notes about alerts, 0 keeps them forever").
+		Envar("HISTORY_RETENTION").
+		Default("2160h").
+		DurationVar(&config.historyRetention)
+
 	a.Flag("listen.addr", "The address the alertmanager-bot listens on for incoming webhooks").
 		Envar("LISTEN_ADDR").
 		Default("0.0.0.0:8080").
@@ -110,6 +151,15 @@ func main() {
 		Default(levelInfo).
 		EnumVar(&config.logLevel, levelError, levelWarn, levelInfo, levelDebug)
 
+	a.Flag("prometheus.url", "The URL of the Prometheus the alerts are graphed from, empty disables graphs").
+		Envar("PROMETHEUS_URL").
+		URLVar(&config.prometheus)
+
+	a.Flag("silence.warning", "How long before a silence expires the chat it was created from is warned, 0 disables it").
+		Envar("SILENCE_WARNING").
+		Default("15m").
+		DurationVar(&config.silenceWarning)
+
 	a.Flag("store", "The store to use").
 		Required().
 		Envar("STORE").
@@ -145,6 +195,11 @@ func main() {
 		Default("/dicts").
 		StringVar(&config.translationsPath)
 
+	a.Flag("translations.fallback", "The chain of languages to try in order when a translation is missing").
+		Envar("TRANSLATIONS_FALLBACK").
+		Default("en").
+		StringsVar(&config.fallbacks)
+
 	_, err := a.Parse(os.Args[1:])
 	if err != nil {
 		fmt.Printf("error parsing commandline arguments: %v\n", err)
@@ -180,14 +235,29 @@ func main() {
 		panic(err)
 	}
 
-	fallback := language.MustParse("en")
-	cat, err := catalog.NewFromMap(dict, catalog.Fallback(fallback))
+	var fallbacks []language.Tag
+	for _, lang := range config.fallbacks {
+		fallbacks = append(fallbacks, language.MustParse(lang))
+	}
+
+	translationsCounter := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alertmanagerbot",
+		Name:      "translation_missing_total",
+		Help:      "Number of messages missing in a language's translation",
+	}, []string{"language"})
+
+	prometheus.MustRegister(translationsCounter)
+
+	cat, err := translation.NewCatalog(
+		dict,
+		translation.WithFallbacks(fallbacks...),
+		translation.WithLogger(log.With(logger, "component", "translation")),
+		translation.WithMissingCounter(translationsCounter),
+	)
 	if err != nil {
 		panic(err)
 	}
 
-	translator := message.NewPrinter(cat.Languages()[0], message.Catalog(cat))
-
 	//----------------------------------------------------------------------------
 	// Template init
 	//----------------------------------------------------------------------------
@@ -199,6 +269,16 @@ func main() {
 	funcs["duration"] = func(start time.Time, end time.Time) string {
 		return durafmt.Parse(end.Sub(start)).String()
 	}
+	// tr is replaced by the bot with a translation into the chat's language
+	funcs["tr"] = fmt.Sprintf
+	// date is replaced by the bot with a formatter in the chat's timezone
+	funcs["date"] = func(layout string, t time.Time) string {
+		return t.Format(layout)
+	}
+	// oncall is replaced by the bot with the @username on call for the given team
+	funcs["oncall"] = func(team string) string {
+		return ""
+	}
 
 	vendor.DefaultFuncs = funcs
 
@@ -264,17 +344,116 @@ func main() {
 		os.Exit(1)
 	}
 
+	pendingStore, err := telegram.NewPendingStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create pending store", "err", err)
+		os.Exit(1)
+	}
+
+	historyStore, err := telegram.NewHistoryStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create history store", "err", err)
+		os.Exit(1)
+	}
+
+	ackStore, err := telegram.NewAckStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create ack store", "err", err)
+		os.Exit(1)
+	}
+
+	messageStore, err := telegram.NewMessageStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create message store", "err", err)
+		os.Exit(1)
+	}
+
+	escalationStore, err := telegram.NewEscalationStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create escalation store", "err", err)
+		os.Exit(1)
+	}
+
+	rotationStore, err := telegram.NewRotationStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create rotation store", "err", err)
+		os.Exit(1)
+	}
+
+	flapStore, err := telegram.NewFlapStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create flap store", "err", err)
+		os.Exit(1)
+	}
+
+	silenceStore, err := telegram.NewSilenceStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create silence store", "err", err)
+		os.Exit(1)
+	}
+
+	incidentStore, err := telegram.NewIncidentStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create incident store", "err", err)
+		os.Exit(1)
+	}
+
+	noteStore, err := telegram.NewNoteStore(kvStore)
+	if err != nil {
+		level.Error(tlogger).Log("msg", "failed to create note store", "err", err)
+		os.Exit(1)
+	}
+
+	var botConfig telegram.Config
+	if config.configFile != "" {
+		botConfig, err = telegram.LoadConfig(config.configFile)
+		if err != nil {
+			level.Error(tlogger).Log("msg", "failed to load config file", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	var heartbeat vendor.Matchers
+	if config.heartbeat != "" {
+		heartbeat, err = vendor.ParseMatchers(config.heartbeat)
+		if err != nil {
+			level.Error(tlogger).Log("msg", "failed to parse heartbeat matchers", "err", err)
+			os.Exit(1)
+		}
+	}
+
 	bot, err := telegram.NewBot(
 		chatStore, config.telegramToken, config.telegramAdmins[0], config.telegramVerbose,
 		telegram.WithLogger(logger),
 		telegram.WithAddr(config.listenAddr),
 		telegram.WithAlertmanager(config.alertmanager),
-		telegram.WithTranslation(translator),
+		telegram.WithPrometheus(config.prometheus),
+		telegram.WithCatalog(cat),
 		telegram.WithTemplates(tmpl),
 		telegram.WithRevision(Revision),
 		telegram.WithStartTime(StartTime),
 		telegram.WithExtraAdmins(config.telegramAdmins[1:]...),
 		telegram.WithChatsToSubscribe(chats...),
+		telegram.WithPendingStore(pendingStore),
+		telegram.WithHistoryStore(historyStore),
+		telegram.WithHistoryRetention(config.historyRetention),
+		telegram.WithAckStore(ackStore),
+		telegram.WithMessageStore(messageStore),
+		telegram.WithEscalationStore(escalationStore),
+		telegram.WithEscalationPolicies(botConfig.Escalations...),
+		telegram.WithSilencePresets(botConfig.Silences...),
+		telegram.WithRoles(botConfig.Roles),
+		telegram.WithQueryRole(botConfig.QueryRole),
+		telegram.WithSilenceRole(botConfig.SilenceRole),
+		telegram.WithRotationStore(rotationStore),
+		telegram.WithFlapStore(flapStore),
+		telegram.WithFlapping(config.flapWindow, config.flapThreshold),
+		telegram.WithSilenceStore(silenceStore),
+		telegram.WithSilenceWarning(config.silenceWarning),
+		telegram.WithIncidentStore(incidentStore),
+		telegram.WithNoteStore(noteStore),
+		telegram.WithAlertmanagerProbe(config.probeInterval),
+		telegram.WithHeartbeat(heartbeat, config.heartbeatTimeout),
 	)
 	if err != nil {
 		level.Error(tlogger).Log("msg", "failed to create bot", "err", err)
@@ -292,12 +471,16 @@ func main() {
 	level.Debug(tlogger).Log(
 		"msg", "with this environment",
 		"alertmanager_url", config.alertmanager,
+		"prometheus_url", config.prometheus,
 		"log_level", config.logLevel,
 		"admins", fmt.Sprint(config.telegramAdmins),
 		"store", config.store,
 		"lang", fmt.Sprint(cat.Languages()),
 	)
 
+	// Show the commands in the menus of Telegram
+	go bot.RegisterCommands()
+
 	// Serve Alertmanager webhooks
 	level.Info(tlogger).Log("msg", "starting webhooks serving")
 	go bot.Serve(webhooks)
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -17,9 +18,25 @@ type alertResponse struct {
 
 // ListAlerts returns a slice of Alert and an error.
 func ListAlerts(logger log.Logger, alertmanagerURL string) ([]*types.Alert, error) {
+	return QueryAlerts(logger, alertmanagerURL, true, true)
+}
+
+// QueryAlerts returns a slice of Alert, leaving out the silenced or inhibited ones when they're not included.
+func QueryAlerts(logger log.Logger, alertmanagerURL string, silenced, inhibited bool) ([]*types.Alert, error) {
+
+	query := url.Values{}
+	if !silenced {
+		query.Set("silenced", "false")
+	}
+	if !inhibited {
+		query.Set("inhibited", "false")
+	}
 
 	apiEndpoint := string("/api/v1/alerts")
 	getURL := alertmanagerURL + apiEndpoint
+	if len(query) > 0 {
+		getURL += "?" + query.Encode()
+	}
 	level.Debug(logger).Log("msg", "assembled URL for GETing alerts request", "url", getURL)
 
 	response, err := httpRetry(logger, http.MethodGet, getURL)
@@ -28,6 +28,12 @@ func TestListAlerts(t *testing.T) {
 	mux.HandleFunc("/ok/api/v1/alerts", func(res http.ResponseWriter, req *http.Request) {
 		switch req.Method {
 		case http.MethodGet:
+			if req.URL.Query().Get("silenced") == "false" {
+				res.Header().Set("Content-Type", "application/json")
+				res.WriteHeader(http.StatusOK)
+				res.Write([]byte(`{"status":"success","data":[]}`))
+				return
+			}
 			res.Header().Set("Content-Type", "application/json")
 			res.WriteHeader(http.StatusOK)
 			res.Write([]byte(alertsJSON))
@@ -67,4 +73,14 @@ func TestListAlerts(t *testing.T) {
 	} else {
 		t.Log("ListAlerts() : Test 2 PASSED.")
 	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: silenced alerts left out
+	// ---------------------------------------------------------------------------
+	alerts, err := QueryAlerts(logger, routeOK.String(), false, true)
+	if err != nil || len(alerts) != 0 {
+		t.Errorf("QueryAlerts() : Test 3 FAILED, got %d alerts and error: %v", len(alerts), err)
+	} else {
+		t.Log("QueryAlerts() : Test 3 PASSED.")
+	}
 }
@@ -24,6 +24,11 @@ func httpBackoff() *backoff.ExponentialBackOff {
 }
 
 func httpRetry(logger log.Logger, method string, url string) (*http.Response, error) {
+	return httpRetryBackoff(logger, method, url, httpBackoff())
+}
+
+// httpRetryBackoff is httpRetry with the retries paced by the given backoff policy
+func httpRetryBackoff(logger log.Logger, method string, url string, policy backoff.BackOff) (*http.Response, error) {
 
 	var resp *http.Response
 	var err error
@@ -66,7 +71,7 @@ func httpRetry(logger log.Logger, method string, url string) (*http.Response, er
 		)
 	}
 
-	if err := backoff.RetryNotify(fn, httpBackoff(), notify); err != nil {
+	if err := backoff.RetryNotify(fn, policy, notify); err != nil {
 		return nil, err
 	}
 
@@ -81,12 +86,13 @@ func request(logger log.Logger, method string, code int, url string, payLoad []b
 
 	// Assembly request for API
 	request, err := http.NewRequest(method, url, bytes.NewBuffer([]byte(payLoad)))
+	if err != nil {
+		level.Error(logger).Log("msg", "error while assembling http.NewRequest", "err", err)
+		return nil, err
+	}
 	// Adding necessary 'key-value' pairs to header
 	request.Header.Set("Content-Type", "application/json")
 	request.Header.Set("Accept-Charset", "UTF-8")
-	if err != nil {
-		return response, level.Error(logger).Log("msg", "error while assembling http.NewRequest", "err", err)
-	}
 
 	// Client creating
 	transport := &http.Transport{
@@ -97,7 +103,8 @@ func request(logger log.Logger, method string, code int, url string, payLoad []b
 	// Starting request, receiving response
 	response, err = client.Do(request)
 	if err != nil {
-		return response, level.Error(logger).Log("msg", "error while doing request", "err", err)
+		level.Error(logger).Log("msg", "error while doing request", "err", err)
+		return nil, err
 	}
 	if response.StatusCode != code {
 		return response, level.Error(logger).Log("msg", "awaiting response status code", "code", code, "msg", "got this status code", "code", response.StatusCode)
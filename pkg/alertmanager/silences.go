@@ -3,6 +3,8 @@ package alertmanager
 import (
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"sort"
 	"strings"
@@ -13,6 +15,7 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/hako/durafmt"
+	"github.com/prometheus/common/model"
 )
 
 // ListSilences returns a slice of Silence and an error.
@@ -87,8 +90,19 @@ func Resolved(s vendor.Silence) bool {
 	return !s.EndsAt.After(time.Now())
 }
 
-// PostSilence used for POSTing valid silence JSON on alertmanager API endpoint.
-func PostSilence(logger log.Logger, alertmanagerURL string, silence vendor.Silence) error {
+// Silenced returns if any of the active silences matches the label set.
+func Silenced(silences []vendor.Silence, lset model.LabelSet) bool {
+	for _, s := range silences {
+		if s.Status.State == vendor.SilenceStateActive && s.Matchers.Matches(lset) {
+			return true
+		}
+	}
+	return false
+}
+
+// PostSilence used for POSTing valid silence JSON on alertmanager API endpoint,
+// it returns the ID of the silence created or updated.
+func PostSilence(logger log.Logger, alertmanagerURL string, silence vendor.Silence) (string, error) {
 
 	apiEndpoint := string("/api/v2/silences")
 	postURL := alertmanagerURL + apiEndpoint
@@ -96,18 +110,32 @@ func PostSilence(logger log.Logger, alertmanagerURL string, silence vendor.Silen
 
 	payLoad, err := json.Marshal(silence)
 	if err != nil {
-		return level.Error(logger).Log("msg", "marshalling silence to JSON", "err", err)
+		level.Error(logger).Log("msg", "marshalling silence to JSON", "err", err)
+		return "", err
 	}
 
 	level.Debug(logger).Log("msg", "testing created silence", "silence", string(payLoad))
 
 	response, err := request(logger, http.MethodPost, http.StatusOK, postURL, payLoad)
 	if err != nil {
-		return level.Error(logger).Log("msg", "error while POST silence to alertmanager", "err", err)
+		level.Error(logger).Log("msg", "error while POST silence to alertmanager", "err", err)
+		return "", err
 	}
 	defer response.Body.Close()
 
-	return nil
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return "", fmt.Errorf("alertmanager answered %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var created struct {
+		SilenceID string `json:"silenceID"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&created); err != nil {
+		return "", err
+	}
+
+	return created.SilenceID, nil
 }
 
 // DeleteSuperSilence used for DELETing supersilence from */sm*-command on alertmanager API endpoint.
@@ -119,9 +147,15 @@ func DeleteSuperSilence(logger log.Logger, alertmanagerURL string, silenceID str
 
 	response, err := request(logger, http.MethodDelete, http.StatusOK, postURL, []byte{})
 	if err != nil {
-		return level.Error(logger).Log("msg", "error while DELETE supersilence from alertmanager", "err", err)
+		level.Error(logger).Log("msg", "error while DELETE supersilence from alertmanager", "err", err)
+		return err
 	}
 	defer response.Body.Close()
 
+	if response.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("alertmanager answered %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
 	return nil
 }
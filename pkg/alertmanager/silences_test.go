@@ -13,6 +13,7 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,9 +56,25 @@ func TestListSilencesAndPosting(t *testing.T) {
 		default:
 		}
 	})
+	mux.HandleFunc("/ok/api/v2/silences", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+		res.Write([]byte(`{"silenceID":"acf620d5-0239-4f7b-ab83-249b4da88d43"}`))
+	})
 	mux.HandleFunc("/wrong/api/v1/silences", func(res http.ResponseWriter, req *http.Request) {
 		res.WriteHeader(http.StatusNotFound)
 	})
+	mux.HandleFunc("/ok/api/v2/silence/", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/bad/api/v2/silence/", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNotFound)
+		res.Write([]byte("silence not found"))
+	})
+	mux.HandleFunc("/bad/api/v2/silences", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte(`"missing comment"`))
+	})
 
 	ts := httptest.NewServer(mux)
 	defer ts.Close()
@@ -85,16 +102,58 @@ func TestListSilencesAndPosting(t *testing.T) {
 	}
 
 	// ---------------------------------------------------------------------------
-	//  CASE: PostSilence
+	//  CASE: PostSilence to an unknown endpoint fails
 	// ---------------------------------------------------------------------------
 	routePost, _ := url.Parse(ts.URL + "/post")
-	err = PostSilence(logger, routePost.String(), *silence)
-	if err != nil {
-		t.Errorf("PostSilence() : Test 1 FAILED, got error: %s", err)
+	_, err = PostSilence(logger, routePost.String(), *silence)
+	if err == nil {
+		t.Error("PostSilence() : Test 1 FAILED, got no error")
 	} else {
 		t.Log("PostSilence() : Test 1 PASSED.")
 	}
 
+	// ---------------------------------------------------------------------------
+	//  CASE: PostSilence returns the ID of the silence
+	// ---------------------------------------------------------------------------
+	id, err := PostSilence(logger, routeOK.String(), *silence)
+	assert.NoError(t, err)
+	assert.Equal(t, silence.ID, id)
+	t.Log("PostSilence() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: silence rejected by Alertmanager
+	// ---------------------------------------------------------------------------
+	routeBad, _ := url.Parse(ts.URL + "/bad")
+	id, err = PostSilence(logger, routeBad.String(), *silence)
+	if assert.Error(t, err) {
+		assert.Equal(t, `alertmanager answered 400: "missing comment"`, err.Error())
+	}
+	assert.Empty(t, id)
+	t.Log("PostSilence() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: unreachable Alertmanager
+	// ---------------------------------------------------------------------------
+	_, err = PostSilence(logger, "http://127.0.0.1:1", *silence)
+	assert.Error(t, err)
+	t.Log("PostSilence() : Test 4 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: DeleteSuperSilence
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, DeleteSuperSilence(logger, routeOK.String(), silence.ID))
+	t.Log("DeleteSuperSilence() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: DeleteSuperSilence of an unknown silence or to an unreachable Alertmanager fails
+	// ---------------------------------------------------------------------------
+	err = DeleteSuperSilence(logger, routeBad.String(), silence.ID)
+	if assert.Error(t, err) {
+		assert.Equal(t, "alertmanager answered 404: silence not found", err.Error())
+	}
+	assert.Error(t, DeleteSuperSilence(logger, "http://127.0.0.1:1", silence.ID))
+	t.Log("DeleteSuperSilence() : Test 2 PASSED.")
+
 	// ---------------------------------------------------------------------------
 	//  CASE: active silence
 	// ---------------------------------------------------------------------------
@@ -129,3 +188,15 @@ func TestResolved(t *testing.T) {
 	s.EndsAt = time.Now().Add(-1 * time.Minute)
 	assert.True(t, Resolved(s))
 }
+
+func TestSilenced(t *testing.T) {
+	m, err := vendor.NewMatcher(vendor.MatchEqual, "alertname", "A")
+	assert.NoError(t, err)
+
+	s := vendor.Silence{Matchers: vendor.Matchers{m}, Status: vendor.SilenceStatus{State: vendor.SilenceStateActive}}
+	assert.True(t, Silenced([]vendor.Silence{s}, model.LabelSet{"alertname": "A", "severity": "critical"}))
+	assert.False(t, Silenced([]vendor.Silence{s}, model.LabelSet{"alertname": "B"}))
+
+	s.Status.State = vendor.SilenceStateExpired
+	assert.False(t, Silenced([]vendor.Silence{s}, model.LabelSet{"alertname": "A"}))
+}
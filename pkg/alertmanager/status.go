@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/cenkalti/backoff"
 	"github.com/go-kit/kit/log"
 )
 
@@ -26,9 +27,20 @@ type StatusResponse struct {
 
 // Status returns a StatusResponse or an error.
 func Status(logger log.Logger, alertmanagerURL string) (StatusResponse, error) {
+	return status(logger, alertmanagerURL, httpBackoff())
+}
+
+// Probe requests the status of Alertmanager once without retrying, so a caller
+// polling it on its own schedule is not held up while Alertmanager is down.
+func Probe(logger log.Logger, alertmanagerURL string) error {
+	_, err := status(logger, alertmanagerURL, &backoff.StopBackOff{})
+	return err
+}
+
+func status(logger log.Logger, alertmanagerURL string, policy backoff.BackOff) (StatusResponse, error) {
 	var statusResponse StatusResponse
 
-	resp, err := httpRetry(logger, http.MethodGet, alertmanagerURL+"/api/v1/status")
+	resp, err := httpRetryBackoff(logger, http.MethodGet, alertmanagerURL+"/api/v1/status", policy)
 	if err != nil {
 		return statusResponse, err
 	}
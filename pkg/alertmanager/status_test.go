@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -64,4 +65,19 @@ func TestStatus(t *testing.T) {
 	} else {
 		t.Log("Status() : Test 2 PASSED.")
 	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: probe answers once without retrying
+	// ---------------------------------------------------------------------------
+	if err = Probe(logger, routeOK.String()); err != nil {
+		t.Errorf("Probe() : Test 1 FAILED, got error: %s", err)
+	} else {
+		t.Log("Probe() : Test 1 PASSED.")
+	}
+	start := time.Now()
+	if err = Probe(logger, routeWrong.String()); err == nil || time.Since(start) > time.Second {
+		t.Error("Probe() : Test 2 FAILED")
+	} else {
+		t.Log("Probe() : Test 2 PASSED.")
+	}
 }
@@ -0,0 +1,186 @@
+package chart
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// ErrNoData is returned when there is no sample to draw
+var ErrNoData = errors.New("no data points")
+
+// margin around the plot area in pixels
+const margin = 16
+
+// gridLines is how many lines divide the plot area each way
+const gridLines = 4
+
+// Palette are the colors of the series in order, the series after the last color aren't drawn
+var Palette = []color.RGBA{
+	{R: 0x1f, G: 0x77, B: 0xb4, A: 0xff},
+	{R: 0xd6, G: 0x27, B: 0x28, A: 0xff},
+	{R: 0x2c, G: 0xa0, B: 0x2c, A: 0xff},
+	{R: 0xff, G: 0x7f, B: 0x0e, A: 0xff},
+	{R: 0x94, G: 0x67, B: 0xbd, A: 0xff},
+	{R: 0x8c, G: 0x56, B: 0x4b, A: 0xff},
+	{R: 0xe6, G: 0xc2, B: 0x00, A: 0xff},
+	{R: 0x30, G: 0x30, B: 0x30, A: 0xff},
+}
+
+// Legend are squares of about the colors of the Palette to tell the series apart in text
+var Legend = []string{"🟦", "🟥", "🟩", "🟧", "🟪", "🟫", "🟨", "⬛"}
+
+var (
+	background = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	grid       = color.RGBA{R: 0xe0, G: 0xe0, B: 0xe0, A: 0xff}
+	axis       = color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff}
+)
+
+// Bounds are the ranges of time and values a chart shows, from the bottom left to the top right corner
+type Bounds struct {
+	From, To time.Time
+	Min, Max float64
+}
+
+// bounds returns the ranges of the samples of the series drawn
+func bounds(matrix model.Matrix) (Bounds, error) {
+
+	b := Bounds{Min: math.Inf(1), Max: math.Inf(-1)}
+	found := false
+
+	for i, s := range matrix {
+		if i == len(Palette) {
+			break
+		}
+		for _, v := range s.Values {
+			f := float64(v.Value)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				continue
+			}
+			t := v.Timestamp.Time()
+			if !found || t.Before(b.From) {
+				b.From = t
+			}
+			if !found || t.After(b.To) {
+				b.To = t
+			}
+			b.Min, b.Max = math.Min(b.Min, f), math.Max(b.Max, f)
+			found = true
+		}
+	}
+	if !found {
+		return b, ErrNoData
+	}
+
+	// A flat line is drawn in the middle
+	if b.Min == b.Max {
+		b.Min, b.Max = b.Min-1, b.Max+1
+	}
+
+	return b, nil
+}
+
+// Line draws the series of the matrix as a PNG line chart of the given size, with a line
+// per series in the colors of the Palette. The labels are left to the caller, the chart
+// only has a grid, the bounds it was drawn with are returned.
+func Line(w io.Writer, matrix model.Matrix, width, height int) (Bounds, error) {
+
+	b, err := bounds(matrix)
+	if err != nil {
+		return b, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	plot := image.Rect(margin, margin, width-margin, height-margin)
+	for i := 0; i <= gridLines; i++ {
+		x := plot.Min.X + i*plot.Dx()/gridLines
+		y := plot.Min.Y + i*plot.Dy()/gridLines
+		line(img, x, plot.Min.Y, x, plot.Max.Y, grid)
+		line(img, plot.Min.X, y, plot.Max.X, y, grid)
+	}
+	line(img, plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y, axis)
+	line(img, plot.Min.X, plot.Min.Y, plot.Min.X, plot.Max.Y, axis)
+
+	span := b.To.Sub(b.From)
+	point := func(p model.SamplePair) (int, int) {
+		x := plot.Min.X
+		if span > 0 {
+			x += int(float64(plot.Dx()) * float64(p.Timestamp.Time().Sub(b.From)) / float64(span))
+		}
+		y := plot.Max.Y - int(float64(plot.Dy())*(float64(p.Value)-b.Min)/(b.Max-b.Min))
+		return x, y
+	}
+
+	for i, s := range matrix {
+		if i == len(Palette) {
+			break
+		}
+		c := Palette[i]
+
+		// Missing samples and gaps in the series break the line
+		drawing := false
+		var px, py int
+		for _, v := range s.Values {
+			f := float64(v.Value)
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				drawing = false
+				continue
+			}
+			x, y := point(v)
+			if drawing {
+				line(img, px, py, x, y, c)
+				line(img, px, py+1, x, y+1, c)
+			} else {
+				img.Set(x, y, c)
+				img.Set(x, y+1, c)
+			}
+			px, py, drawing = x, y, true
+		}
+	}
+
+	return b, png.Encode(w, img)
+}
+
+// line draws a line between two points with Bresenham's algorithm
+func line(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for {
+		img.Set(x0, y0, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * e; e2 >= dy {
+			e += dy
+			x0 += sx
+		} else {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,65 @@
+package chart
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLine(t *testing.T) {
+
+	start := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	at := func(minutes int) model.Time {
+		return model.TimeFromUnix(start.Add(time.Duration(minutes) * time.Minute).Unix())
+	}
+
+	matrix := model.Matrix{
+		{Metric: model.Metric{"instance": "a"}, Values: []model.SamplePair{{Timestamp: at(0), Value: 1}, {Timestamp: at(10), Value: 3}}},
+		{Metric: model.Metric{"instance": "b"}, Values: []model.SamplePair{{Timestamp: at(5), Value: 2}, {Timestamp: at(20), Value: model.SampleValue(math.NaN())}}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a PNG of the size with the series in the colors of the palette
+	// ---------------------------------------------------------------------------
+	var buf bytes.Buffer
+	bounds, err := Line(&buf, matrix, 200, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, Bounds{From: start, To: start.Add(10 * time.Minute), Min: 1, Max: 3}, Bounds{
+		From: bounds.From.UTC(), To: bounds.To.UTC(), Min: bounds.Min, Max: bounds.Max,
+	})
+
+	img, err := png.Decode(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 200, img.Bounds().Dx())
+	assert.Equal(t, 100, img.Bounds().Dy())
+
+	// The first series goes from the bottom left to the top right corner of the plot area
+	assert.Equal(t, Palette[0], img.At(margin, 100-margin))
+	assert.Equal(t, Palette[0], img.At(200-margin, margin))
+	assert.Equal(t, background, img.At(0, 0))
+	t.Log("Line() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a flat line and no data
+	// ---------------------------------------------------------------------------
+	bounds, err = Line(&bytes.Buffer{}, model.Matrix{{Values: []model.SamplePair{{Timestamp: at(0), Value: 5}}}}, 200, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, bounds.Min)
+	assert.Equal(t, 6.0, bounds.Max)
+
+	_, err = Line(&bytes.Buffer{}, model.Matrix{}, 200, 100)
+	assert.Equal(t, ErrNoData, err)
+	_, err = Line(&bytes.Buffer{}, model.Matrix{{Values: []model.SamplePair{{Timestamp: at(0), Value: model.SampleValue(math.NaN())}}}}, 200, 100)
+	assert.Equal(t, ErrNoData, err)
+	t.Log("Line() : Test 2 PASSED.")
+
+}
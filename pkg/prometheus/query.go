@@ -0,0 +1,141 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+)
+
+// requestTimeout is how long a query may take before it is given up
+const requestTimeout = 30 * time.Second
+
+// response is the envelope of every answer of the Prometheus HTTP API
+type response struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	ErrorType string          `json:"errorType"`
+	Error     string          `json:"error"`
+}
+
+// queryData is the data of a query answer, its result depends on the type
+type queryData struct {
+	ResultType model.ValueType `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// get calls the endpoint of the Prometheus HTTP API with the parameters and returns the data of the answer
+func get(logger log.Logger, prometheusURL, endpoint string, params url.Values) (json.RawMessage, error) {
+
+	getURL := strings.TrimSuffix(prometheusURL, "/") + endpoint + "?" + params.Encode()
+	level.Debug(logger).Log("msg", "assembled URL for querying Prometheus", "url", getURL)
+
+	client := &http.Client{Timeout: requestTimeout}
+	res, err := client.Get(getURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	// Bad queries are answered with an error status and the reason in the body
+	var r response
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("unexpected answer from Prometheus, status %d: %s", res.StatusCode, err.Error())
+	}
+	if r.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", r.ErrorType, r.Error)
+	}
+
+	return r.Data, nil
+}
+
+// timestamp formats a time as the seconds the Prometheus HTTP API expects
+func timestamp(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+// decode returns the result of a query answer as its type
+func decode(data json.RawMessage) (model.Value, error) {
+
+	var d queryData
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+
+	var v model.Value
+	switch d.ResultType {
+	case model.ValMatrix:
+		v = &model.Matrix{}
+	case model.ValVector:
+		v = &model.Vector{}
+	case model.ValScalar:
+		v = &model.Scalar{}
+	case model.ValString:
+		v = &model.String{}
+	default:
+		return nil, fmt.Errorf("unknown result type %q", d.ResultType)
+	}
+	if err := json.Unmarshal(d.Result, v); err != nil {
+		return nil, err
+	}
+
+	// The results are returned as values like the API of the Prometheus client
+	switch v := v.(type) {
+	case *model.Matrix:
+		return *v, nil
+	case *model.Vector:
+		return *v, nil
+	}
+	return v, nil
+}
+
+// QueryRange evaluates the expression over the range of time at each step
+func QueryRange(logger log.Logger, prometheusURL, expr string, start, end time.Time, step time.Duration) (model.Matrix, error) {
+
+	if step <= 0 {
+		return nil, errors.New("the step must be positive")
+	}
+
+	data, err := get(logger, prometheusURL, "/api/v1/query_range", url.Values{
+		"query": {expr},
+		"start": {timestamp(start)},
+		"end":   {timestamp(end)},
+		"step":  {strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	matrix, ok := v.(model.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unexpected result type %s of a range query", v.Type())
+	}
+
+	return matrix, nil
+}
+
+// Query evaluates the expression at the time
+func Query(logger log.Logger, prometheusURL, expr string, at time.Time) (model.Value, error) {
+
+	data, err := get(logger, prometheusURL, "/api/v1/query", url.Values{
+		"query": {expr},
+		"time":  {timestamp(at)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(data)
+}
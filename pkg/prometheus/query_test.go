@@ -0,0 +1,143 @@
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestQueryRange(t *testing.T) {
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	logger = level.NewFilter(logger, level.AllowDebug())
+
+	mux := http.NewServeMux()
+
+	// Query Range Mock
+	mux.HandleFunc("/ok/api/v1/query_range", func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, `up{job="node"}`, req.URL.Query().Get("query"))
+		assert.Equal(t, "1615377600.000", req.URL.Query().Get("start"))
+		assert.Equal(t, "1615381200.000", req.URL.Query().Get("end"))
+		assert.Equal(t, "60", req.URL.Query().Get("step"))
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[
+			{"metric":{"__name__":"up","job":"node","instance":"a"},"values":[[1615377600,"1"],[1615377660,"0"]]}
+		]}}`))
+	})
+	mux.HandleFunc("/bad/api/v1/query_range", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusBadRequest)
+		res.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	})
+	mux.HandleFunc("/vector/api/v1/query_range", func(res http.ResponseWriter, req *http.Request) {
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	})
+	mux.HandleFunc("/wrong/api/v1/query_range", func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusNotFound)
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	start := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the series of the expression
+	// ---------------------------------------------------------------------------
+	matrix, err := QueryRange(logger, ts.URL+"/ok/", `up{job="node"}`, start, end, time.Minute)
+	assert.NoError(t, err)
+	if assert.Len(t, matrix, 1) {
+		assert.Equal(t, model.LabelValue("a"), matrix[0].Metric["instance"])
+		assert.Equal(t, []model.SamplePair{
+			{Timestamp: model.TimeFromUnix(start.Unix()), Value: 1},
+			{Timestamp: model.TimeFromUnix(start.Unix() + 60), Value: 0},
+		}, matrix[0].Values)
+	}
+	t.Log("QueryRange() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: errors of Prometheus and of the answer
+	// ---------------------------------------------------------------------------
+	_, err = QueryRange(logger, ts.URL+"/bad", "up{", start, end, time.Minute)
+	assert.EqualError(t, err, "bad_data: parse error")
+	_, err = QueryRange(logger, ts.URL+"/vector", "up", start, end, time.Minute)
+	assert.Error(t, err)
+	_, err = QueryRange(logger, ts.URL+"/wrong", "up", start, end, time.Minute)
+	assert.Error(t, err)
+	_, err = QueryRange(logger, ts.URL+"/ok", `up{job="node"}`, start, end, 0)
+	assert.Error(t, err)
+	t.Log("QueryRange() : Test 2 PASSED.")
+
+}
+
+func TestQuery(t *testing.T) {
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	logger = level.NewFilter(logger, level.AllowDebug())
+
+	results := map[string]string{
+		"vector": `{"resultType":"vector","result":[{"metric":{"instance":"a"},"value":[1615377600,"1"]}]}`,
+		"matrix": `{"resultType":"matrix","result":[{"metric":{"instance":"a"},"values":[[1615377600,"1"]]}]}`,
+		"scalar": `{"resultType":"scalar","result":[1615377600,"2.5"]}`,
+		"string": `{"resultType":"string","result":[1615377600,"hello"]}`,
+		"bad":    `{"resultType":"unknown","result":[]}`,
+	}
+
+	mux := http.NewServeMux()
+
+	// Query Mock
+	mux.HandleFunc("/api/v1/query", func(res http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "1615377600.000", req.URL.Query().Get("time"))
+		res.Header().Set("Content-Type", "application/json")
+		res.Write([]byte(`{"status":"success","data":` + results[req.URL.Query().Get("query")] + `}`))
+	})
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	at := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: every type of result
+	// ---------------------------------------------------------------------------
+	v, err := Query(logger, ts.URL, "vector", at)
+	assert.NoError(t, err)
+	if assert.IsType(t, model.Vector{}, v) {
+		assert.Equal(t, model.SampleValue(1), v.(model.Vector)[0].Value)
+	}
+	v, err = Query(logger, ts.URL, "matrix", at)
+	assert.NoError(t, err)
+	assert.IsType(t, model.Matrix{}, v)
+	v, err = Query(logger, ts.URL, "scalar", at)
+	assert.NoError(t, err)
+	if assert.IsType(t, &model.Scalar{}, v) {
+		assert.Equal(t, model.SampleValue(2.5), v.(*model.Scalar).Value)
+	}
+	v, err = Query(logger, ts.URL, "string", at)
+	assert.NoError(t, err)
+	if assert.IsType(t, &model.String{}, v) {
+		assert.Equal(t, "hello", v.(*model.String).Value)
+	}
+	t.Log("Query() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an unknown type of result
+	// ---------------------------------------------------------------------------
+	_, err = Query(logger, ts.URL, "bad", at)
+	assert.Error(t, err)
+	t.Log("Query() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,337 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramAcksDirectory = "telegram/acks"
+
+// maxAlertButtons limits the rows of buttons attached to a single alert message
+const maxAlertButtons = 20
+
+// errAlertNotFiring is returned when acting on an alert Alertmanager doesn't know as firing
+var errAlertNotFiring = errors.New("alert is not firing")
+
+// ackButton is sent with every firing alert not acknowledged yet
+var ackButton = telebot.InlineButton{Unique: "ack"}
+
+// Ack of a firing alert, valid until the alert resolves
+type Ack struct {
+	Fingerprint string `json:"fingerprint"`
+	// StartsAt of the firing acknowledged, an alert firing again is not acknowledged anymore
+	StartsAt time.Time `json:"startsAt"`
+	By       string    `json:"by"`
+	UserID   int       `json:"userId"`
+	At       time.Time `json:"at"`
+}
+
+// AckStore writes the acknowledgements to a libkv store backend
+type AckStore struct {
+	kv store.Store
+}
+
+// NewAckStore stores acknowledgements in the provided kv backend
+func NewAckStore(kv store.Store) (*AckStore, error) {
+	return &AckStore{kv: kv}, nil
+}
+
+// Get the acknowledgement of an alert, nil if there is none
+func (s *AckStore) Get(fingerprint string) (*Ack, error) {
+
+	key := fmt.Sprintf("%s/%s", telegramAcksDirectory, fingerprint)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ack Ack
+	if err := json.Unmarshal(kv.Value, &ack); err != nil {
+		return nil, err
+	}
+
+	return &ack, nil
+}
+
+// Set the acknowledgement of an alert
+func (s *AckStore) Set(ack Ack) error {
+	b, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramAcksDirectory, ack.Fingerprint)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove the acknowledgement of an alert
+func (s *AckStore) Remove(fingerprint string) error {
+	key := fmt.Sprintf("%s/%s", telegramAcksDirectory, fingerprint)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// userName returns the @username of the user, the full name if there is none
+func userName(u *telebot.User) string {
+	if u == nil {
+		return ""
+	}
+	if u.Username != "" {
+		return "@" + u.Username
+	}
+	return strings.TrimSpace(u.FirstName + " " + u.LastName)
+}
+
+// Acknowledge a firing alert
+func (b *Bot) handleAck(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseAckUsage", commandAck))
+		return
+	}
+
+	ack, err := b.ack(args[0], message.Sender)
+	if err == errAlertNotFiring {
+		b.telegram.Reply(message, p.Sprintf("responseAckNotFiring", args[0]))
+		return
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to acknowledge alert", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseAckFail"))
+		return
+	}
+
+	b.telegram.Reply(message, p.Sprintf("responseAcked", ack.Fingerprint))
+
+}
+
+// Acknowledge the alert of the pressed button
+func (b *Bot) handleAckCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+	b.commandsCounter.WithLabelValues(commandAck).Inc()
+
+	_, err := b.ack(c.Data, c.Sender)
+	switch err {
+	case nil:
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseAcked", c.Data)})
+	case errAlertNotFiring:
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseAckNotFiring", c.Data), ShowAlert: true})
+	default:
+		level.Warn(b.logger).Log("msg", "failed to acknowledge alert", "err", err)
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseAckFail"), ShowAlert: true})
+	}
+
+}
+
+// ack records the acknowledgement of a firing alert by the user and updates the messages about it
+func (b *Bot) ack(fingerprint string, user *telebot.User) (Ack, error) {
+
+	if b.ackStore == nil {
+		return Ack{}, errors.New("acknowledgements are not stored")
+	}
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		return Ack{}, err
+	}
+
+	for _, alert := range alerts {
+		if alert.Fingerprint().String() != fingerprint || alert.Resolved() {
+			continue
+		}
+
+		ack := Ack{
+			Fingerprint: fingerprint,
+			StartsAt:    alert.StartsAt,
+			By:          userName(user),
+			UserID:      user.ID,
+			At:          time.Now(),
+		}
+		if err := b.ackStore.Set(ack); err != nil {
+			return Ack{}, err
+		}
+
+		level.Info(b.logger).Log(
+			"msg", "user acknowledged alert",
+			"username", user.Username,
+			"user_id", user.ID,
+			"fingerprint", fingerprint,
+		)
+
+		b.recordIncidentEvent(fingerprint, IncidentEvent{Kind: incidentAcked, By: ack.By})
+		b.refreshMessages(fingerprint)
+		return ack, nil
+	}
+
+	return Ack{}, errAlertNotFiring
+}
+
+// clearAcks removes the acknowledgements of resolved alerts and of alerts firing again
+func (b *Bot) clearAcks(alerts vendor.Alerts) {
+
+	if b.ackStore == nil {
+		return
+	}
+
+	for _, alert := range alerts {
+		fingerprint := alertFingerprint(alert)
+		ack, err := b.ackStore.Get(fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get acknowledgement from store", "err", err)
+			continue
+		}
+		if ack == nil || alert.Status == string(model.AlertFiring) && ack.StartsAt.Equal(alert.StartsAt) {
+			continue
+		}
+		if err := b.ackStore.Remove(fingerprint); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove acknowledgement from store", "err", err)
+		}
+	}
+}
+
+// annotate returns a copy of the data with the state the bot keeps about the alerts
+func (b *Bot) annotate(data *vendor.Data) *vendor.Data {
+
+	d := *data
+	d.Alerts = make(vendor.Alerts, len(data.Alerts))
+
+	for i, alert := range data.Alerts {
+		if b.ackStore != nil && alert.Status == string(model.AlertFiring) {
+			ack, err := b.ackStore.Get(alertFingerprint(alert))
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to get acknowledgement from store", "err", err)
+			}
+			if ack != nil && ack.StartsAt.Equal(alert.StartsAt) {
+				alert.Acked = true
+				alert.AckedBy = ack.By
+			}
+		}
+		alert.Notes = b.alertNotes(alertFingerprint(alert))
+		if f := b.flapping(alert); f != nil {
+			alert.IsFlapping = true
+			alert.Flaps = len(f.Transitions)
+		}
+		d.Alerts[i] = alert
+	}
+
+	return &d
+}
+
+// alertsMarkup returns the buttons sent with the alerts, nil if there are none
+func (b *Bot) alertsMarkup(p *translation.Printer, data *vendor.Data) *telebot.ReplyMarkup {
+
+	var keyboard [][]telebot.InlineButton
+	for _, alert := range data.Alerts {
+		if len(keyboard) == maxAlertButtons {
+			break
+		}
+		if alert.Status != string(model.AlertFiring) {
+			continue
+		}
+		var row []telebot.InlineButton
+		if b.ackStore != nil && !alert.Acked {
+			button := *ackButton.With(alertFingerprint(alert))
+			button.Text = p.Sprintf("buttonAck", alert.Labels["alertname"])
+			// Escalated alerts are claimed, which acknowledges them as well
+			if b.escalationPolicy(alert) != nil {
+				button.Text = p.Sprintf("buttonTakingIt", alert.Labels["alertname"])
+			}
+			row = append(row, button)
+		}
+		row = append(row, b.silencePresetButtons(alertFingerprint(alert))...)
+		details := *alertDetailsButton.With(alertFingerprint(alert))
+		details.Text = "ℹ️"
+		row = append(row, details)
+		if b.prometheus != nil && alert.GeneratorURL != "" {
+			graph := *graphButton.With(alertFingerprint(alert))
+			graph.Text = "📈"
+			row = append(row, graph)
+		}
+		keyboard = append(keyboard, row)
+	}
+
+	if len(keyboard) == 0 {
+		return nil
+	}
+
+	return &telebot.ReplyMarkup{InlineKeyboard: keyboard}
+}
+
+// refreshMessages renders the messages sent about the alert again and edits them
+func (b *Bot) refreshMessages(fingerprint string) {
+
+	if b.messageStore == nil {
+		return
+	}
+
+	messages, err := b.messageStore.ByFingerprint(fingerprint)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get messages from store", "err", err)
+		return
+	}
+
+	for _, m := range messages {
+		b.editMessage(m)
+	}
+}
+
+// editMessage renders the alerts of a sent message again and edits it
+func (b *Bot) editMessage(m SentMessage) {
+
+	// The parts of a split message can't be edited with the whole text
+	if m.Parts > 1 {
+		return
+	}
+
+	chat := &telebot.Chat{ID: m.ChatID}
+	p := b.printer(chat, nil)
+	data := b.annotate(m.Data)
+
+	out, err := b.tmplData("telegram.default", p, b.location(chat), data)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to template alerts", "err", err)
+		return
+	}
+
+	// Editing without buttons removes them from the message
+	options := &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: b.alertsMarkup(p, data)}
+
+	sent := telebot.StoredMessage{MessageID: strconv.Itoa(m.MessageID), ChatID: m.ChatID}
+	if _, err := b.telegram.Edit(sent, out, options); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit alert message", "err", err)
+	}
+}
@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestAcks(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "acks"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewAckStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewAckStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	cat, _ := translation.NewCatalog(nil)
+	bot := &Bot{logger: log.NewNopLogger(), catalog: cat, ackStore: s}
+
+	startsAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	firing := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "A"}, StartsAt: startsAt, Fingerprint: "a"}
+	other := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "B"}, StartsAt: startsAt, Fingerprint: "b"}
+	data := &vendor.Data{Alerts: vendor.Alerts{firing, other}}
+
+	assert.NoError(t, s.Remove("a"))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing acknowledged, every firing alert gets a button
+	// ---------------------------------------------------------------------------
+	ack, err := s.Get("a")
+	assert.NoError(t, err)
+	assert.Nil(t, ack)
+
+	markup := bot.alertsMarkup(cat.Printer(), bot.annotate(data))
+	if assert.NotNil(t, markup) && assert.Len(t, markup.InlineKeyboard, 2) {
+		assert.Equal(t, "ack", markup.InlineKeyboard[0][0].Unique)
+		assert.Equal(t, "a", markup.InlineKeyboard[0][0].Data)
+	}
+	t.Log("alertsMarkup() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: acknowledged alerts are annotated and lose their button
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Set(Ack{Fingerprint: "a", StartsAt: startsAt, By: "@alice", UserID: 1, At: time.Now()}))
+
+	annotated := bot.annotate(data)
+	assert.True(t, annotated.Alerts[0].Acked)
+	assert.Equal(t, "@alice", annotated.Alerts[0].AckedBy)
+	assert.False(t, annotated.Alerts[1].Acked)
+	assert.False(t, data.Alerts[0].Acked)
+
+	markup = bot.alertsMarkup(cat.Printer(), annotated)
+	if assert.NotNil(t, markup) && assert.Len(t, markup.InlineKeyboard, 2) {
+		assert.Equal(t, "alert_details", markup.InlineKeyboard[0][0].Unique)
+		assert.Len(t, markup.InlineKeyboard[0], 1)
+		assert.Equal(t, "ack", markup.InlineKeyboard[1][0].Unique)
+		assert.Equal(t, "b", markup.InlineKeyboard[1][0].Data)
+	}
+	t.Log("annotate() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an alert firing again isn't acknowledged anymore
+	// ---------------------------------------------------------------------------
+	again := firing
+	again.StartsAt = startsAt.Add(time.Hour)
+	assert.False(t, bot.annotate(&vendor.Data{Alerts: vendor.Alerts{again}}).Alerts[0].Acked)
+
+	bot.clearAcks(vendor.Alerts{firing})
+	ack, _ = s.Get("a")
+	assert.NotNil(t, ack)
+
+	bot.clearAcks(vendor.Alerts{again})
+	ack, _ = s.Get("a")
+	assert.Nil(t, ack)
+	t.Log("clearAcks() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: resolved alerts clear their acknowledgement
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Set(Ack{Fingerprint: "a", StartsAt: startsAt}))
+	resolved := firing
+	resolved.Status = "resolved"
+	bot.clearAcks(vendor.Alerts{resolved})
+	ack, _ = s.Get("a")
+	assert.Nil(t, ack)
+	assert.Nil(t, bot.alertsMarkup(cat.Printer(), &vendor.Data{Alerts: vendor.Alerts{resolved}}))
+	t.Log("clearAcks() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: user names
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "@alice", userName(&telebot.User{Username: "alice", FirstName: "Alice"}))
+	assert.Equal(t, "Alice Smith", userName(&telebot.User{FirstName: "Alice", LastName: "Smith"}))
+	t.Log("userName() : Test 1 PASSED.")
+
+}
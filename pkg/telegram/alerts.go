@@ -0,0 +1,301 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// alertsPerPage is how many alerts a page of /alerts shows
+const alertsPerPage = 5
+
+// alertsPageButton turns the pages of /alerts, the query is read again from the command replied to
+var alertsPageButton = telebot.InlineButton{Unique: "alerts_page"}
+
+// alertsQuery is what /alerts is asked for
+type alertsQuery struct {
+	matchers vendor.Matchers
+	// by is the label the alerts are counted by, they are listed if empty
+	by        string
+	silenced  bool
+	inhibited bool
+}
+
+// alertGroup is the count of the alerts with the same value of the label grouped by
+type alertGroup struct {
+	Value string
+	Count int
+}
+
+// splitQuoted splits the string around whitespace outside of double quotes
+func splitQuoted(s string) []string {
+
+	var (
+		tokens  []string
+		token   strings.Builder
+		quoted  bool
+		escaped bool
+	)
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+			continue
+		}
+		token.WriteRune(r)
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	return tokens
+}
+
+// parseAlertsQuery parses the arguments of /alerts: matchers, "by <label>" and
+// -silenced or -inhibited to leave those alerts out
+func parseAlertsQuery(s string) (alertsQuery, error) {
+
+	q := alertsQuery{silenced: true, inhibited: true}
+
+	var matchers []string
+	tokens := splitQuoted(s)
+	for i := 0; i < len(tokens); i++ {
+		switch token := tokens[i]; token {
+		case "by":
+			if i+1 == len(tokens) {
+				return q, errors.New("expected a label after by")
+			}
+			i++
+			q.by = tokens[i]
+		case "-silenced":
+			q.silenced = false
+		case "-inhibited":
+			q.inhibited = false
+		default:
+			if token = strings.Trim(token, ","); token != "" {
+				matchers = append(matchers, token)
+			}
+		}
+	}
+
+	if len(matchers) > 0 {
+		ms, err := vendor.ParseMatchers(strings.Join(matchers, ","))
+		if err != nil {
+			return q, err
+		}
+		q.matchers = ms
+	}
+
+	return q, nil
+}
+
+// filterAlerts returns the alerts matching the query, the most recent first
+func filterAlerts(alerts []*types.Alert, q alertsQuery) []*types.Alert {
+
+	var filtered []*types.Alert
+	for _, alert := range alerts {
+		if q.matchers.Matches(alert.Labels) {
+			filtered = append(filtered, alert)
+		}
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if !filtered[i].StartsAt.Equal(filtered[j].StartsAt) {
+			return filtered[i].StartsAt.After(filtered[j].StartsAt)
+		}
+		return filtered[i].Fingerprint() < filtered[j].Fingerprint()
+	})
+
+	return filtered
+}
+
+// groupAlerts counts the alerts by the value of the label, the largest groups first
+func groupAlerts(alerts []*types.Alert, label string) []alertGroup {
+
+	counts := map[string]int{}
+	for _, alert := range alerts {
+		counts[string(alert.Labels[model.LabelName(label)])]++
+	}
+
+	var groups []alertGroup
+	for value, count := range counts {
+		groups = append(groups, alertGroup{Value: value, Count: count})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].Value < groups[j].Value
+	})
+
+	return groups
+}
+
+// pageOf returns the bounds of the page of n items, the page is clamped to the existing ones
+func pageOf(n, page, perPage int) (from, to, current, pages int) {
+
+	pages = (n + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+
+	current = page
+	if current < 1 {
+		current = 1
+	}
+	if current > pages {
+		current = pages
+	}
+
+	from = (current - 1) * perPage
+	to = from + perPage
+	if to > n {
+		to = n
+	}
+
+	return from, to, current, pages
+}
+
+// alertsPage returns the text and the buttons of the page of /alerts
+func (b *Bot) alertsPage(p *translation.Printer, chat *telebot.Chat, q alertsQuery, page int) (string, *telebot.ReplyMarkup, error) {
+
+	alerts, err := alertmanager.QueryAlerts(b.logger, b.alertmanager.String(), q.silenced, q.inhibited)
+	if err != nil {
+		return "", nil, err
+	}
+	alerts = filterAlerts(alerts, q)
+
+	if len(alerts) == 0 {
+		return p.Sprintf("responseNoAlerts"), nil, nil
+	}
+
+	if q.by != "" {
+		lines := []string{p.Sprintf("responseAlertsBy", q.by, len(alerts))}
+		for _, g := range groupAlerts(alerts, q.by) {
+			value := g.Value
+			if value == "" {
+				value = p.Sprintf("responseAlertsByNone")
+			}
+			lines = append(lines, fmt.Sprintf("<b>%s</b>: %d", html.EscapeString(value), g.Count))
+		}
+		return b.truncateMessage(strings.Join(lines, "\n")), nil, nil
+	}
+
+	from, to, page, pages := pageOf(len(alerts), page, alertsPerPage)
+
+	out, err := b.tmplAlerts(p, b.location(chat), alerts[from:to]...)
+	if err != nil {
+		return "", nil, err
+	}
+	if pages == 1 {
+		return b.truncateMessage(out), nil, nil
+	}
+
+	// The page is told first, a long page is truncated at its end
+	out = p.Sprintf("responseAlertsPage", page, pages, len(alerts)) + "\n\n" + out
+
+	var row []telebot.InlineButton
+	if page > 1 {
+		prev := *alertsPageButton.With(strconv.Itoa(page - 1))
+		prev.Text = p.Sprintf("buttonPrevious")
+		row = append(row, prev)
+	}
+	if page < pages {
+		next := *alertsPageButton.With(strconv.Itoa(page + 1))
+		next.Text = p.Sprintf("buttonNext")
+		row = append(row, next)
+	}
+
+	return b.truncateMessage(out), &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{row}}, nil
+}
+
+// commandArgs returns the arguments of a command
+func commandArgs(text string) string {
+	if i := strings.IndexAny(text, " \n"); i != -1 {
+		return text[i+1:]
+	}
+	return ""
+}
+
+// List the alerts, filtered by matchers, grouped by a label or paginated
+func (b *Bot) handleAlerts(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	q, err := parseAlertsQuery(commandArgs(message.Text))
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseAlertsInvalid", err.Error(), commandAlerts))
+		return
+	}
+
+	text, markup, err := b.alertsPage(p, message.Chat, q, 1)
+	if err != nil {
+		b.telegram.Send(message.Chat, p.Sprintf("responseAlertsFail", err))
+		level.Error(b.logger).Log("msg", "failed to list alerts", "err", err)
+		return
+	}
+
+	// The pages reply to the command to read the query again when turned
+	_, err = b.telegram.Reply(message, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: markup})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send list of alerts", "err", err)
+	}
+
+}
+
+// handleAlertsPageCallback edits the list of alerts to show the page of the button
+func (b *Bot) handleAlertsPageCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	// Without the command replied to, e.g. when it was deleted, all alerts are listed
+	var args string
+	if c.Message.ReplyTo != nil {
+		args = commandArgs(c.Message.ReplyTo.Text)
+	}
+	q, _ := parseAlertsQuery(args)
+	page, _ := strconv.Atoi(c.Data)
+
+	text, markup, err := b.alertsPage(p, c.Message.Chat, q, page)
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to list alerts", "err", err)
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseAlertsFail", err), ShowAlert: true})
+		return
+	}
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{})
+	if _, err := b.telegram.Edit(c.Message, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML, ReplyMarkup: markup}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit list of alerts", "err", err)
+	}
+}
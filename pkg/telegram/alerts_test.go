@@ -0,0 +1,115 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseAlertsQuery(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: matchers separated by commas and spaces, options and grouping
+	// ---------------------------------------------------------------------------
+	q, err := parseAlertsQuery(`severity="critical", team=~"db.*" instance="a b" -silenced by namespace`)
+	assert.NoError(t, err)
+	assert.Len(t, q.matchers, 3)
+	assert.Equal(t, "a b", q.matchers[2].Value)
+	assert.Equal(t, "namespace", q.by)
+	assert.False(t, q.silenced)
+	assert.True(t, q.inhibited)
+	t.Log("parseAlertsQuery() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing lists all alerts
+	// ---------------------------------------------------------------------------
+	q, err = parseAlertsQuery("")
+	assert.NoError(t, err)
+	assert.Empty(t, q.matchers)
+	assert.True(t, q.silenced)
+	assert.True(t, q.inhibited)
+	t.Log("parseAlertsQuery() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid queries
+	// ---------------------------------------------------------------------------
+	for _, s := range []string{"severity", "by", `team=~"("`} {
+		_, err := parseAlertsQuery(s)
+		assert.Error(t, err, s)
+	}
+	t.Log("parseAlertsQuery() : Test 3 PASSED.")
+
+}
+
+func TestFilterAlerts(t *testing.T) {
+
+	now := time.Now()
+	alert := func(name, namespace string, startsAt time.Time) *types.Alert {
+		a := &types.Alert{}
+		a.Labels = model.LabelSet{"alertname": model.LabelValue(name)}
+		if namespace != "" {
+			a.Labels["namespace"] = model.LabelValue(namespace)
+		}
+		a.StartsAt = startsAt
+		return a
+	}
+	alerts := []*types.Alert{
+		alert("A", "web", now.Add(-3*time.Hour)),
+		alert("B", "db", now.Add(-time.Hour)),
+		alert("C", "web", now.Add(-2*time.Hour)),
+		alert("D", "", now),
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the most recent alerts come first
+	// ---------------------------------------------------------------------------
+	filtered := filterAlerts(alerts, alertsQuery{})
+	if assert.Len(t, filtered, 4) {
+		assert.Equal(t, model.LabelValue("D"), filtered[0].Labels["alertname"])
+		assert.Equal(t, model.LabelValue("A"), filtered[3].Labels["alertname"])
+	}
+	t.Log("filterAlerts() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only the alerts matching all matchers
+	// ---------------------------------------------------------------------------
+	q, _ := parseAlertsQuery(`namespace="web"`)
+	filtered = filterAlerts(alerts, q)
+	if assert.Len(t, filtered, 2) {
+		assert.Equal(t, model.LabelValue("C"), filtered[0].Labels["alertname"])
+	}
+	t.Log("filterAlerts() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: counted by a label, the largest groups first
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []alertGroup{{"web", 2}, {"", 1}, {"db", 1}}, groupAlerts(alerts, "namespace"))
+	t.Log("groupAlerts() : Test 3 PASSED.")
+
+}
+
+func TestPageOf(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: pages are clamped to the existing ones
+	// ---------------------------------------------------------------------------
+	for _, c := range []struct{ n, page, from, to, current, pages int }{
+		{n: 12, page: 1, from: 0, to: 5, current: 1, pages: 3},
+		{n: 12, page: 3, from: 10, to: 12, current: 3, pages: 3},
+		{n: 12, page: 7, from: 10, to: 12, current: 3, pages: 3},
+		{n: 12, page: 0, from: 0, to: 5, current: 1, pages: 3},
+		{n: 0, page: 1, from: 0, to: 0, current: 1, pages: 1},
+	} {
+		from, to, current, pages := pageOf(c.n, c.page, 5)
+		assert.Equal(t, []int{c.from, c.to, c.current, c.pages}, []int{from, to, current, pages}, c)
+	}
+	t.Log("pageOf() : Test 1 PASSED.")
+
+}
@@ -9,9 +9,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
 	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
 
 	"github.com/go-kit/kit/log"
@@ -20,8 +22,8 @@ import (
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
 	"golang.org/x/text/language"
-	loc "golang.org/x/text/message"
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
 
@@ -33,11 +35,10 @@ const (
 
 	commandStatus   = "/status"
 	commandAlerts   = "/alerts"
+	commandAlert    = "/alert"
 	commandSilences = "/silences"
 
-	commandSilenceFor2Hours  = "/s2h"
-	commandSilenceFor48Hours = "/s48h"
-	commandSilenceFor2Weeks  = "/s2w"
+	commandSilenceFor = "/silence_for"
 
 	commandServiceMaintenance = "/sm"
 
@@ -47,13 +48,121 @@ const (
 
 	commandFingerprint = "/fingerprint"
 	commandAdmins      = "/admins"
+	commandLanguage    = "/lang"
+	commandSchedule    = "/schedule"
+	commandDigest      = "/digest"
+	commandReport      = "/report"
+	commandHistory     = "/history"
+	commandTop         = "/top"
+	commandAck         = "/ack"
+	commandOnCall      = "/oncall"
+	commandRemind      = "/remind"
+	commandIncident    = "/incident"
+	commandNote        = "/note"
+	commandGraph       = "/graph"
+	commandQuery       = "/query"
+	commandQueryRange  = "/query_range"
+
+	commandSilenceEvents = "/silence_events"
 )
 
+// languageAuto resets the chat language to the one of the user's Telegram client
+const languageAuto = "auto"
+
+// minDigestWindow is the shortest digest window, alerts are flushed once per tick anyway
+const minDigestWindow = time.Minute
+
+// tickInterval is how often the Bot checks for scheduled work while serving
+const tickInterval = 30 * time.Second
+
 // BotChatStore is all the Bot needs to store and read
 type BotChatStore interface {
 	List() ([]telebot.Chat, error)
 	Add(telebot.Chat) error
 	Remove(telebot.Chat) error
+	Settings(telebot.Chat) (ChatSettings, error)
+	SetSettings(telebot.Chat, ChatSettings) error
+	Reminded(telebot.Chat) (map[string]time.Time, error)
+	SetReminded(telebot.Chat, map[string]time.Time) error
+	ReportsSent(telebot.Chat) (map[string]time.Time, error)
+	SetReportsSent(telebot.Chat, map[string]time.Time) error
+}
+
+// BotPendingStore is all the Bot needs to hold alerts back from chats
+type BotPendingStore interface {
+	Get(telebot.Chat) (Pending, error)
+	Add(telebot.Chat, ...vendor.Alert) error
+	Remove(telebot.Chat) error
+}
+
+// BotHistoryStore is all the Bot needs to record alerts and read them back
+type BotHistoryStore interface {
+	Record(time.Time, ...vendor.Alert) error
+	Range(from, to time.Time) ([]AlertRecord, error)
+	Prune(before time.Time) error
+}
+
+// BotAckStore is all the Bot needs to keep acknowledgements of alerts
+type BotAckStore interface {
+	Get(fingerprint string) (*Ack, error)
+	Set(Ack) error
+	Remove(fingerprint string) error
+}
+
+// BotMessageStore is all the Bot needs to keep the alert messages sent
+type BotMessageStore interface {
+	Add(SentMessage) error
+	Get(chatID int64, messageID int) (SentMessage, error)
+	ByFingerprint(fingerprint string) ([]SentMessage, error)
+	Prune(before time.Time) error
+}
+
+// BotEscalationStore is all the Bot needs to keep the escalations of alerts
+type BotEscalationStore interface {
+	List() ([]Escalation, error)
+	Get(fingerprint string) (*Escalation, error)
+	Set(Escalation) error
+	Remove(fingerprint string) error
+}
+
+// BotRotationStore is all the Bot needs to keep the on-call rotations
+type BotRotationStore interface {
+	List() ([]Rotation, error)
+	Set(Rotation) error
+	Remove(name string) error
+}
+
+// BotFlapStore is all the Bot needs to track the state transitions of alerts
+type BotFlapStore interface {
+	List() ([]Flap, error)
+	Get(fingerprint string) (*Flap, error)
+	Set(Flap) error
+	Remove(fingerprint string) error
+}
+
+// BotSilenceStore is all the Bot needs to keep what it knows about silences
+type BotSilenceStore interface {
+	List() ([]SilenceWatch, error)
+	Get(id string) (*SilenceWatch, error)
+	Set(SilenceWatch) error
+	Remove(id string) error
+	Seen() (map[string]vendor.Silence, error)
+	SetSeen(map[string]vendor.Silence) error
+}
+
+// BotNoteStore is all the Bot needs to keep the notes about alerts
+type BotNoteStore interface {
+	Add(AlertNote) error
+	ByFingerprint(fingerprint string) ([]AlertNote, error)
+	Prune(before time.Time) error
+}
+
+// BotIncidentStore is all the Bot needs to keep the open incidents of the chats
+type BotIncidentStore interface {
+	List() ([]Incident, error)
+	Get(chatID int64) (*Incident, error)
+	Set(Incident) error
+	Remove(chatID int64) error
 }
 
 // Bot runs the alertmanager telegram
@@ -61,13 +170,50 @@ type Bot struct {
 	addr         string
 	admins       []int // must be kept sorted
 	alertmanager *url.URL
+	prometheus   *url.URL
+	queryRole    string
+	silenceRole  string
 	templates    *vendor.Template
 	chatStore    BotChatStore
-	logger       log.Logger
-	revision     string
-	startTime    time.Time
-
-	translator *loc.Printer
+	pendingStore BotPendingStore
+	historyStore BotHistoryStore
+	ackStore     BotAckStore
+	messageStore BotMessageStore
+
+	escalationStore    BotEscalationStore
+	escalationPolicies []EscalationPolicy
+	rotationStore      BotRotationStore
+
+	flapStore     BotFlapStore
+	flapWindow    time.Duration
+	flapThreshold int
+
+	silenceStore   BotSilenceStore
+	silenceWarning time.Duration
+	silencePresets []SilencePreset
+	roles          map[string][]int
+
+	incidentStore BotIncidentStore
+	// incidentsMu serializes the updates of the incidents by the webhooks and the commands
+	incidentsMu sync.Mutex
+	noteStore   BotNoteStore
+
+	probeInterval     time.Duration
+	probed            time.Time
+	unreachable       time.Time // since when Alertmanager is unreachable, zero if it isn't
+	heartbeatMatchers vendor.Matchers
+	heartbeatInterval time.Duration
+	heartbeat         time.Time
+	heartbeatMissing  bool
+
+	logger    log.Logger
+	revision  string
+	startTime time.Time
+
+	historyRetention time.Duration
+	pruned           time.Time
+
+	catalog *translation.Catalog
 
 	telegram *telebot.Bot
 
@@ -103,15 +249,22 @@ func NewBot(chatStore BotChatStore, token string, admin int, verbose bool, opts
 	// 	return nil, err
 	// }
 
+	// Without dictionaries every message is printed as its key
+	cat, err := translation.NewCatalog(nil)
+	if err != nil {
+		return nil, err
+	}
+
 	b := &Bot{
 		logger:          log.NewNopLogger(),
-		translator:      loc.NewPrinter(language.English),
+		catalog:         cat,
 		telegram:        bot,
 		chatStore:       chatStore,
 		addr:            "127.0.0.1:8080",
 		admins:          []int{admin},
 		alertmanager:    &url.URL{Host: "localhost:9093"},
 		commandsCounter: commandsCounter,
+		silencePresets:  defaultSilencePresets,
 		// TODO: initialize templates with default?
 	}
 
@@ -119,6 +272,32 @@ func NewBot(chatStore BotChatStore, token string, admin int, verbose bool, opts
 		opt(b)
 	}
 
+	for _, command := range b.builtinCommands() {
+		if b.silencePreset(command.Name) != nil {
+			return nil, fmt.Errorf("silence preset %s conflicts with a command of the bot", command.Name)
+		}
+	}
+
+	// Buttons sent with the alerts
+	bot.Handle(&alertDetailsButton, b.handleAlertDetailsCallback)
+	bot.Handle(&graphButton, b.handleGraphCallback)
+	bot.Handle(&ackButton, b.handleAckCallback)
+	bot.Handle(&silencePresetButton, b.handleSilencePresetCallback)
+	// Buttons asking which alert of a message replied to is silenced
+	bot.Handle(&silenceChoiceButton, b.handleSilenceChoiceCallback)
+	// Buttons turning the pages of /alerts
+	bot.Handle(&alertsPageButton, b.handleAlertsPageCallback)
+	// Buttons sent with the silence expiry warnings
+	bot.Handle(&silenceExtendButton, b.handleSilenceExtendCallback)
+	bot.Handle(&silenceExpireButton, b.handleSilenceExpireCallback)
+	// Buttons turning the pages of /silences and changing its silences
+	bot.Handle(&silencesPageButton, b.handleSilencesPageCallback)
+	bot.Handle(&silencesExpireButton, b.handleSilencesExpireCallback)
+	bot.Handle(&silencesExtendButton, b.handleSilencesExtendCallback)
+	bot.Handle(&silencesCloneButton, b.handleSilencesCloneCallback)
+	// Searching the alerts with @bot in any chat
+	bot.Handle(telebot.OnQuery, b.handleInlineQuery)
+
 	return b, nil
 }
 
@@ -143,6 +322,28 @@ func WithAlertmanager(u *url.URL) BotOption {
 	}
 }
 
+// WithPrometheus sets the url of the Prometheus the alerts are graphed from, graphs are disabled without it
+func WithPrometheus(u *url.URL) BotOption {
+	return func(b *Bot) {
+		b.prometheus = u
+	}
+}
+
+// WithQueryRole sets the role needed to query Prometheus with /query and /query_range, any admin can if empty
+func WithQueryRole(role string) BotOption {
+	return func(b *Bot) {
+		b.queryRole = role
+	}
+}
+
+// WithSilenceRole sets the role needed to silence for any duration with /silence_for and /sm,
+// the silence presets require their own roles, any admin can if empty
+func WithSilenceRole(role string) BotOption {
+	return func(b *Bot) {
+		b.silenceRole = role
+	}
+}
+
 // WithTemplates uses Alertmanager template to render messages for Telegram
 func WithTemplates(t *vendor.Template) BotOption {
 	return func(b *Bot) {
@@ -150,10 +351,141 @@ func WithTemplates(t *vendor.Template) BotOption {
 	}
 }
 
-// WithTranslation sets translation for Telegram messages
-func WithTranslation(t *loc.Printer) BotOption {
+// WithCatalog sets the translations catalog, a language is picked from it per chat
+func WithCatalog(c *translation.Catalog) BotOption {
+	return func(b *Bot) {
+		b.catalog = c
+	}
+}
+
+// WithPendingStore allows holding alerts for digests and during quiet hours, they are sent right away otherwise
+func WithPendingStore(s BotPendingStore) BotOption {
 	return func(b *Bot) {
-		b.translator = t
+		b.pendingStore = s
+	}
+}
+
+// WithHistoryStore records every alert received, needed for reports
+func WithHistoryStore(s BotHistoryStore) BotOption {
+	return func(b *Bot) {
+		b.historyStore = s
+	}
+}
+
+// WithAckStore allows acknowledging alerts
+func WithAckStore(s BotAckStore) BotOption {
+	return func(b *Bot) {
+		b.ackStore = s
+	}
+}
+
+// WithMessageStore keeps the alert messages sent, needed to edit them later
+func WithMessageStore(s BotMessageStore) BotOption {
+	return func(b *Bot) {
+		b.messageStore = s
+	}
+}
+
+// WithEscalationStore keeps the escalations of alerts in progress
+func WithEscalationStore(s BotEscalationStore) BotOption {
+	return func(b *Bot) {
+		b.escalationStore = s
+	}
+}
+
+// WithEscalationPolicies sets the policies escalating unclaimed alerts, the first matching one applies
+func WithEscalationPolicies(policies ...EscalationPolicy) BotOption {
+	return func(b *Bot) {
+		b.escalationPolicies = policies
+	}
+}
+
+// WithRotationStore keeps the on-call rotations
+func WithRotationStore(s BotRotationStore) BotOption {
+	return func(b *Bot) {
+		b.rotationStore = s
+	}
+}
+
+// WithFlapStore keeps the state transitions of alerts, needed to detect flapping
+func WithFlapStore(s BotFlapStore) BotOption {
+	return func(b *Bot) {
+		b.flapStore = s
+	}
+}
+
+// WithFlapping marks alerts changing their status at least threshold times within the window
+// as flapping, a threshold of zero disables it
+func WithFlapping(window time.Duration, threshold int) BotOption {
+	return func(b *Bot) {
+		b.flapWindow = window
+		b.flapThreshold = threshold
+	}
+}
+
+// WithSilenceStore keeps the silences watched and the chats they were created from
+func WithSilenceStore(s BotSilenceStore) BotOption {
+	return func(b *Bot) {
+		b.silenceStore = s
+	}
+}
+
+// WithIncidentStore keeps the open incidents of the chats
+func WithIncidentStore(s BotIncidentStore) BotOption {
+	return func(b *Bot) {
+		b.incidentStore = s
+	}
+}
+
+// WithNoteStore keeps the notes about alerts, shown with them next time
+func WithNoteStore(s BotNoteStore) BotOption {
+	return func(b *Bot) {
+		b.noteStore = s
+	}
+}
+
+// WithSilenceWarning sets how long before a silence expires it is warned about, zero disables it
+func WithSilenceWarning(d time.Duration) BotOption {
+	return func(b *Bot) {
+		b.silenceWarning = d
+	}
+}
+
+// WithAlertmanagerProbe sets how often the status of Alertmanager is probed, zero disables it
+func WithAlertmanagerProbe(d time.Duration) BotOption {
+	return func(b *Bot) {
+		b.probeInterval = d
+	}
+}
+
+// WithHeartbeat sets the heartbeat alert expected at least once per interval, no matchers disable it
+func WithHeartbeat(matchers vendor.Matchers, interval time.Duration) BotOption {
+	return func(b *Bot) {
+		b.heartbeatMatchers = matchers
+		b.heartbeatInterval = interval
+	}
+}
+
+// WithSilencePresets replaces the default silence presets, none keep the defaults
+func WithSilencePresets(presets ...SilencePreset) BotOption {
+	return func(b *Bot) {
+		if len(presets) > 0 {
+			b.silencePresets = presets
+		}
+	}
+}
+
+// WithRoles sets the user IDs of the roles the silence presets can require
+func WithRoles(roles map[string][]int) BotOption {
+	return func(b *Bot) {
+		b.roles = roles
+	}
+}
+
+// WithHistoryRetention sets how long resolved alerts are kept in the history, zero keeps them forever
+func WithHistoryRetention(d time.Duration) BotOption {
+	return func(b *Bot) {
+		b.historyRetention = d
 	}
 }
 
@@ -209,6 +541,9 @@ func (b *Bot) Handle(endpoint interface{}, handler interface{}) {
 // Serve listen for webhook messages from AlertManager and send them to the telegram
 func (b *Bot) Serve(webhooks <-chan vendor.Message) {
 
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
 	for {
 		select {
 
@@ -216,6 +551,20 @@ func (b *Bot) Serve(webhooks <-chan vendor.Message) {
 
 			level.Info(b.logger).Log("msg", "received webhook from Alertmanager")
 
+			w.Alerts = b.heartbeats(w.Alerts, time.Now())
+			if len(w.Alerts) == 0 {
+				continue
+			}
+
+			if b.historyStore != nil {
+				if err := b.historyStore.Record(time.Now(), w.Alerts...); err != nil {
+					level.Warn(b.logger).Log("msg", "failed to record alerts to history", "err", err)
+				}
+			}
+			b.clearAcks(w.Alerts)
+			b.trackEscalations(w.Alerts, time.Now())
+			b.trackIncidents(w.Alerts, time.Now())
+
 			chats, err := b.chatStore.List()
 			if err != nil {
 				level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
@@ -232,27 +581,207 @@ func (b *Bot) Serve(webhooks <-chan vendor.Message) {
 				ExternalURL:       w.ExternalURL,
 			}
 
-			out, err := b.templates.ExecuteHTMLString(`{{ template "telegram.default" . }}`, data)
-			if err != nil {
-				level.Warn(b.logger).Log("msg", "failed to template alerts", "err", err)
+			data = b.collapseFlapping(data, time.Now())
+			if len(data.Alerts) == 0 {
 				continue
 			}
 
 			for _, chat := range chats {
-				for _, splitedMessage := range b.splitMessage(out) {
-					_, err = b.telegram.Send(&chat, splitedMessage, &telebot.SendOptions{ParseMode: telebot.ModeHTML})
-					if err != nil {
-						level.Warn(b.logger).Log("msg", "failed to send message to subscribed chat", "err", err)
-					} else {
-						level.Debug(b.logger).Log("msg", "send this Telegram", "message", splitedMessage)
-					}
-				}
+				b.notify(chat, data, time.Now())
 			}
 
-		default:
+		case now := <-ticker.C:
+
+			b.flushPending(now)
+			b.sendReports(now)
+			b.escalate(now)
+			b.remind(now)
+			b.settleFlapping(now)
+			b.warnSilences(now)
+			b.pollSilences()
+			b.watch(now)
+			b.prune(now)
+		}
+	}
+
+}
+
+// notify sends the alerts to the chat according to its settings: chats in digest mode
+// get them batched, during quiet hours alerts below the chat's severity are sent silently
+// or held until the quiet hours end
+func (b *Bot) notify(chat telebot.Chat, data *vendor.Data, now time.Time) {
+
+	settings, err := b.chatStore.Settings(chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+	}
+
+	schedule := settings.Schedule
+	quiet := schedule.Quiet(now)
+
+	alerts := data.Alerts
+	if quiet {
+		var loud vendor.Alerts
+		alerts = nil
+		for _, alert := range data.Alerts {
+			if schedule.Loud(alert) {
+				loud = append(loud, alert)
+			} else {
+				alerts = append(alerts, alert)
+			}
+		}
+		if len(loud) > 0 {
+			b.sendData(chat, withAlerts(data, loud), false)
+		}
+	}
+	if len(alerts) == 0 {
+		return
+	}
+
+	if (settings.Digest > 0 || quiet && schedule.Hold) && b.pendingStore != nil {
+		if err := b.pendingStore.Add(chat, alerts...); err == nil {
+			level.Debug(b.logger).Log("msg", "held alerts for a digest", "chat", chat.ID, "alerts", len(alerts))
+			return
+		}
+		level.Warn(b.logger).Log("msg", "failed to hold alerts, sending them right away", "err", err)
+	}
+
+	b.sendData(chat, withAlerts(data, alerts), quiet)
+}
+
+// flushPending sends the held alerts as a digest to the chats whose digest window
+// has passed and which are not holding alerts during quiet hours
+func (b *Bot) flushPending(now time.Time) {
+
+	if b.pendingStore == nil {
+		return
+	}
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		return
+	}
+
+	for _, chat := range chats {
+
+		settings, err := b.chatStore.Settings(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+			continue
+		}
+		quiet := settings.Schedule.Quiet(now)
+		if quiet && settings.Schedule.Hold {
+			continue
+		}
+
+		pending, err := b.pendingStore.Get(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get held alerts from store", "err", err)
+			continue
+		}
+		if len(pending.Alerts) == 0 || now.Sub(pending.Since) < settings.Digest {
+			continue
+		}
+
+		b.sendDigest(chat, pending, quiet)
+
+		if err := b.pendingStore.Remove(chat); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove held alerts from store", "err", err)
+		}
+	}
+}
+
+// sendData renders the alerts for the chat and sends them with their buttons, without a sound if silent.
+// It returns the messages sent.
+func (b *Bot) sendData(chat telebot.Chat, data *vendor.Data, silent bool) []*telebot.Message {
+
+	p := b.printer(&chat, nil)
+	annotated := b.annotate(data)
+
+	out, err := b.tmplData("telegram.default", p, b.location(&chat), annotated)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to template alerts", "err", err)
+		return nil
+	}
+
+	sent := b.sendHTML(chat, out, silent, b.alertsMarkup(p, annotated))
+
+	if b.messageStore == nil {
+		return sent
+	}
+	for _, message := range sent {
+		err := b.messageStore.Add(SentMessage{
+			ChatID:    chat.ID,
+			MessageID: message.ID,
+			SentAt:    message.Time(),
+			Data:      data,
+			Parts:     len(sent),
+		})
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save message to store", "err", err)
 		}
 	}
 
+	return sent
+}
+
+// sendDigest renders the held alerts with the digest template and sends them, without a sound if silent
+func (b *Bot) sendDigest(chat telebot.Chat, pending Pending, silent bool) {
+
+	digest := Digest{
+		Data:  withAlerts(&vendor.Data{}, pending.Alerts),
+		Since: pending.Since,
+	}
+
+	out, err := b.tmplData("telegram.digest", b.printer(&chat, nil), b.location(&chat), digest)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to template digest", "err", err)
+		return
+	}
+
+	b.sendHTML(chat, out, silent, nil)
+}
+
+// sendHTML sends a rendered template to the chat split into messages Telegram accepts,
+// the markup is attached to the last one. It returns the messages sent.
+func (b *Bot) sendHTML(chat telebot.Chat, out string, silent bool, markup *telebot.ReplyMarkup) []*telebot.Message {
+
+	var sent []*telebot.Message
+
+	splits := b.splitMessage(out)
+	for i, splitedMessage := range splits {
+		options := &telebot.SendOptions{
+			ParseMode:           telebot.ModeHTML,
+			DisableNotification: silent,
+		}
+		if i == len(splits)-1 {
+			options.ReplyMarkup = markup
+		}
+
+		message, err := b.telegram.Send(&chat, splitedMessage, options)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send message to subscribed chat", "err", err)
+		} else {
+			level.Debug(b.logger).Log("msg", "send this Telegram", "message", splitedMessage)
+			sent = append(sent, message)
+		}
+	}
+
+	return sent
+}
+
+// withAlerts returns a copy of the data with only the given alerts
+func withAlerts(data *vendor.Data, alerts vendor.Alerts) *vendor.Data {
+
+	d := *data
+	d.Alerts = alerts
+	d.Status = string(model.AlertResolved)
+	if len(alerts.Firing()) > 0 {
+		d.Status = string(model.AlertFiring)
+	}
+
+	return &d
 }
 
 // SendAdminMessage to the admin's ID with a message
@@ -265,21 +794,9 @@ func (b *Bot) HandleCommands(message *telebot.Message) {
 
 	commandSuffix := fmt.Sprintf("@%s", b.telegram.Me.Username)
 
-	commands := map[string]func(message *telebot.Message){
-		commandStart:              b.handleStart,
-		commandStop:               b.handleStop,
-		commandHelp:               b.handleHelp,
-		commandChats:              b.handleChats,
-		commandStatus:             b.handleStatus,
-		commandAlerts:             b.handleAlerts,
-		commandSilences:           b.handleSilences,
-		commandSilence:            b.handleSilence,
-		commandSilenceFor2Hours:   b.handleSilenceTwoHours,
-		commandSilenceFor48Hours:  b.handleSilenceFortyEightHours,
-		commandSilenceFor2Weeks:   b.handleSilenceTwoWeeks,
-		commandServiceMaintenance: b.handleServiceMaintenance,
-		commandFingerprint:        b.handleFingerprint,
-		commandAdmins:             b.handleAdminsList,
+	commands := map[string]botCommand{}
+	for _, command := range b.commands() {
+		commands[command.Name] = command
 	}
 
 	// init counters with 0
@@ -306,47 +823,56 @@ func (b *Bot) HandleCommands(message *telebot.Message) {
 
 	level.Debug(b.logger).Log("msg", "command received", "command", commandName)
 
-	if !b.isAdminID(message.Sender.ID) && !(commandName == "/help" || commandName == "/status" || commandName == "/chats") {
+	p := b.printer(message.Chat, message.Sender)
+
+	// Get the corresponding command from the map by the commands text
+	command, ok := commands[commandName]
+
+	if !b.isAdminID(message.Sender.ID) && !command.Public {
 		b.commandsCounter.WithLabelValues("dropped").Inc()
 		level.Error(b.logger).Log("msg", "dropped message from forbidden sender")
 
 		b.telegram.Reply(
 			message,
-			b.translator.Sprintf("responseNonAdmin", message.Sender.Username, message.Sender.FirstName, message.Sender.LastName),
+			p.Sprintf("responseNonAdmin", message.Sender.Username, message.Sender.FirstName, message.Sender.LastName),
 		)
 
 		return
 	}
 
-	// Get the corresponding handler from the map by the commands text
-	handler, ok := commands[commandName]
+	if !ok && b.incidentNote(message) {
+		return
+	}
 
 	if !ok {
 		b.commandsCounter.WithLabelValues("incomprehensible").Inc()
 		b.telegram.Reply(
 			message,
-			b.translator.Sprintf("responseIncomprehensible"),
+			p.Sprintf("responseIncomprehensible"),
 		)
 		return
 	}
 
-	level.Debug(b.logger).Log("msg", "handler identified", "handler", fmt.Sprint(b.getHandlerName(handler)))
+	level.Debug(b.logger).Log("msg", "handler identified", "handler", fmt.Sprint(b.getHandlerName(command.Handler)))
 
 	b.commandsCounter.WithLabelValues(commandName).Inc()
-	handler(message)
+	command.Handler(message)
 
 }
 
 //
 func (b *Bot) handleStart(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	if err := b.chatStore.Add(*message.Chat); err != nil {
 		level.Warn(b.logger).Log("msg", "failed to add chat to chat store", "err", err)
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseStartFail"))
+		b.telegram.Send(message.Chat, p.Sprintf("responseStartFail"))
 		return
 	}
 
-	b.telegram.Send(message.Chat, b.translator.Sprintf("responseStart", message.Sender.FirstName, commandHelp))
+	b.telegram.Send(message.Chat, p.Sprintf("responseStart", message.Sender.FirstName, commandHelp))
+	b.registerChatCommands(*message.Chat)
 	level.Info(b.logger).Log(
 		"msg", "user subscribed",
 		"username", message.Sender.Username,
@@ -359,13 +885,15 @@ func (b *Bot) handleStart(message *telebot.Message) {
 //
 func (b *Bot) handleStop(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	if err := b.chatStore.Remove(*message.Chat); err != nil {
 		level.Warn(b.logger).Log("msg", "failed to remove chat from chat store", "err", err)
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseStopFail"))
+		b.telegram.Send(message.Chat, p.Sprintf("responseStopFail"))
 		return
 	}
 
-	b.telegram.Send(message.Chat, b.translator.Sprintf("responseStop", message.Sender.FirstName, commandHelp))
+	b.telegram.Send(message.Chat, p.Sprintf("responseStop", message.Sender.FirstName, commandHelp))
 	level.Info(b.logger).Log(
 		"msg", "user unsubscribed",
 		"username", message.Sender.Username,
@@ -377,21 +905,15 @@ func (b *Bot) handleStop(message *telebot.Message) {
 
 //
 func (b *Bot) handleHelp(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	// Underscores of the commands would start italics in Markdown
+	escape := strings.NewReplacer("_", "\\_")
+
 	b.telegram.Send(
 		message.Chat,
-		b.translator.Sprintf("responseHelp",
-			commandStart,
-			commandStop,
-			commandStatus,
-			commandAlerts,
-			commandSilences,
-			commandSilence,
-			commandSilenceFor2Hours,
-			commandSilenceFor48Hours,
-			commandSilenceFor2Weeks,
-			commandServiceMaintenance,
-			commandChats,
-		),
+		escape.Replace(renderHelp(p, b.commands(), b.isAdminID(message.Sender.ID))),
 		&telebot.SendOptions{ParseMode: telebot.ModeMarkdown},
 	)
 	level.Info(b.logger).Log(
@@ -406,10 +928,12 @@ func (b *Bot) handleHelp(message *telebot.Message) {
 //
 func (b *Bot) handleChats(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	chats, err := b.chatStore.List()
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to list chats from chat store", "err", err)
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseChatsFail"))
+		b.telegram.Send(message.Chat, p.Sprintf("responseChatsFail"))
 		return
 	}
 
@@ -427,7 +951,7 @@ func (b *Bot) handleChats(message *telebot.Message) {
 		}
 	}
 
-	b.telegram.Send(message.Chat, b.translator.Sprintf("responseChats", list))
+	b.telegram.Send(message.Chat, p.Sprintf("responseChats", list))
 	level.Info(b.logger).Log(
 		"msg", "user requested chats list",
 		"username", message.Sender.Username,
@@ -440,10 +964,12 @@ func (b *Bot) handleChats(message *telebot.Message) {
 //
 func (b *Bot) handleStatus(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	s, err := alertmanager.Status(b.logger, b.alertmanager.String())
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to get status", "err", err)
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseStatusFail", err))
+		b.telegram.Send(message.Chat, p.Sprintf("responseStatusFail", err))
 		return
 	}
 
@@ -452,7 +978,7 @@ func (b *Bot) handleStatus(message *telebot.Message) {
 
 	b.telegram.Send(
 		message.Chat,
-		b.translator.Sprintf(
+		p.Sprintf(
 			"responseStatus",
 			s.Data.VersionInfo.Version,
 			uptime,
@@ -470,148 +996,29 @@ func (b *Bot) handleStatus(message *telebot.Message) {
 
 }
 
-//
-func (b *Bot) handleAlerts(message *telebot.Message) {
-
-	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
-	if err != nil {
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseAlertsFail", err))
-		level.Error(b.logger).Log("msg", "failed to get alerts", "err", err)
-		return
-	}
-	level.Debug(b.logger).Log("alerts", fmt.Sprint(alerts))
-
-	if len(alerts) == 0 {
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseNoAlerts"))
-		return
-	}
-
-	out, err := b.tmplAlerts(alerts...)
-	if err != nil {
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseAlertsFail", err))
-		level.Error(b.logger).Log("msg", "failed to template alerts", "err", err)
-		return
-	}
-	level.Debug(b.logger).Log("template", fmt.Sprint(out))
-
-	for _, splitedMessage := range b.splitMessage(out) {
-		_, err = b.telegram.Send(message.Chat, splitedMessage, &telebot.SendOptions{
-			ParseMode: telebot.ModeHTML,
-		})
-		if err != nil {
-			level.Warn(b.logger).Log("msg", "failed to send list of alerts", "err", err)
-		}
-	}
-
-}
-
-//
-func (b *Bot) handleSilences(message *telebot.Message) {
-
-	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
-	if err != nil {
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseSilencesFail", err))
-		level.Error(b.logger).Log("msg", "failed to get silences", "err", err)
-		return
-	}
-
-	if len(silences) == 0 {
-		b.telegram.Send(message.Chat, b.translator.Sprintf("responseNoSilences"))
-		return
-	}
-
-	var out string
-	for _, silence := range silences {
-		out = out + alertmanager.SilenceMessage(silence) + "\n"
-	}
-
-	for _, splitedMessage := range b.splitMessage(out) {
-		_, err = b.telegram.Send(message.Chat, splitedMessage, &telebot.SendOptions{
-			ParseMode: telebot.ModeMarkdown})
-		if err != nil {
-			level.Warn(b.logger).Log("msg", "failed to send list of silences", "err", err)
-		}
-	}
-
-}
-
 // TODO intellectual silence
 func (b *Bot) handleSilence(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	b.telegram.Reply(
-		message, b.translator.Sprintf("responseInDev", " 🖕"),
+		message, p.Sprintf("responseInDev", " 🖕"),
 	)
 
 }
 
-// Fast silencing alert for 2 hours
-func (b *Bot) handleSilenceTwoHours(message *telebot.Message) {
-
-	const time = 2 * time.Hour
-
-	fingerPrint := ""
-
-	if strings.Index(message.Text, " ") != -1 {
-		fingerPrint = strings.Split(message.Text, " ")[1]
-		err := b.silence(fingerPrint, time)
-		if err != nil {
-			b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
-			return
-		}
-		b.telegram.Reply(message, b.translator.Sprintf("responseSilenceCreated"))
-	} else {
-		b.telegram.Reply(message, b.translator.Sprintf("responseNoFingerprint"))
-	}
-
-}
-
-// Fast silencing alert for 48 hours
-func (b *Bot) handleSilenceFortyEightHours(message *telebot.Message) {
-
-	const time = 48 * time.Hour
-
-	fingerPrint := ""
-
-	if strings.Index(message.Text, " ") != -1 {
-		fingerPrint = strings.Split(message.Text, " ")[1]
-		err := b.silence(fingerPrint, time)
-		if err != nil {
-			b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
-			return
-		}
-		b.telegram.Reply(message, b.translator.Sprintf("responseSilenceCreated"))
-	} else {
-		b.telegram.Reply(message, b.translator.Sprintf("responseNoFingerprint"))
-	}
-
-}
-
-// Fast silencing alert for 2 weeks
-func (b *Bot) handleSilenceTwoWeeks(message *telebot.Message) {
-
-	const time = 336 * time.Hour
-
-	fingerPrint := ""
-
-	if strings.Index(message.Text, " ") != -1 {
-		fingerPrint = strings.Split(message.Text, " ")[1]
-		err := b.silence(fingerPrint, time)
-		if err != nil {
-			b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
-			return
-		}
-		b.telegram.Reply(message, b.translator.Sprintf("responseSilenceCreated"))
-	} else {
-		b.telegram.Reply(message, b.translator.Sprintf("responseNoFingerprint"))
-	}
-
-}
-
 // Control silencing/expire of ALL alerts for 8 hour (or custom) maintenance
 func (b *Bot) handleServiceMaintenance(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	const defaultTime = 8 * time.Hour
 
+	if !b.hasRole(message.Sender.ID, b.silenceRole) {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceRole", b.silenceRole, commandServiceMaintenance))
+		return
+	}
+
 	if strings.Index(message.Text, " ") != -1 {
 
 		switch strings.Split(message.Text, " ")[1] {
@@ -619,74 +1026,41 @@ func (b *Bot) handleServiceMaintenance(message *telebot.Message) {
 			// Custom DELETE request
 			err := alertmanager.DeleteSuperSilence(b.logger, b.alertmanager.String(), "SUPER_SILENCE")
 			if err != nil {
-				b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
+				b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
 				return
 			}
 			b.telegram.Reply(message, "TEST_SUPERSTOP")
 		default:
-			newTime, err := strconv.Atoi(strings.Split(message.Text, " ")[1])
+			newTime, _ := strconv.Atoi(strings.Split(message.Text, " ")[1])
 			if newTime > 24 || newTime < 1 {
 				newTime = 8
 			}
-			err = b.silenceAll(time.Duration(newTime) * time.Hour)
+			id, err := b.silenceAll(time.Duration(newTime) * time.Hour)
 			if err != nil {
-				b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
+				b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
 				return
 			}
-			b.telegram.Reply(message, b.translator.Sprintf("responseSilenceAllCreated", fmt.Sprint(newTime)))
+			b.watchSilence(id, message.Chat)
+			b.telegram.Reply(message, p.Sprintf("responseSilenceAllCreated", newTime))
 		}
 
 	} else {
-		err := b.silenceAll(defaultTime)
+		id, err := b.silenceAll(defaultTime)
 		if err != nil {
-			b.telegram.Reply(message, b.translator.Sprintf("responseSilenceFail", err))
+			b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
 			return
 		}
-		b.telegram.Reply(message, b.translator.Sprintf("responseSilenceAllCreated", fmt.Sprint(defaultTime.Hours())))
+		b.watchSilence(id, message.Chat)
+		b.telegram.Reply(message, p.Sprintf("responseSilenceAllCreated", int(defaultTime.Hours())))
 	}
 
 }
 
-//
-func (b *Bot) handleFingerprint(message *telebot.Message) {
-
-	fingerPrint := ""
-	count := 0
-
-	if strings.Index(message.Text, " ") != -1 {
-
-		fingerPrint = strings.Split(message.Text, " ")[1]
-
-		alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
-		if err != nil {
-			b.telegram.Send(message.Chat, b.translator.Sprintf("responseAlertsFail", err))
-			level.Error(b.logger).Log("msg", "failed to get alerts", "err", err)
-			return
-		}
-
-		for _, alert := range alerts {
-			if alert.Fingerprint().String() == fingerPrint {
-				count++
-				level.Debug(b.logger).Log("msg", "found alert match", "string", alert.String())
-				b.telegram.Reply(
-					message, b.translator.Sprintf("responseFingerprintFound", alert.String(), alert.Labels.String(), fingerPrint),
-				)
-				break
-			}
-		}
-
-		if count == 0 {
-			b.telegram.Reply(
-				message, b.translator.Sprintf("responseNoFingerprintFound"),
-			)
-		}
-
-	}
-}
-
 // Show current administrators list
 func (b *Bot) handleAdminsList(message *telebot.Message) {
 
+	p := b.printer(message.Chat, message.Sender)
+
 	var (
 		list  = ""
 		count = 0
@@ -707,14 +1081,177 @@ func (b *Bot) handleAdminsList(message *telebot.Message) {
 
 	b.telegram.Reply(
 		message,
-		b.translator.Sprintf("responseAdmins", list),
+		p.Sprintf("responseAdmins", list),
 		&telebot.SendOptions{ParseMode: telebot.ModeMarkdown},
 	)
 
 }
 
+// Show or change the language used in this chat
+func (b *Bot) handleLanguage(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	var available []string
+	for _, tag := range b.catalog.Languages() {
+		available = append(available, tag.String())
+	}
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseLanguageFail"))
+		return
+	}
+
+	if strings.Index(message.Text, " ") == -1 {
+		current := settings.Language
+		if current == "" {
+			current = languageAuto
+		}
+		b.telegram.Reply(message, p.Sprintf("responseLanguage", current, strings.Join(available, ", "), commandLanguage))
+		return
+	}
+
+	lang := strings.Split(message.Text, " ")[1]
+	switch lang {
+	case languageAuto:
+		settings.Language = ""
+	default:
+		settings.Language = ""
+		for _, tag := range available {
+			if strings.EqualFold(tag, lang) {
+				settings.Language = tag
+			}
+		}
+		if settings.Language == "" {
+			b.telegram.Reply(message, p.Sprintf("responseLanguageUnknown", lang, strings.Join(available, ", ")))
+			return
+		}
+	}
+
+	if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseLanguageFail"))
+		return
+	}
+
+	// Reply already in the newly selected language
+	p = b.printer(message.Chat, message.Sender)
+	b.telegram.Reply(message, p.Sprintf("responseLanguageSet", lang))
+	level.Info(b.logger).Log(
+		"msg", "user changed chat language",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"language", lang,
+	)
+
+}
+
+// Show or change the quiet hours and timezone of this chat
+func (b *Bot) handleSchedule(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseScheduleFail"))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		location := settings.Schedule.Location()
+		b.telegram.Reply(message, p.Sprintf(
+			"responseSchedule",
+			location.String(),
+			time.Now().In(location).Format(clockLayout),
+			settings.Schedule.String(),
+			commandSchedule,
+		))
+		return
+	}
+
+	schedule, err := parseSchedule(settings.Schedule, args)
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseScheduleInvalid", err.Error(), commandSchedule))
+		return
+	}
+	settings.Schedule = schedule
+
+	if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseScheduleFail"))
+		return
+	}
+
+	b.telegram.Reply(message, p.Sprintf("responseScheduleSet", schedule.String()))
+	level.Info(b.logger).Log(
+		"msg", "user changed chat schedule",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"schedule", schedule.String(),
+	)
+
+}
+
+// Show or change the digest window of this chat
+func (b *Bot) handleDigest(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseDigestFail"))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		current := "off"
+		if settings.Digest > 0 {
+			current = settings.Digest.String()
+		}
+		b.telegram.Reply(message, p.Sprintf("responseDigest", current, commandDigest))
+		return
+	}
+
+	switch args[0] {
+	case "off":
+		settings.Digest = 0
+	default:
+		window, err := time.ParseDuration(args[0])
+		if err != nil || window < minDigestWindow {
+			b.telegram.Reply(message, p.Sprintf("responseDigestInvalid", args[0], minDigestWindow.String()))
+			return
+		}
+		settings.Digest = window
+	}
+
+	if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseDigestFail"))
+		return
+	}
+
+	if settings.Digest > 0 {
+		b.telegram.Reply(message, p.Sprintf("responseDigestSet", settings.Digest.String()))
+	} else {
+		b.telegram.Reply(message, p.Sprintf("responseDigestOff"))
+	}
+	level.Info(b.logger).Log(
+		"msg", "user changed chat digest window",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"digest", settings.Digest,
+	)
+
+}
+
 // silence is used for making predefined in duration silences.
-func (b *Bot) silence(fingerPrint string, duration time.Duration) error {
+func (b *Bot) silence(fingerPrint string, duration time.Duration, comment string) (string, error) {
 
 	var (
 		silence  *vendor.Silence
@@ -725,16 +1262,21 @@ func (b *Bot) silence(fingerPrint string, duration time.Duration) error {
 	level.Debug(b.logger).Log("fingerprint", fingerPrint)
 	level.Debug(b.logger).Log("duration", duration)
 
+	// Alertmanager rejects silences without a comment
+	if comment == "" {
+		comment = defaultSilenceComment
+	}
+
 	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
 	if err != nil {
 		level.Error(b.logger).Log("msg", "failed to get alerts", "err", err)
-		return err
+		return "", err
 	}
 	level.Debug(b.logger).Log("alerts", fmt.Sprint(alerts))
 
 	if len(alerts) == 0 {
 		level.Error(b.logger).Log("msg", "no alerts found right now")
-		return errors.New("no alerts found right now")
+		return "", errors.New("no alerts found right now")
 	}
 	level.Debug(b.logger).Log("msg", "alerts", "len", len(alerts))
 
@@ -748,7 +1290,7 @@ func (b *Bot) silence(fingerPrint string, duration time.Duration) error {
 			matchers, err = vendor.ParseMatchers(alert.Labels.String())
 			if err != nil {
 				level.Error(b.logger).Log("msg", "failed to parse alert labels into matchers", "err", err)
-				return err
+				return "", err
 			}
 			level.Debug(b.logger).Log("msg", "parsed", "matchers", fmt.Sprint(matchers))
 			// Assemble new silence
@@ -758,12 +1300,16 @@ func (b *Bot) silence(fingerPrint string, duration time.Duration) error {
 				StartsAt:  time.Now(),
 				EndsAt:    time.Now().Add(duration),
 				UpdatedAt: time.Now(),
-				CreatedBy: "alertmanager-bot",
-				Comment:   "Enacted by administrator command",
+				CreatedBy: silenceCreatedBy,
+				Comment:   comment,
 				Status:    vendor.SilenceStatus{State: vendor.CalcSilenceState(time.Now(), time.Now().Add(duration))},
 			}
 			// Custom POST request
-			return alertmanager.PostSilence(b.logger, b.alertmanager.String(), *silence)
+			id, err := alertmanager.PostSilence(b.logger, b.alertmanager.String(), *silence)
+			if err == nil {
+				b.recordIncidentEvent(fingerPrint, IncidentEvent{Kind: incidentSilenced, Text: model.Duration(duration).String() + ": " + comment})
+			}
+			return id, err
 		} else {
 			count++
 			level.Debug(b.logger).Log("msg", "no matches with current alert", "count", count)
@@ -773,14 +1319,14 @@ func (b *Bot) silence(fingerPrint string, duration time.Duration) error {
 
 	if count == len(alerts) {
 		level.Error(b.logger).Log("msg", "no matches found for silence", "count", count)
-		return errors.New("no matches found for silence!")
+		return "", errors.New("no matches found for silence!")
 	}
 
-	return nil
+	return "", nil
 }
 
 // silenceAll is used for making predefined in duration silence for ALL alerts (past, present and future).
-func (b *Bot) silenceAll(duration time.Duration) error {
+func (b *Bot) silenceAll(duration time.Duration) (string, error) {
 
 	var (
 		silence  *vendor.Silence
@@ -797,8 +1343,8 @@ func (b *Bot) silenceAll(duration time.Duration) error {
 		StartsAt:  time.Now(),
 		EndsAt:    time.Now().Add(duration),
 		UpdatedAt: time.Now(),
-		CreatedBy: "alertmanager-bot",
-		Comment:   "Enacted by administrator command",
+		CreatedBy: silenceCreatedBy,
+		Comment:   defaultSilenceComment,
 		Status:    vendor.SilenceStatus{State: vendor.CalcSilenceState(time.Now(), time.Now().Add(duration))},
 	}
 	// Custom POST request
@@ -812,11 +1358,30 @@ func (b *Bot) isAdminID(id int) bool {
 }
 
 // Apply template (Alert -> string)
-func (b *Bot) tmplAlerts(alerts ...*types.Alert) (string, error) {
+func (b *Bot) tmplAlerts(p *translation.Printer, location *time.Location, alerts ...*types.Alert) (string, error) {
 
 	data := b.templates.Data("default", nil, alerts...)
 	level.Debug(b.logger).Log("data", fmt.Sprint(data))
-	out, err := b.templates.ExecuteHTMLString(`{{ template "telegram.default" . }}`, data)
+
+	return b.tmplData("telegram.default", p, location, data)
+}
+
+// Apply the named template (Data -> string) in the language of the given printer and the timezone of the location
+func (b *Bot) tmplData(name string, p *translation.Printer, location *time.Location, data interface{}) (string, error) {
+
+	tmpl, err := b.templates.WithFuncs(vendor.FuncMap{
+		"tr": p.Sprintf,
+		"date": func(layout string, t time.Time) string {
+			return t.In(location).Format(layout)
+		},
+		"oncall": b.onCall,
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to bind chat functions to template", "err", err)
+		return "", err
+	}
+
+	out, err := tmpl.ExecuteHTMLString(fmt.Sprintf(`{{ template %q . }}`, name), data)
 	if err != nil {
 		level.Warn(b.logger).Log("msg", "failed to parse provided template", "err", err)
 		return "", err
@@ -825,6 +1390,62 @@ func (b *Bot) tmplAlerts(alerts ...*types.Alert) (string, error) {
 	return out, nil
 }
 
+// printer returns a message printer in the language explicitly set for the chat,
+// otherwise in the language of the user's Telegram client, otherwise the fallback.
+func (b *Bot) printer(chat *telebot.Chat, user *telebot.User) *translation.Printer {
+
+	var preferred []language.Tag
+
+	settings, err := b.chatStore.Settings(*chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+	}
+	if settings.Language != "" {
+		preferred = append(preferred, language.Make(settings.Language))
+	}
+	if user != nil && user.LanguageCode != "" {
+		preferred = append(preferred, language.Make(user.LanguageCode))
+	}
+
+	return b.catalog.Printer(preferred...)
+}
+
+// prune removes old alert history, messages and notes from the stores, at most once an hour
+func (b *Bot) prune(now time.Time) {
+
+	if now.Sub(b.pruned) < time.Hour {
+		return
+	}
+	b.pruned = now
+
+	if b.historyStore != nil && b.historyRetention > 0 {
+		if err := b.historyStore.Prune(now.Add(-b.historyRetention)); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to prune alert history", "err", err)
+		}
+	}
+	if b.messageStore != nil {
+		if err := b.messageStore.Prune(now.Add(-messageRetention)); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to prune messages", "err", err)
+		}
+	}
+	if b.noteStore != nil && b.historyRetention > 0 {
+		if err := b.noteStore.Prune(now.Add(-b.historyRetention)); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to prune notes", "err", err)
+		}
+	}
+}
+
+// location returns the timezone of the chat's schedule
+func (b *Bot) location(chat *telebot.Chat) *time.Location {
+
+	settings, err := b.chatStore.Settings(*chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+	}
+
+	return settings.Schedule.Location()
+}
+
 // SplitMessage splits string into slice of 4095 bytes strings
 func (b *Bot) splitMessage(str string) []string {
 
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,9 +23,6 @@ import (
 	"github.com/hako/durafmt"
 	"github.com/prometheus/alertmanager/types"
 	"github.com/prometheus/common/model"
-	"golang.org/x/text/language"
-	"golang.org/x/text/message"
-	"golang.org/x/text/message/catalog"
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
 
@@ -46,9 +44,7 @@ func TestHandlers(t *testing.T) {
 	botChat := os.Getenv("ENV_BOT_CHAT")
 
 	dict, _ := translation.ParseYAMLDict("../../en.yaml", logger)
-	fallback := language.MustParse("en")
-	cat, _ := catalog.NewFromMap(dict, catalog.Fallback(fallback))
-	translator := message.NewPrinter(cat.Languages()[0], message.Catalog(cat))
+	cat, _ := translation.NewCatalog(dict, translation.WithLogger(logger))
 
 	var tmpl *vendor.Template
 	funcs := vendor.DefaultFuncs
@@ -58,6 +54,13 @@ func TestHandlers(t *testing.T) {
 	funcs["duration"] = func(start time.Time, end time.Time) string {
 		return durafmt.Parse(end.Sub(start)).String()
 	}
+	funcs["tr"] = fmt.Sprintf
+	funcs["date"] = func(layout string, t time.Time) string {
+		return t.Format(layout)
+	}
+	funcs["oncall"] = func(team string) string {
+		return ""
+	}
 
 	vendor.DefaultFuncs = funcs
 
@@ -160,7 +163,7 @@ func TestHandlers(t *testing.T) {
 		WithStartTime(time.Now()),
 		WithAlertmanager(alertmanagerURL),
 		WithTemplates(tmpl),
-		WithTranslation(translator),
+		WithCatalog(cat),
 		WithExtraAdmins(int(5678), int(9000)),
 		WithChatsToSubscribe(chat),
 	)
@@ -266,53 +269,64 @@ func TestHandlers(t *testing.T) {
 	//  CASE: /fingerprint
 	// ---------------------------------------------------------------------------
 	message.Text = "/fingerprint@" + botUsername + " " + alertA.Fingerprint().String()
-	bot.handleFingerprint(message)
-	t.Log("handleFingerprint() : Test 9 PASSED.")
+	bot.handleAlert(message)
+	t.Log("handleAlert() : Test 9 PASSED.")
 
 	// ---------------------------------------------------------------------------
 	//  CASE: /s2h
 	// ---------------------------------------------------------------------------
 	message.Text = "/s2h@" + botUsername + " " + alertA.Fingerprint().String()
-	bot.handleSilenceTwoHours(message)
-	t.Log("handleSilenceTwoHours() : Test 10.1 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2h"))
+	t.Log("handleSilencePreset() : Test 10.1 PASSED.")
 
 	message.Text = "/s2h@" + botUsername + " " + "nonexistentfingerprint"
-	bot.handleSilenceTwoHours(message)
-	t.Log("handleSilenceTwoHours() : Test 10.2 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2h"))
+	t.Log("handleSilencePreset() : Test 10.2 PASSED.")
 
 	message.Text = "/s2h@" + botUsername // no fingerprint given
-	bot.handleSilenceTwoHours(message)
-	t.Log("handleSilenceTwoHours() : Test 10.3 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2h"))
+	t.Log("handleSilencePreset() : Test 10.3 PASSED.")
 
 	// ---------------------------------------------------------------------------
 	//  CASE: /s48h
 	// ---------------------------------------------------------------------------
 	message.Text = "/s48h@" + botUsername + " " + alertB.Fingerprint().String()
-	bot.handleSilenceFortyEightHours(message)
-	t.Log("handleSilenceFortyEightHours() : Test 11.1 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s48h"))
+	t.Log("handleSilencePreset() : Test 11.1 PASSED.")
 
 	message.Text = "/s48h@" + botUsername + " " + "nonexistentfingerprint"
-	bot.handleSilenceFortyEightHours(message)
-	t.Log("handleSilenceFortyEightHours() : Test 11.2 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s48h"))
+	t.Log("handleSilencePreset() : Test 11.2 PASSED.")
 
 	message.Text = "/s48h@" + botUsername // no fingerprint given
-	bot.handleSilenceFortyEightHours(message)
-	t.Log("handleSilenceFortyEightHours() : Test 11.3 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s48h"))
+	t.Log("handleSilencePreset() : Test 11.3 PASSED.")
 
 	// ---------------------------------------------------------------------------
 	//  CASE: /s2w
 	// ---------------------------------------------------------------------------
 	message.Text = "/s2w@" + botUsername + " " + alertB.Fingerprint().String()
-	bot.handleSilenceTwoWeeks(message)
-	t.Log("handleSilenceTwoWeeks() : Test 12.1 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2w"))
+	t.Log("handleSilencePreset() : Test 12.1 PASSED.")
 
 	message.Text = "/s2w@" + botUsername + " " + "nonexistentfingerprint"
-	bot.handleSilenceTwoWeeks(message)
-	t.Log("handleSilenceTwoWeeks() : Test 12.2 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2w"))
+	t.Log("handleSilencePreset() : Test 12.2 PASSED.")
 
 	message.Text = "/s2w@" + botUsername // no fingerprint given
-	bot.handleSilenceTwoWeeks(message)
-	t.Log("handleSilenceTwoWeeks() : Test 12.3 PASSED.")
+	bot.handleSilencePreset(message, *bot.silencePreset("/s2w"))
+	t.Log("handleSilencePreset() : Test 12.3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: /silence_for
+	// ---------------------------------------------------------------------------
+	message.Text = "/silence_for@" + botUsername + " 90m " + alertA.Fingerprint().String()
+	bot.handleSilenceFor(message)
+	t.Log("handleSilenceFor() : Test 12.4 PASSED.")
+
+	message.Text = "/silence_for@" + botUsername + " 3d"
+	bot.handleSilenceFor(message)
+	t.Log("handleSilenceFor() : Test 12.5 PASSED.")
 
 	// ---------------------------------------------------------------------------
 	//  CASE: /silence
@@ -344,6 +358,21 @@ func TestHandlers(t *testing.T) {
 	bot.handleServiceMaintenance(message)
 	t.Log("handleServiceMaintenance() : Test 14.5 PASSED.")
 
+	// ---------------------------------------------------------------------------
+	//  CASE: /lang
+	// ---------------------------------------------------------------------------
+	message.Text = "/lang@" + botUsername
+	bot.handleLanguage(message)
+	t.Log("handleLanguage() : Test 15.1 PASSED.")
+
+	message.Text = "/lang@" + botUsername + " xx"
+	bot.handleLanguage(message)
+	t.Log("handleLanguage() : Test 15.2 PASSED.")
+
+	message.Text = "/lang@" + botUsername + " auto"
+	bot.handleLanguage(message)
+	t.Log("handleLanguage() : Test 15.3 PASSED.")
+
 	// ---------------------------------------------------------------------------
 	//  CASE: /help & /stop non-admin
 	// ---------------------------------------------------------------------------
@@ -444,6 +473,13 @@ func TestSendWebhooks(t *testing.T) {
 	funcs["duration"] = func(start time.Time, end time.Time) string {
 		return durafmt.Parse(end.Sub(start)).String()
 	}
+	funcs["tr"] = fmt.Sprintf
+	funcs["date"] = func(layout string, t time.Time) string {
+		return t.Format(layout)
+	}
+	funcs["oncall"] = func(team string) string {
+		return ""
+	}
 
 	vendor.DefaultFuncs = funcs
 
@@ -547,3 +583,174 @@ func TestSendWebhooks(t *testing.T) {
 	t.Log("Serve() : Test 1 PASSED.")
 
 }
+
+func TestPrinter(t *testing.T) {
+
+	logger := log.NewNopLogger()
+
+	dict, err := translation.ParseYAMLDict("../../en.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : got error: %s", err)
+	}
+	ru, err := translation.ParseYAMLDict("../../ru.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : got error: %s", err)
+	}
+	dict["ru"] = ru["ru"]
+	cat, err := translation.NewCatalog(dict)
+	if err != nil {
+		t.Fatalf("NewCatalog() : got error: %s", err)
+	}
+
+	kvStore, _ := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "printer"})
+	defer kvStore.Close()
+
+	chatStore, _ := NewChatStore(kvStore)
+
+	bot := &Bot{logger: logger, catalog: cat, chatStore: chatStore}
+
+	chat := &telebot.Chat{ID: int64(4242)}
+	chatStore.SetSettings(*chat, ChatSettings{})
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing is known about the chat, the fallback is used
+	// ---------------------------------------------------------------------------
+	if got := bot.printer(chat, nil).Sprintf("templateDuration"); got != "Duration:" {
+		t.Errorf("printer() : Test 1 FAILED, got: %s", got)
+	} else {
+		t.Log("printer() : Test 1 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: language of the user's Telegram client
+	// ---------------------------------------------------------------------------
+	user := &telebot.User{ID: 1234, LanguageCode: "ru"}
+	if got := bot.printer(chat, user).Sprintf("templateDuration"); got != "Длительность:" {
+		t.Errorf("printer() : Test 2 FAILED, got: %s", got)
+	} else {
+		t.Log("printer() : Test 2 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: explicit chat language wins over the client's one
+	// ---------------------------------------------------------------------------
+	chatStore.SetSettings(*chat, ChatSettings{Language: "en"})
+	if got := bot.printer(chat, user).Sprintf("templateDuration"); got != "Duration:" {
+		t.Errorf("printer() : Test 3 FAILED, got: %s", got)
+	} else {
+		t.Log("printer() : Test 3 PASSED.")
+	}
+}
+
+func TestTmplData(t *testing.T) {
+
+	logger := log.NewNopLogger()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time.LoadLocation() : no timezone database: %s", err)
+	}
+
+	funcs := vendor.DefaultFuncs
+	funcs["since"] = func(t time.Time) string {
+		return durafmt.Parse(time.Since(t)).String()
+	}
+	funcs["duration"] = func(start time.Time, end time.Time) string {
+		return durafmt.Parse(end.Sub(start)).String()
+	}
+	funcs["tr"] = fmt.Sprintf
+	funcs["date"] = func(layout string, t time.Time) string {
+		return t.Format(layout)
+	}
+	funcs["oncall"] = func(team string) string {
+		return ""
+	}
+	vendor.DefaultFuncs = funcs
+
+	tmpl, err := vendor.FromGlobs("../../default.tmpl")
+	if err != nil {
+		t.Fatalf("FromGlobs() : got error: %s", err)
+	}
+
+	dict, err := translation.ParseYAMLDict("../../en.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : got error: %s", err)
+	}
+	cat, err := translation.NewCatalog(dict)
+	if err != nil {
+		t.Fatalf("NewCatalog() : got error: %s", err)
+	}
+
+	bot := &Bot{logger: logger, catalog: cat, templates: tmpl}
+	p := cat.Printer()
+
+	startsAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	alerts := vendor.Alerts{
+		{Status: "firing", Labels: vendor.KV{"alertname": "Firing"}, StartsAt: startsAt},
+		{Status: "resolved", Labels: vendor.KV{"alertname": "Resolved"}, StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: times are rendered in the chat's timezone
+	// ---------------------------------------------------------------------------
+	out, err := bot.tmplData("telegram.default", p, berlin, withAlerts(&vendor.Data{}, alerts))
+	if err != nil || !strings.Contains(out, "Started:</b> 2021-03-01 13:00 CET") {
+		t.Errorf("tmplData() : Test 1 FAILED, got: %s, error: %v", out, err)
+	} else {
+		t.Log("tmplData() : Test 1 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: acknowledged alerts
+	// ---------------------------------------------------------------------------
+	acked := withAlerts(&vendor.Data{}, alerts)
+	acked.Alerts[0].Acked, acked.Alerts[0].AckedBy = true, "@alice"
+	out, err = bot.tmplData("telegram.default", p, time.UTC, acked)
+	if err != nil || !strings.Contains(out, "Acked by</b> @alice") {
+		t.Errorf("tmplData() : Test 4 FAILED, got: %s, error: %v", out, err)
+	} else {
+		t.Log("tmplData() : Test 4 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: notes about the alerts, escaped
+	// ---------------------------------------------------------------------------
+	noted := withAlerts(&vendor.Data{}, alerts)
+	noted.Alerts[0].Notes = []vendor.Note{{By: "@bob", At: startsAt, Text: "restarted <pod>"}}
+	out, err = bot.tmplData("telegram.default", p, time.UTC, noted)
+	if err != nil || !strings.Contains(out, "Last time:</b>\n2021-03-01 @bob: restarted &lt;pod&gt;") {
+		t.Errorf("tmplData() : Test 5 FAILED, got: %s, error: %v", out, err)
+	} else {
+		t.Log("tmplData() : Test 5 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: digest template
+	// ---------------------------------------------------------------------------
+	digest := Digest{Data: withAlerts(&vendor.Data{}, alerts), Since: startsAt}
+	out, err = bot.tmplData("telegram.digest", p, time.UTC, digest)
+	if err != nil ||
+		!strings.Contains(out, "2 alerts since 2021-03-01 12:00 UTC") ||
+		!strings.Contains(out, "🔥 <b>Firing</b>") ||
+		!strings.Contains(out, "✅ <b>Resolved</b>") {
+		t.Errorf("tmplData() : Test 2 FAILED, got: %s, error: %v", out, err)
+	} else {
+		t.Log("tmplData() : Test 2 PASSED.")
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: report template
+	// ---------------------------------------------------------------------------
+	records := []AlertRecord{
+		{Status: "resolved", Labels: vendor.KV{"alertname": "Noisy"}, StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)},
+	}
+	report := newReport(records, startsAt.Add(-time.Hour), startsAt.Add(23*time.Hour))
+	out, err = bot.tmplData("telegram.report", p, time.UTC, report)
+	if err != nil ||
+		!strings.Contains(out, "Fired: 1") ||
+		!strings.Contains(out, "1 × <b>Noisy</b>, Firing time: 1h0m0s, MTTR: 1h0m0s") {
+		t.Errorf("tmplData() : Test 3 FAILED, got: %s, error: %v", out, err)
+	} else {
+		t.Log("tmplData() : Test 3 PASSED.")
+	}
+}
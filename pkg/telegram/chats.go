@@ -3,12 +3,34 @@ package telegram
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/docker/libkv/store"
 	telebot "gopkg.in/tucnak/telebot.v2"
 )
 
-const telegramChatsDirectory = "telegram/chats"
+const (
+	telegramChatsDirectory    = "telegram/chats"
+	telegramSettingsDirectory = "telegram/settings"
+	telegramRemindedDirectory = "telegram/reminded"
+	telegramReportsDirectory  = "telegram/reports"
+)
+
+// ChatSettings holds the per-chat preferences stored next to the subscription
+type ChatSettings struct {
+	// Language is the explicitly chosen language, empty means auto-detect
+	Language string `json:"language,omitempty"`
+	// Schedule of the quiet hours and the timezone alerts are rendered in
+	Schedule *Schedule `json:"schedule,omitempty"`
+	// Digest is the window alerts are batched for, zero sends them right away
+	Digest time.Duration `json:"digest,omitempty"`
+	// Reports scheduled to be sent to the chat
+	Reports []ReportSchedule `json:"reports,omitempty"`
+	// Reminders of the alerts that keep firing, the first matching rule applies
+	Reminders []ReminderRule `json:"reminders,omitempty"`
+	// SilenceEvents tells the chat about the silences created, updated or expired outside the bot
+	SilenceEvents bool `json:"silenceEvents,omitempty"`
+}
 
 // ChatStore writes the users to a libkv store backend
 type ChatStore struct {
@@ -56,3 +78,99 @@ func (s *ChatStore) Remove(c telebot.Chat) error {
 	key := fmt.Sprintf("%s/%d", telegramChatsDirectory, c.ID)
 	return s.kv.Delete(key)
 }
+
+// Settings returns the settings of a telegram chat, zero value if none were saved
+func (s *ChatStore) Settings(c telebot.Chat) (ChatSettings, error) {
+	var settings ChatSettings
+
+	key := fmt.Sprintf("%s/%d", telegramSettingsDirectory, c.ID)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return settings, nil
+	}
+	if err != nil {
+		return settings, err
+	}
+
+	err = json.Unmarshal(kv.Value, &settings)
+
+	return settings, err
+}
+
+// SetSettings saves the settings of a telegram chat to the kv backend
+func (s *ChatStore) SetSettings(c telebot.Chat, settings ChatSettings) error {
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d", telegramSettingsDirectory, c.ID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Reminded returns when the chat was last reminded of each firing alert matching its rules,
+// or when the bot got it if not yet, by fingerprint. It's kept apart from the settings
+// the commands change, the reminders update it on their own.
+func (s *ChatStore) Reminded(c telebot.Chat) (map[string]time.Time, error) {
+	reminded := map[string]time.Time{}
+
+	key := fmt.Sprintf("%s/%d", telegramRemindedDirectory, c.ID)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return reminded, nil
+	}
+	if err != nil {
+		return reminded, err
+	}
+
+	err = json.Unmarshal(kv.Value, &reminded)
+
+	return reminded, err
+}
+
+// SetReminded saves when the chat was last reminded of the alerts to the kv backend
+func (s *ChatStore) SetReminded(c telebot.Chat, reminded map[string]time.Time) error {
+	b, err := json.Marshal(reminded)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d", telegramRemindedDirectory, c.ID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// ReportsSent returns when the scheduled reports of the chat were sent last time, by ReportSchedule.Key.
+// It's kept apart from the settings the commands change, the reports update it on their own.
+func (s *ChatStore) ReportsSent(c telebot.Chat) (map[string]time.Time, error) {
+	sent := map[string]time.Time{}
+
+	key := fmt.Sprintf("%s/%d", telegramReportsDirectory, c.ID)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return sent, nil
+	}
+	if err != nil {
+		return sent, err
+	}
+
+	err = json.Unmarshal(kv.Value, &sent)
+
+	return sent, err
+}
+
+// SetReportsSent saves when the scheduled reports of the chat were sent to the kv backend
+func (s *ChatStore) SetReportsSent(c telebot.Chat, sent map[string]time.Time) error {
+	b, err := json.Marshal(sent)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d", telegramReportsDirectory, c.ID)
+
+	return s.kv.Put(key, b, nil)
+}
@@ -7,7 +7,7 @@ import (
 	// "net/url"
 	// "os"
 	"testing"
-	// "time"
+	"time"
 
 	"github.com/docker/libkv/store"
 	"github.com/docker/libkv/store/boltdb"
@@ -56,6 +56,46 @@ func TestChats(t *testing.T) {
 		t.Log("Remove() : Test 1 PASSED.")
 	}
 
+	settings, err := s.Settings(telebot.Chat{ID: time.Now().UnixNano()})
+	if err != nil || settings.Language != "" {
+		t.Errorf("Settings() : Test 1 FAILED, got error: %s", err)
+	} else {
+		t.Log("Settings() : Test 1 PASSED.")
+	}
+
+	err = s.SetSettings(telebot.Chat{ID: int64(2222)}, ChatSettings{Language: "ru"})
+	if err != nil {
+		t.Errorf("SetSettings() : Test 1 FAILED, got error: %s", err)
+	} else {
+		t.Log("SetSettings() : Test 1 PASSED.")
+	}
+
+	settings, err = s.Settings(telebot.Chat{ID: int64(2222)})
+	if err != nil || settings.Language != "ru" {
+		t.Errorf("Settings() : Test 2 FAILED, got: %v, error: %s", settings, err)
+	} else {
+		t.Log("Settings() : Test 2 PASSED.")
+	}
+
+	at := time.Now().Truncate(time.Second)
+	err = s.SetReminded(telebot.Chat{ID: int64(2222)}, map[string]time.Time{"a": at})
+	reminded, _ := s.Reminded(telebot.Chat{ID: int64(2222)})
+	settings, _ = s.Settings(telebot.Chat{ID: int64(2222)})
+	if err != nil || !reminded["a"].Equal(at) || settings.Language != "ru" {
+		t.Errorf("SetReminded() : Test 1 FAILED, got: %v, %v, error: %s", reminded, settings, err)
+	} else {
+		t.Log("SetReminded() : Test 1 PASSED.")
+	}
+
+	err = s.SetReportsSent(telebot.Chat{ID: int64(2222)}, map[string]time.Time{"24h0m0s @daily": at})
+	sent, _ := s.ReportsSent(telebot.Chat{ID: int64(2222)})
+	settings, _ = s.Settings(telebot.Chat{ID: int64(2222)})
+	if err != nil || !sent["24h0m0s @daily"].Equal(at) || settings.Language != "ru" {
+		t.Errorf("SetReportsSent() : Test 1 FAILED, got: %v, %v, error: %s", sent, settings, err)
+	} else {
+		t.Log("SetReportsSent() : Test 1 PASSED.")
+	}
+
 	_, err = s.List()
 	if err != nil && err.Error() == "Key not found in store" {
 		t.Log("List() : Test 1 PASSED")
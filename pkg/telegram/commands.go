@@ -0,0 +1,217 @@
+package telegram
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/go-kit/kit/log/level"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// maxCommandDescription is how long the description of a command in a menu may be
+const maxCommandDescription = 256
+
+// menuCommandPattern is what Telegram accepts as a command of a menu, without the slash
+var menuCommandPattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// botCommand is a command of the bot. The commands HandleCommands dispatches on, /help and
+// the command menus of Telegram are all taken from the same list of them.
+type botCommand struct {
+	Name    string
+	Handler func(message *telebot.Message)
+	// Public commands can be used by everybody, the others by the admins only
+	Public bool
+	// Hidden commands like aliases are left out of /help and the menus
+	Hidden bool
+	// Description is the translation key of the description, formatted with the args
+	Description string
+	args        []interface{}
+}
+
+// describe returns the description of the command in the language of the printer
+func (c botCommand) describe(p *translation.Printer) string {
+	return p.Sprintf(c.Description, c.args...)
+}
+
+// builtinCommands returns the commands of the bot in the order of /help, without the silence presets
+func (b *Bot) builtinCommands() []botCommand {
+	return []botCommand{
+		{Name: commandStart, Handler: b.handleStart, Description: "commandStart"},
+		{Name: commandStop, Handler: b.handleStop, Description: "commandStop"},
+		{Name: commandHelp, Handler: b.handleHelp, Public: true, Description: "commandHelp"},
+		{Name: commandStatus, Handler: b.handleStatus, Public: true, Description: "commandStatus"},
+		{Name: commandAlerts, Handler: b.handleAlerts, Description: "commandAlerts"},
+		{Name: commandAlert, Handler: b.handleAlert, Description: "commandAlert"},
+		{Name: commandFingerprint, Handler: b.handleAlert, Hidden: true},
+		{Name: commandSilences, Handler: b.handleSilences, Description: "commandSilences"},
+		{Name: commandSilence, Handler: b.handleSilence, Description: "commandSilence"},
+		{Name: commandSilenceFor, Handler: b.handleSilenceFor, Description: "commandSilenceFor"},
+		{Name: commandServiceMaintenance, Handler: b.handleServiceMaintenance, Description: "commandServiceMaintenance"},
+		{Name: commandChats, Handler: b.handleChats, Public: true, Description: "commandChats"},
+		{Name: commandAdmins, Handler: b.handleAdminsList, Description: "commandAdmins"},
+		{Name: commandLanguage, Handler: b.handleLanguage, Description: "commandLanguage"},
+		{Name: commandSchedule, Handler: b.handleSchedule, Description: "commandSchedule"},
+		{Name: commandDigest, Handler: b.handleDigest, Description: "commandDigest"},
+		{Name: commandReport, Handler: b.handleReport, Description: "commandReport"},
+		{Name: commandHistory, Handler: b.handleHistory, Description: "commandHistory"},
+		{Name: commandTop, Handler: b.handleTop, Description: "commandTop"},
+		{Name: commandAck, Handler: b.handleAck, Description: "commandAck"},
+		{Name: commandOnCall, Handler: b.handleOnCall, Description: "commandOnCall"},
+		{Name: commandRemind, Handler: b.handleRemind, Description: "commandRemind"},
+		{Name: commandIncident, Handler: b.handleIncident, Description: "commandIncident"},
+		{Name: commandNote, Handler: b.handleNote, Description: "commandNote"},
+		{Name: commandGraph, Handler: b.handleGraph, Description: "commandGraph"},
+		{Name: commandQuery, Handler: b.handleQuery, Description: "commandQuery"},
+		{Name: commandQueryRange, Handler: b.handleQueryRange, Description: "commandQueryRange"},
+		{Name: commandSilenceEvents, Handler: b.handleSilenceEvents, Description: "commandSilenceEvents"},
+	}
+}
+
+// commands returns all commands of the bot, the silence presets follow /silence
+func (b *Bot) commands() []botCommand {
+
+	var commands []botCommand
+	for _, c := range b.builtinCommands() {
+		commands = append(commands, c)
+		if c.Name != commandSilence {
+			continue
+		}
+		for _, preset := range b.silencePresets {
+			preset := preset
+			commands = append(commands, botCommand{
+				Name:        preset.Command,
+				Handler:     func(message *telebot.Message) { b.handleSilencePreset(message, preset) },
+				Description: "commandSilencePreset",
+				args:        []interface{}{preset.Duration.String()},
+			})
+		}
+	}
+
+	return commands
+}
+
+// renderHelp returns the lines of /help of the commands, the admins get all of them
+func renderHelp(p *translation.Printer, commands []botCommand, admin bool) string {
+
+	lines := []string{strings.TrimSuffix(p.Sprintf("responseHelp"), "\n")}
+	for _, c := range commands {
+		if c.Hidden || !c.Public && !admin {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s - %s", c.Name, c.describe(p)))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// commandMenu returns the menu of the commands Telegram shows, of the public ones unless for the admins
+func commandMenu(p *translation.Printer, commands []botCommand, admin bool) []telebot.Command {
+
+	var menu []telebot.Command
+	for _, c := range commands {
+		name := strings.TrimPrefix(c.Name, "/")
+		if c.Hidden || !c.Public && !admin || !menuCommandPattern.MatchString(name) {
+			continue
+		}
+		description := []rune(c.describe(p))
+		if len(description) > maxCommandDescription {
+			description = append(description[:maxCommandDescription-1], '…')
+		}
+		menu = append(menu, telebot.Command{Text: name, Description: string(description)})
+	}
+
+	return menu
+}
+
+// commandScope is a BotCommandScope of the Bot API, who a command menu is shown to
+type commandScope struct {
+	Type   string `json:"type"`
+	ChatID int64  `json:"chat_id,omitempty"`
+	UserID int    `json:"user_id,omitempty"`
+}
+
+// commandScopes returns the scopes of the menus and whether they are the admins' ones: everybody in
+// private and in group chats, the admins in their private chats and in the group chats subscribed
+func (b *Bot) commandScopes(chats []telebot.Chat) map[commandScope]bool {
+
+	scopes := map[commandScope]bool{
+		{Type: "all_private_chats"}: false,
+		{Type: "all_group_chats"}:   false,
+	}
+	for _, admin := range b.admins {
+		scopes[commandScope{Type: "chat", ChatID: int64(admin)}] = true
+		for _, chat := range chats {
+			if chat.Type == telebot.ChatGroup || chat.Type == telebot.ChatSuperGroup {
+				scopes[commandScope{Type: "chat_member", ChatID: chat.ID, UserID: admin}] = true
+			}
+		}
+	}
+
+	return scopes
+}
+
+// setCommands sets the menu of the scope in the language, the default one if empty
+func (b *Bot) setCommands(scope commandScope, lang string, menu []telebot.Command) error {
+
+	_, err := b.telegram.Raw("setMyCommands", struct {
+		Commands     []telebot.Command `json:"commands"`
+		Scope        commandScope      `json:"scope"`
+		LanguageCode string            `json:"language_code,omitempty"`
+	}{menu, scope, lang})
+
+	return err
+}
+
+// registerCommands sets the menus of the scopes in every language having a dictionary
+func (b *Bot) registerCommands(scopes map[commandScope]bool) {
+
+	commands := b.commands()
+
+	// The menu without a language is shown to the users whose language has none
+	languages := map[string]*translation.Printer{"": b.catalog.Printer()}
+	for _, tag := range b.catalog.Languages() {
+		base, _ := tag.Base()
+		languages[base.String()] = b.catalog.Printer(tag)
+	}
+
+	for scope, admin := range scopes {
+		for lang, p := range languages {
+			// The admins may not be members of every chat subscribed
+			if err := b.setCommands(scope, lang, commandMenu(p, commands, admin)); err != nil {
+				level.Debug(b.logger).Log("msg", "failed to set the commands", "scope", scope.Type, "chat", scope.ChatID, "lang", lang, "err", err)
+			}
+		}
+	}
+}
+
+// RegisterCommands sets the menus of the commands Telegram shows for everybody and for the admins,
+// in every language having a dictionary
+func (b *Bot) RegisterCommands() {
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+	}
+
+	b.registerCommands(b.commandScopes(chats))
+	level.Info(b.logger).Log("msg", "registered the commands", "languages", fmt.Sprint(b.catalog.Languages()))
+}
+
+// registerChatCommands sets the menus of the admins in a group chat subscribed
+func (b *Bot) registerChatCommands(chat telebot.Chat) {
+
+	if chat.Type != telebot.ChatGroup && chat.Type != telebot.ChatSuperGroup {
+		return
+	}
+
+	scopes := map[commandScope]bool{}
+	for scope, admin := range b.commandScopes([]telebot.Chat{chat}) {
+		if scope.Type == "chat_member" {
+			scopes[scope] = admin
+		}
+	}
+	b.registerCommands(scopes)
+}
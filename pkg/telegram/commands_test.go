@@ -0,0 +1,178 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestCommandRegistry(t *testing.T) {
+
+	logger := log.NewNopLogger()
+
+	en, err := translation.ParseYAMLDict("../../en.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : got error: %s", err)
+	}
+	ru, err := translation.ParseYAMLDict("../../ru.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : got error: %s", err)
+	}
+
+	bot := &Bot{logger: logger, silencePresets: defaultSilencePresets}
+	commands := bot.commands()
+
+	// ---------------------------------------------------------------------------
+	//  CASE: every command once, the presets after /silence
+	// ---------------------------------------------------------------------------
+	seen := map[string]bool{}
+	var names []string
+	for _, c := range commands {
+		assert.False(t, seen[c.Name], c.Name)
+		assert.NotNil(t, c.Handler, c.Name)
+		seen[c.Name] = true
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, len(bot.builtinCommands())+len(defaultSilencePresets), len(commands))
+	assert.Equal(t, []string{commandSilence, "/s2h", "/s48h", "/s2w", commandSilenceFor}, names[8:13])
+	t.Log("commands() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: every command shown is described in every language
+	// ---------------------------------------------------------------------------
+	for _, c := range commands {
+		if c.Hidden {
+			continue
+		}
+		assert.Contains(t, en["en"], c.Description, c.Name)
+		assert.Contains(t, ru["ru"], c.Description, c.Name)
+	}
+	t.Log("commands() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only /help, /status and /chats are public
+	// ---------------------------------------------------------------------------
+	var public []string
+	for _, c := range commands {
+		if c.Public {
+			public = append(public, c.Name)
+		}
+	}
+	assert.Equal(t, []string{commandHelp, commandStatus, commandChats}, public)
+	t.Log("commands() : Test 3 PASSED.")
+
+}
+
+func TestCommandHelp(t *testing.T) {
+
+	cat, err := translation.NewCatalog(map[string]translation.Dictionary{"en": {
+		"responseHelp":         {Text: "Available commands:\n"},
+		"commandHelp":          {Text: "Show the commands."},
+		"commandStatus":        {Text: "Print the status."},
+		"commandAlerts":        {Text: "List the alerts."},
+		"commandSilencePreset": {Text: "Silence for %s."},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := cat.Printer()
+
+	commands := []botCommand{
+		{Name: commandHelp, Public: true, Description: "commandHelp"},
+		{Name: commandStatus, Public: true, Description: "commandStatus"},
+		{Name: commandAlerts, Description: "commandAlerts"},
+		{Name: commandFingerprint, Hidden: true},
+		{Name: "/s2h", Description: "commandSilencePreset", args: []interface{}{"2h"}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the admins get every command but the hidden ones
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, strings.Join([]string{
+		"Available commands:",
+		"/help - Show the commands.",
+		"/status - Print the status.",
+		"/alerts - List the alerts.",
+		"/s2h - Silence for 2h.",
+	}, "\n"), renderHelp(p, commands, true))
+	t.Log("renderHelp() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the others get the public commands
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "Available commands:\n/help - Show the commands.\n/status - Print the status.", renderHelp(p, commands, false))
+	t.Log("renderHelp() : Test 2 PASSED.")
+
+}
+
+func TestCommandMenu(t *testing.T) {
+
+	cat, err := translation.NewCatalog(map[string]translation.Dictionary{
+		"en": {
+			"commandHelp":          {Text: "Show the commands."},
+			"commandAlerts":        {Text: strings.Repeat("x", 300)},
+			"commandSilencePreset": {Text: "Silence for %s."},
+		},
+		"ru": {
+			"commandHelp": {Text: "Показать команды."},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	commands := []botCommand{
+		{Name: commandHelp, Public: true, Description: "commandHelp"},
+		{Name: commandAlerts, Description: "commandAlerts"},
+		{Name: commandFingerprint, Hidden: true},
+		{Name: "/s2h", Description: "commandSilencePreset", args: []interface{}{model.Duration(2 * time.Hour).String()}},
+		{Name: "/S2H", Description: "commandSilencePreset", args: []interface{}{"2h"}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the commands Telegram accepts, described in the language
+	// ---------------------------------------------------------------------------
+	menu := commandMenu(cat.Printer(), commands, true)
+	if assert.Len(t, menu, 3) {
+		assert.Equal(t, telebot.Command{Text: "help", Description: "Show the commands."}, menu[0])
+		assert.Equal(t, "alerts", menu[1].Text)
+		assert.Len(t, []rune(menu[1].Description), maxCommandDescription)
+		assert.Equal(t, telebot.Command{Text: "s2h", Description: "Silence for 2h."}, menu[2])
+	}
+	assert.Equal(t, []telebot.Command{{Text: "help", Description: "Показать команды."}},
+		commandMenu(cat.Printer(cat.Languages()[1]), commands, false))
+	t.Log("commandMenu() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: everybody in private and group chats, the admins in their chats and the groups subscribed
+	// ---------------------------------------------------------------------------
+	bot := &Bot{admins: []int{1, 2}}
+	scopes := bot.commandScopes([]telebot.Chat{
+		{ID: -100, Type: telebot.ChatSuperGroup},
+		{ID: -200, Type: telebot.ChatGroup},
+		{ID: 3, Type: telebot.ChatPrivate},
+	})
+	assert.Equal(t, map[commandScope]bool{
+		{Type: "all_private_chats"}:                    false,
+		{Type: "all_group_chats"}:                      false,
+		{Type: "chat", ChatID: 1}:                      true,
+		{Type: "chat", ChatID: 2}:                      true,
+		{Type: "chat_member", ChatID: -100, UserID: 1}: true,
+		{Type: "chat_member", ChatID: -100, UserID: 2}: true,
+		{Type: "chat_member", ChatID: -200, UserID: 1}: true,
+		{Type: "chat_member", ChatID: -200, UserID: 2}: true,
+	}, scopes)
+	t.Log("commandScopes() : Test 2 PASSED.")
+
+}
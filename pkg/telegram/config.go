@@ -0,0 +1,63 @@
+package telegram
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config of the bot's features too structured for flags
+type Config struct {
+	Escalations []EscalationPolicy `yaml:"escalations"`
+	// Silences replace the default silence presets
+	Silences []SilencePreset `yaml:"silences"`
+	// Roles are the user IDs by role name, silence presets can require one
+	Roles map[string][]int `yaml:"roles"`
+	// QueryRole is the role needed to query Prometheus, any admin can if empty
+	QueryRole string `yaml:"query_role"`
+	// SilenceRole is the role needed to silence for any duration, any admin can if empty
+	SilenceRole string `yaml:"silence_role"`
+}
+
+// LoadConfig reads the config from a YAML file and validates it
+func LoadConfig(filename string) (Config, error) {
+
+	var config Config
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return config, fmt.Errorf("err reading config file: %s", err.Error())
+	}
+
+	if err := yaml.UnmarshalStrict(b, &config); err != nil {
+		return config, fmt.Errorf("err unmarshaling config file: %s", err.Error())
+	}
+
+	for i := range config.Escalations {
+		if err := config.Escalations[i].compile(); err != nil {
+			return config, fmt.Errorf("invalid escalation policy %q: %s", config.Escalations[i].Name, err.Error())
+		}
+	}
+
+	commands := map[string]bool{}
+	for i := range config.Silences {
+		preset := &config.Silences[i]
+		if err := preset.compile(config.Roles); err != nil {
+			return config, fmt.Errorf("invalid silence preset %q: %s", preset.Command, err.Error())
+		}
+		if commands[preset.Command] {
+			return config, fmt.Errorf("duplicate silence preset %q", preset.Command)
+		}
+		commands[preset.Command] = true
+	}
+
+	if _, ok := config.Roles[config.QueryRole]; config.QueryRole != "" && !ok {
+		return config, fmt.Errorf("unknown query role %q", config.QueryRole)
+	}
+	if _, ok := config.Roles[config.SilenceRole]; config.SilenceRole != "" && !ok {
+		return config, fmt.Errorf("unknown silence role %q", config.SilenceRole)
+	}
+
+	return config, nil
+}
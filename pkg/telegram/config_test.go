@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLoadConfig(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: example config
+	// ---------------------------------------------------------------------------
+	config, err := LoadConfig("../../examples/config/config.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfig() : Test 1 FAILED, got error: %s", err)
+	}
+	if assert.Len(t, config.Escalations, 2) {
+		assert.Equal(t, 20*time.Minute, config.Escalations[0].Steps[1].After)
+		assert.Len(t, config.Escalations[1].matchers, 2)
+	}
+	if assert.Len(t, config.Silences, 3) {
+		assert.Equal(t, 24*time.Hour, time.Duration(config.Silences[1].Duration))
+		assert.Equal(t, defaultSilenceComment, config.Silences[0].Comment)
+		assert.Equal(t, "leads", config.Silences[2].Role)
+	}
+	assert.Equal(t, "leads", config.QueryRole)
+	assert.Equal(t, "leads", config.SilenceRole)
+	t.Log("LoadConfig() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid configs
+	// ---------------------------------------------------------------------------
+	for i, content := range []string{
+		"escalations: [{name: a, steps: [{after: 1m, renotify: true}], matchers: ['severity']}]",
+		"escalations: [{name: a, steps: [{after: 1m}]}]",
+		"escalations: [{name: a}]",
+		"escalations: [{steps: [{after: 1m, renotify: true}]}]",
+		"unknown: true",
+		"silences: [{command: s2h, duration: 2h}]",
+		"silences: [{command: /s2h}]",
+		"silences: [{command: /s2h, duration: 2h, role: leads}]",
+		"silences: [{command: /s2h, duration: 2h}, {command: /s2h, duration: 4h}]",
+		"query_role: leads",
+		"silence_role: leads",
+	} {
+		f, err := ioutil.TempFile("", "config*.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		f.WriteString(content)
+		f.Close()
+
+		if _, err := LoadConfig(f.Name()); err == nil {
+			t.Errorf("LoadConfig() : Test %d FAILED, expected an error for %q", i+2, content)
+		} else {
+			t.Logf("LoadConfig() : Test %d PASSED.", i+2)
+		}
+	}
+
+	_, err = LoadConfig("../../examples/config/nonexistent.yaml")
+	assert.Error(t, err)
+
+}
@@ -0,0 +1,303 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/types"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// errAlertNotFound is returned when Alertmanager doesn't know the alert of a fingerprint
+var errAlertNotFound = errors.New("alert not found")
+
+// alertDetailsButton is sent with every firing alert to show its details
+var alertDetailsButton = telebot.InlineButton{Unique: "alert_details"}
+
+// alertDetails is everything known about an alert, shown by /alert
+type alertDetails struct {
+	Alert *types.Alert
+	// Silences are the active silences matching the alert
+	Silences  []vendor.Silence
+	Inhibited bool
+	// Notified are the chats the bot last sent the alert to
+	Notified []notification
+	// Notes are what was noted about the alert, the oldest first
+	Notes []AlertNote
+}
+
+// notification is the last time the bot sent an alert to a chat
+type notification struct {
+	Chat string
+	At   time.Time
+}
+
+// chatTitle returns how a chat is shown to users
+func chatTitle(chat telebot.Chat) string {
+	switch {
+	case chat.Title != "":
+		return chat.Title
+	case chat.Username != "":
+		return "@" + chat.Username
+	case chat.FirstName != "":
+		return strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+	}
+	return strconv.FormatInt(chat.ID, 10)
+}
+
+// lastNotifications returns when the messages were last sent to each chat, the most recent first
+func lastNotifications(messages []SentMessage, chats []telebot.Chat) []notification {
+
+	last := map[int64]time.Time{}
+	for _, m := range messages {
+		if m.SentAt.After(last[m.ChatID]) {
+			last[m.ChatID] = m.SentAt
+		}
+	}
+
+	titles := map[int64]string{}
+	for _, chat := range chats {
+		titles[chat.ID] = chatTitle(chat)
+	}
+
+	var notified []notification
+	for id, at := range last {
+		title, ok := titles[id]
+		if !ok {
+			title = strconv.FormatInt(id, 10)
+		}
+		notified = append(notified, notification{Chat: title, At: at})
+	}
+	sort.Slice(notified, func(i, j int) bool { return notified[i].At.After(notified[j].At) })
+
+	return notified
+}
+
+// isURL returns whether an annotation is a link, like a runbook or a dashboard
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// renderAlertDetails renders the details of an alert as HTML
+func renderAlertDetails(p *translation.Printer, location *time.Location, d alertDetails, now time.Time) string {
+
+	const timeFormat = "2006-01-02 15:04 MST"
+
+	alert := d.Alert
+	var out strings.Builder
+
+	status := "🔥"
+	if alert.Resolved() {
+		status = "✅"
+	}
+	fmt.Fprintf(&out, "%s <b>%s</b>\n", status, html.EscapeString(string(alert.Name())))
+	fmt.Fprintf(&out, "%s\n", p.Sprintf("responseAlertDetailsStarted",
+		alert.StartsAt.In(location).Format(timeFormat), now.Sub(alert.StartsAt).Round(time.Minute).String()))
+	fmt.Fprintf(&out, "%s <code>%s</code>\n", p.Sprintf("responseAlertDetailsFingerprint"), alert.Fingerprint().String())
+
+	names := func(m map[string]string) []string {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+
+	labels := map[string]string{}
+	for k, v := range alert.Labels {
+		labels[string(k)] = string(v)
+	}
+	fmt.Fprintf(&out, "\n<b>%s</b>\n", p.Sprintf("responseAlertDetailsLabels"))
+	for _, k := range names(labels) {
+		fmt.Fprintf(&out, "%s: <code>%s</code>\n", html.EscapeString(k), html.EscapeString(labels[k]))
+	}
+
+	annotations, links := map[string]string{}, map[string]string{}
+	for k, v := range alert.Annotations {
+		if isURL(string(v)) {
+			links[string(k)] = string(v)
+		} else {
+			annotations[string(k)] = string(v)
+		}
+	}
+	if len(annotations) > 0 {
+		fmt.Fprintf(&out, "\n<b>%s</b>\n", p.Sprintf("responseAlertDetailsAnnotations"))
+		for _, k := range names(annotations) {
+			fmt.Fprintf(&out, "%s: %s\n", html.EscapeString(k), html.EscapeString(annotations[k]))
+		}
+	}
+
+	if alert.GeneratorURL != "" || len(links) > 0 {
+		fmt.Fprintf(&out, "\n<b>%s</b>\n", p.Sprintf("responseAlertDetailsLinks"))
+		if alert.GeneratorURL != "" {
+			fmt.Fprintf(&out, "<a href=\"%s\">%s</a>\n", html.EscapeString(alert.GeneratorURL), p.Sprintf("responseAlertDetailsSource"))
+		}
+		for _, k := range names(links) {
+			fmt.Fprintf(&out, "<a href=\"%s\">%s</a>\n", html.EscapeString(links[k]), html.EscapeString(k))
+		}
+	}
+
+	out.WriteString("\n")
+	if len(d.Silences) == 0 {
+		fmt.Fprintf(&out, "%s\n", p.Sprintf("responseAlertDetailsNotSilenced"))
+	} else {
+		fmt.Fprintf(&out, "<b>%s</b>\n", p.Sprintf("responseAlertDetailsSilencedBy"))
+		for _, s := range d.Silences {
+			fmt.Fprintf(&out, "%s\n", p.Sprintf("responseAlertDetailsSilence",
+				html.EscapeString(s.CreatedBy), s.EndsAt.In(location).Format(timeFormat), html.EscapeString(s.Comment)))
+		}
+	}
+	if d.Inhibited {
+		fmt.Fprintf(&out, "%s\n", p.Sprintf("responseAlertDetailsInhibited"))
+	}
+
+	if len(d.Notes) > 0 {
+		fmt.Fprintf(&out, "\n<b>%s</b>\n", p.Sprintf("responseAlertDetailsNotes"))
+		for _, n := range d.Notes {
+			fmt.Fprintf(&out, "%s %s: %s\n", n.At.In(location).Format(timeFormat), html.EscapeString(n.By), html.EscapeString(n.Text))
+		}
+	}
+
+	out.WriteString("\n")
+	if len(d.Notified) == 0 {
+		fmt.Fprintf(&out, "%s\n", p.Sprintf("responseAlertDetailsNotNotified"))
+	} else {
+		fmt.Fprintf(&out, "<b>%s</b>\n", p.Sprintf("responseAlertDetailsNotified"))
+		for _, n := range d.Notified {
+			fmt.Fprintf(&out, "%s: %s\n", html.EscapeString(n.Chat), n.At.In(location).Format(timeFormat))
+		}
+	}
+
+	return strings.TrimSuffix(out.String(), "\n")
+}
+
+// alertDetails gathers the details of the alert of the fingerprint from Alertmanager and the stores
+func (b *Bot) alertDetails(fingerprint string) (alertDetails, error) {
+
+	var d alertDetails
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		return d, err
+	}
+	for _, alert := range alerts {
+		if alert.Fingerprint().String() == fingerprint {
+			d.Alert = alert
+			break
+		}
+	}
+	if d.Alert == nil {
+		return d, errAlertNotFound
+	}
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		return d, err
+	}
+	for _, s := range silences {
+		if s.Status.State == vendor.SilenceStateActive && s.Matchers.Matches(d.Alert.Labels) {
+			d.Silences = append(d.Silences, s)
+		}
+	}
+
+	// Alertmanager leaves the inhibited alerts out when asked to
+	uninhibited, err := alertmanager.QueryAlerts(b.logger, b.alertmanager.String(), true, false)
+	if err != nil {
+		return d, err
+	}
+	d.Inhibited = true
+	for _, alert := range uninhibited {
+		if alert.Fingerprint().String() == fingerprint {
+			d.Inhibited = false
+			break
+		}
+	}
+
+	if b.messageStore != nil {
+		messages, err := b.messageStore.ByFingerprint(fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get messages from store", "err", err)
+		}
+		chats, err := b.chatStore.List()
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		}
+		d.Notified = lastNotifications(messages, chats)
+	}
+
+	if b.noteStore != nil {
+		d.Notes, err = b.noteStore.ByFingerprint(fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get notes from store", "err", err)
+		}
+	}
+
+	return d, nil
+}
+
+// sendAlertDetails replies to the message with the details of the alert of the fingerprint
+func (b *Bot) sendAlertDetails(message *telebot.Message, user *telebot.User, fingerprint string) {
+
+	p := b.printer(message.Chat, user)
+
+	d, err := b.alertDetails(fingerprint)
+	if err == errAlertNotFound {
+		b.telegram.Reply(message, p.Sprintf("responseNoFingerprintFound"))
+		return
+	}
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get alert details", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseAlertsFail", err))
+		return
+	}
+
+	text := b.truncateMessage(renderAlertDetails(p, b.location(message.Chat), d, time.Now()))
+	if _, err := b.telegram.Reply(message, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML, DisableWebPagePreview: true}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send alert details", "err", err)
+	}
+}
+
+// Show everything known about an alert by its fingerprint
+func (b *Bot) handleAlert(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseNoFingerprint"))
+		return
+	}
+
+	b.sendAlertDetails(message, message.Sender, args[0])
+
+}
+
+// handleAlertDetailsCallback replies to the alert message with the details of the alert of the button
+func (b *Bot) handleAlertDetailsCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+	b.commandsCounter.WithLabelValues(commandAlert).Inc()
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{})
+	b.sendAlertDetails(c.Message, c.Sender, c.Data)
+}
@@ -0,0 +1,100 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestLastNotifications(t *testing.T) {
+
+	now := time.Now()
+	messages := []SentMessage{
+		{ChatID: 1, MessageID: 10, SentAt: now.Add(-2 * time.Hour)},
+		{ChatID: 1, MessageID: 11, SentAt: now.Add(-time.Hour)},
+		{ChatID: 2, MessageID: 20, SentAt: now.Add(-3 * time.Hour)},
+		{ChatID: 3, MessageID: 30, SentAt: now},
+	}
+	chats := []telebot.Chat{
+		{ID: 1, Title: "Ops"},
+		{ID: 2, Username: "alice"},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the last message per chat, the most recent first
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []notification{
+		{Chat: "3", At: now},
+		{Chat: "Ops", At: now.Add(-time.Hour)},
+		{Chat: "@alice", At: now.Add(-3 * time.Hour)},
+	}, lastNotifications(messages, chats))
+	assert.Empty(t, lastNotifications(nil, chats))
+	t.Log("lastNotifications() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: chat titles
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "Alice Smith", chatTitle(telebot.Chat{ID: 4, FirstName: "Alice", LastName: "Smith"}))
+	assert.Equal(t, "4", chatTitle(telebot.Chat{ID: 4}))
+	t.Log("chatTitle() : Test 2 PASSED.")
+
+}
+
+func TestRenderAlertDetails(t *testing.T) {
+
+	cat, _ := translation.NewCatalog(nil)
+	p := cat.Printer()
+	now := time.Date(2021, 3, 10, 15, 0, 0, 0, time.UTC)
+
+	alert := &types.Alert{}
+	alert.Labels = model.LabelSet{"alertname": "NodeDown", "instance": "<node>"}
+	alert.Annotations = model.LabelSet{
+		"summary":     "Node is down",
+		"runbook_url": "https://runbooks.example.com/NodeDown",
+	}
+	alert.StartsAt = now.Add(-3 * time.Hour)
+	alert.GeneratorURL = "http://prometheus:9090/graph?g0.expr=up&g0.tab=1"
+
+	// ---------------------------------------------------------------------------
+	//  CASE: labels, annotations and links of an alert not silenced nor notified
+	// ---------------------------------------------------------------------------
+	out := renderAlertDetails(p, time.UTC, alertDetails{Alert: alert}, now)
+	assert.Contains(t, out, "<b>NodeDown</b>")
+	assert.Contains(t, out, "instance: <code>&lt;node&gt;</code>")
+	assert.Contains(t, out, "summary: Node is down")
+	assert.Contains(t, out, `<a href="https://runbooks.example.com/NodeDown">runbook_url</a>`)
+	assert.Contains(t, out, `<a href="http://prometheus:9090/graph?g0.expr=up&amp;g0.tab=1">responseAlertDetailsSource</a>`)
+	assert.Contains(t, out, "responseAlertDetailsNotSilenced")
+	assert.Contains(t, out, "responseAlertDetailsNotNotified")
+	assert.NotContains(t, out, "responseAlertDetailsInhibited")
+	assert.NotContains(t, out, "responseAlertDetailsNotes")
+	t.Log("renderAlertDetails() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: silenced, inhibited and notified
+	// ---------------------------------------------------------------------------
+	out = renderAlertDetails(p, time.UTC, alertDetails{
+		Alert:     alert,
+		Silences:  []vendor.Silence{{CreatedBy: "alice", Comment: "maintenance", EndsAt: now.Add(time.Hour)}},
+		Inhibited: true,
+		Notified:  []notification{{Chat: "Ops", At: now.Add(-time.Hour)}},
+		Notes:     []AlertNote{{By: "@bob", At: now.Add(-2 * time.Hour), Text: "restarted <pod>"}},
+	}, now)
+	assert.Contains(t, out, "responseAlertDetailsSilencedBy")
+	assert.Contains(t, out, "responseAlertDetailsInhibited")
+	assert.Contains(t, out, "Ops: 2021-03-10 14:00 UTC")
+	assert.Contains(t, out, "2021-03-10 13:00 UTC @bob: restarted &lt;pod&gt;")
+	t.Log("renderAlertDetails() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,314 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramEscalationsDirectory = "telegram/escalations"
+
+// EscalationStep is taken when the alert is still firing, neither silenced nor claimed,
+// the given time after it was first sent
+type EscalationStep struct {
+	After time.Duration `yaml:"after"`
+	// Renotify sends the alert to the subscribed chats again
+	Renotify bool `yaml:"renotify"`
+	// Users are sent the alert directly, they need to have started a chat with the bot
+	Users []int `yaml:"users"`
+	// Chats are sent the alert, e.g. a management chat
+	Chats []int64 `yaml:"chats"`
+}
+
+// EscalationPolicy escalates the alerts matched by all its matchers
+type EscalationPolicy struct {
+	Name string `yaml:"name"`
+	// Matchers like `severity="critical"`, the same syntax as in Alertmanager's config
+	Matchers []string         `yaml:"matchers"`
+	Steps    []EscalationStep `yaml:"steps"`
+
+	matchers vendor.Matchers
+}
+
+// compile parses the matchers and orders the steps
+func (p *EscalationPolicy) compile() error {
+
+	if p.Name == "" {
+		return errors.New("missing name")
+	}
+	if len(p.Steps) == 0 {
+		return errors.New("no steps")
+	}
+
+	p.matchers = nil
+	for _, s := range p.Matchers {
+		m, err := vendor.ParseMatcher(s)
+		if err != nil {
+			return err
+		}
+		p.matchers = append(p.matchers, m)
+	}
+
+	for i, step := range p.Steps {
+		if !step.Renotify && len(step.Users) == 0 && len(step.Chats) == 0 {
+			return fmt.Errorf("step %d does nothing", i+1)
+		}
+	}
+	sort.SliceStable(p.Steps, func(i, j int) bool { return p.Steps[i].After < p.Steps[j].After })
+
+	return nil
+}
+
+// Matches returns whether the policy applies to the alert
+func (p EscalationPolicy) Matches(alert vendor.Alert) bool {
+	return p.matchers.Matches(labelSet(alert.Labels))
+}
+
+// Escalation of a firing alert, persisted so its timer survives restarts
+type Escalation struct {
+	Fingerprint string `json:"fingerprint"`
+	Policy      string `json:"policy"`
+	// Since is when the alert was first sent, the steps are taken relative to it
+	Since time.Time `json:"since"`
+	// Step is the index of the next step to take
+	Step  int          `json:"step"`
+	Alert vendor.Alert `json:"alert"`
+}
+
+// EscalationStore writes the escalations to a libkv store backend
+type EscalationStore struct {
+	kv store.Store
+}
+
+// NewEscalationStore stores escalations in the provided kv backend
+func NewEscalationStore(kv store.Store) (*EscalationStore, error) {
+	return &EscalationStore{kv: kv}, nil
+}
+
+// List all escalations in progress
+func (s *EscalationStore) List() ([]Escalation, error) {
+
+	kvPairs, err := s.kv.List(telegramEscalationsDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var escalations []Escalation
+	for _, kv := range kvPairs {
+		var e Escalation
+		if err := json.Unmarshal(kv.Value, &e); err != nil {
+			return nil, err
+		}
+		escalations = append(escalations, e)
+	}
+
+	return escalations, nil
+}
+
+// Get the escalation of an alert, nil if there is none
+func (s *EscalationStore) Get(fingerprint string) (*Escalation, error) {
+
+	key := fmt.Sprintf("%s/%s", telegramEscalationsDirectory, fingerprint)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var e Escalation
+	if err := json.Unmarshal(kv.Value, &e); err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// Set the escalation of an alert
+func (s *EscalationStore) Set(e Escalation) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramEscalationsDirectory, e.Fingerprint)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove the escalation of an alert
+func (s *EscalationStore) Remove(fingerprint string) error {
+	key := fmt.Sprintf("%s/%s", telegramEscalationsDirectory, fingerprint)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// escalationPolicy returns the first policy matching the alert, nil if there is none
+func (b *Bot) escalationPolicy(alert vendor.Alert) *EscalationPolicy {
+	for i := range b.escalationPolicies {
+		if b.escalationPolicies[i].Matches(alert) {
+			return &b.escalationPolicies[i]
+		}
+	}
+	return nil
+}
+
+// trackEscalations starts escalating the firing alerts matching a policy and
+// cancels the escalation of the resolved ones
+func (b *Bot) trackEscalations(alerts vendor.Alerts, now time.Time) {
+
+	if b.escalationStore == nil || len(b.escalationPolicies) == 0 {
+		return
+	}
+
+	for _, alert := range alerts {
+
+		fingerprint := alertFingerprint(alert)
+
+		if alert.Status != string(model.AlertFiring) {
+			if err := b.escalationStore.Remove(fingerprint); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove escalation from store", "err", err)
+			}
+			continue
+		}
+
+		policy := b.escalationPolicy(alert)
+		if policy == nil {
+			continue
+		}
+
+		e, err := b.escalationStore.Get(fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get escalation from store", "err", err)
+			continue
+		}
+		if e == nil || !e.Alert.StartsAt.Equal(alert.StartsAt) {
+			e = &Escalation{Fingerprint: fingerprint, Policy: policy.Name, Since: now}
+		}
+		e.Alert = alert
+
+		if err := b.escalationStore.Set(*e); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save escalation to store", "err", err)
+		}
+	}
+}
+
+// escalate takes the steps that are due of the alerts neither silenced nor claimed
+func (b *Bot) escalate(now time.Time) {
+
+	if b.escalationStore == nil {
+		return
+	}
+
+	escalations, err := b.escalationStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get escalations from store", "err", err)
+		return
+	}
+	if len(escalations) == 0 {
+		return
+	}
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get silences, postponing escalations", "err", err)
+		return
+	}
+
+	for _, e := range escalations {
+
+		var policy *EscalationPolicy
+		for i := range b.escalationPolicies {
+			if b.escalationPolicies[i].Name == e.Policy {
+				policy = &b.escalationPolicies[i]
+			}
+		}
+
+		claimed := false
+		if b.ackStore != nil {
+			ack, err := b.ackStore.Get(e.Fingerprint)
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to get acknowledgement from store", "err", err)
+				continue
+			}
+			claimed = ack != nil && ack.StartsAt.Equal(e.Alert.StartsAt)
+		}
+
+		if policy == nil || e.Step >= len(policy.Steps) || claimed || alertmanager.Silenced(silences, labelSet(e.Alert.Labels)) {
+			if err := b.escalationStore.Remove(e.Fingerprint); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove escalation from store", "err", err)
+			}
+			continue
+		}
+
+		step := policy.Steps[e.Step]
+		if now.Sub(e.Since) < step.After {
+			continue
+		}
+
+		level.Info(b.logger).Log(
+			"msg", "escalating alert",
+			"fingerprint", e.Fingerprint,
+			"policy", e.Policy,
+			"step", e.Step+1,
+		)
+		b.escalationStep(step, e.Alert, now.Sub(e.Since))
+
+		e.Step++
+		if e.Step == len(policy.Steps) {
+			err = b.escalationStore.Remove(e.Fingerprint)
+		} else {
+			err = b.escalationStore.Set(e)
+		}
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save escalation to store", "err", err)
+		}
+	}
+}
+
+// escalationStep sends the alert to everyone the step escalates to
+func (b *Bot) escalationStep(step EscalationStep, alert vendor.Alert, unclaimed time.Duration) {
+
+	var chats []telebot.Chat
+	if step.Renotify {
+		subscribed, err := b.chatStore.List()
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		}
+		chats = append(chats, subscribed...)
+	}
+	for _, id := range step.Users {
+		chats = append(chats, telebot.Chat{ID: int64(id)})
+	}
+	for _, id := range step.Chats {
+		chats = append(chats, telebot.Chat{ID: id})
+	}
+
+	data := withAlerts(&vendor.Data{}, vendor.Alerts{alert})
+	for _, chat := range chats {
+		p := b.printer(&chat, nil)
+		if _, err := b.telegram.Send(&chat, p.Sprintf("responseEscalation", alert.Labels["alertname"], unclaimed.Round(time.Minute).String())); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send escalation", "chat", chat.ID, "err", err)
+			continue
+		}
+		b.sendData(chat, data, false)
+	}
+}
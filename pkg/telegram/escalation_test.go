@@ -0,0 +1,122 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestEscalations(t *testing.T) {
+
+	silences, err := ioutil.ReadFile("../test/silences.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(silences)
+	}))
+	defer server.Close()
+	alertmanagerURL, _ := url.Parse(server.URL)
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "escalations"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, _ := NewEscalationStore(kvStore)
+	acks, _ := NewAckStore(kvStore)
+
+	policy := EscalationPolicy{
+		Name:     "critical",
+		Matchers: []string{`severity="critical"`},
+		Steps:    []EscalationStep{{After: 10 * time.Minute, Renotify: true}},
+	}
+	if err := policy.compile(); err != nil {
+		t.Fatalf("compile() : Test 1 FAILED, got error: %s", err)
+	}
+
+	bot := &Bot{
+		logger:             log.NewNopLogger(),
+		alertmanager:       alertmanagerURL,
+		ackStore:           acks,
+		escalationStore:    s,
+		escalationPolicies: []EscalationPolicy{policy},
+	}
+
+	now := time.Now()
+	startsAt := now.Add(-time.Hour)
+	silenced := vendor.Alert{Status: "firing", Fingerprint: "silenced", StartsAt: startsAt,
+		Labels: vendor.KV{"alertname": "alertname_1", "environment": "monitoring", "severity": "critical"}}
+	claimed := vendor.Alert{Status: "firing", Fingerprint: "claimed", StartsAt: startsAt,
+		Labels: vendor.KV{"alertname": "B", "severity": "critical"}}
+	pending := vendor.Alert{Status: "firing", Fingerprint: "pending", StartsAt: startsAt,
+		Labels: vendor.KV{"alertname": "C", "severity": "critical"}}
+	warning := vendor.Alert{Status: "firing", Fingerprint: "warning", StartsAt: startsAt,
+		Labels: vendor.KV{"alertname": "D", "severity": "warning"}}
+
+	for _, fp := range []string{"silenced", "claimed", "pending", "warning"} {
+		assert.NoError(t, s.Remove(fp))
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only firing alerts matching a policy are escalated
+	// ---------------------------------------------------------------------------
+	bot.trackEscalations(vendor.Alerts{silenced, claimed, pending, warning}, now)
+
+	escalations, err := s.List()
+	assert.NoError(t, err)
+	assert.Len(t, escalations, 3)
+	e, _ := s.Get("warning")
+	assert.Nil(t, e)
+	t.Log("trackEscalations() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the escalation keeps its timer while the alert keeps firing
+	// ---------------------------------------------------------------------------
+	bot.trackEscalations(vendor.Alerts{pending}, now.Add(5*time.Minute))
+	e, _ = s.Get("pending")
+	if assert.NotNil(t, e) {
+		assert.True(t, now.Equal(e.Since))
+	}
+	t.Log("trackEscalations() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: silenced and claimed alerts are not escalated, steps wait until due
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, acks.Set(Ack{Fingerprint: "claimed", StartsAt: startsAt, By: "@alice"}))
+	bot.escalate(now.Add(5 * time.Minute))
+
+	escalations, err = s.List()
+	assert.NoError(t, err)
+	if assert.Len(t, escalations, 1) {
+		assert.Equal(t, "pending", escalations[0].Fingerprint)
+		assert.Equal(t, 0, escalations[0].Step)
+	}
+	t.Log("escalate() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: resolved alerts cancel their escalation
+	// ---------------------------------------------------------------------------
+	resolved := pending
+	resolved.Status = "resolved"
+	bot.trackEscalations(vendor.Alerts{resolved}, now)
+	e, _ = s.Get("pending")
+	assert.Nil(t, e)
+	t.Log("trackEscalations() : Test 3 PASSED.")
+
+}
@@ -0,0 +1,287 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+)
+
+const telegramFlapsDirectory = "telegram/flaps"
+
+// Flap tracks the state transitions of an alert to tell whether it is flapping
+type Flap struct {
+	Fingerprint string `json:"fingerprint"`
+	// Status is the last status the alert was received with
+	Status string `json:"status"`
+	// Transitions are the times the status changed within the flapping window
+	Transitions []time.Time `json:"transitions,omitempty"`
+	// Since is when the alert started flapping, zero if it isn't
+	Since time.Time `json:"since"`
+	// Messages are the single messages the flapping alert is collapsed into, by chat
+	Messages map[int64]int `json:"messages,omitempty"`
+}
+
+// Flapping returns whether the alert is flapping
+func (f Flap) Flapping() bool {
+	return !f.Since.IsZero()
+}
+
+// observe records the status the alert was received with and forgets the
+// transitions before the given time
+func (f *Flap) observe(status string, now, before time.Time) {
+
+	if f.Status != "" && f.Status != status {
+		f.Transitions = append(f.Transitions, now)
+	}
+	f.Status = status
+
+	f.forget(before)
+}
+
+// forget the transitions before the given time, it returns whether there were any
+func (f *Flap) forget(before time.Time) bool {
+	i := 0
+	for i < len(f.Transitions) && f.Transitions[i].Before(before) {
+		i++
+	}
+	f.Transitions = f.Transitions[i:]
+	return i > 0
+}
+
+// FlapStore writes the state transitions of alerts to a libkv store backend
+type FlapStore struct {
+	kv store.Store
+}
+
+// NewFlapStore stores the state transitions of alerts in the provided kv backend
+func NewFlapStore(kv store.Store) (*FlapStore, error) {
+	return &FlapStore{kv: kv}, nil
+}
+
+// List the transitions of all alerts
+func (s *FlapStore) List() ([]Flap, error) {
+
+	kvPairs, err := s.kv.List(telegramFlapsDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var flaps []Flap
+	for _, kv := range kvPairs {
+		var f Flap
+		if err := json.Unmarshal(kv.Value, &f); err != nil {
+			return nil, err
+		}
+		flaps = append(flaps, f)
+	}
+
+	return flaps, nil
+}
+
+// Get the transitions of an alert, nil if there are none
+func (s *FlapStore) Get(fingerprint string) (*Flap, error) {
+
+	key := fmt.Sprintf("%s/%s", telegramFlapsDirectory, fingerprint)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var f Flap
+	if err := json.Unmarshal(kv.Value, &f); err != nil {
+		return nil, err
+	}
+
+	return &f, nil
+}
+
+// Set the transitions of an alert
+func (s *FlapStore) Set(f Flap) error {
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramFlapsDirectory, f.Fingerprint)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove the transitions of an alert
+func (s *FlapStore) Remove(fingerprint string) error {
+	key := fmt.Sprintf("%s/%s", telegramFlapsDirectory, fingerprint)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// collapseFlapping tracks the transitions of the alerts and returns the data without
+// the flapping ones, those are sent once to every chat and the message is edited afterwards
+func (b *Bot) collapseFlapping(data *vendor.Data, now time.Time) *vendor.Data {
+
+	if b.flapStore == nil || b.messageStore == nil || b.flapThreshold <= 0 {
+		return data
+	}
+
+	var alerts vendor.Alerts
+	for _, alert := range data.Alerts {
+
+		fingerprint := alertFingerprint(alert)
+
+		f, err := b.flapStore.Get(fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get transitions from store", "err", err)
+			alerts = append(alerts, alert)
+			continue
+		}
+		if f == nil {
+			f = &Flap{Fingerprint: fingerprint}
+		}
+		f.observe(alert.Status, now, now.Add(-b.flapWindow))
+
+		switch {
+		case f.Flapping():
+			b.saveFlap(*f)
+			b.editFlapping(f.Messages, &alert)
+		case len(f.Transitions) >= b.flapThreshold:
+			level.Info(b.logger).Log("msg", "alert is flapping", "fingerprint", fingerprint, "transitions", len(f.Transitions))
+			f.Since = now
+			b.saveFlap(*f)
+			f.Messages = b.sendFlapping(withAlerts(data, vendor.Alerts{alert}), now)
+			b.saveFlap(*f)
+		default:
+			b.saveFlap(*f)
+			alerts = append(alerts, alert)
+		}
+	}
+
+	return withAlerts(data, alerts)
+}
+
+// settleFlapping ends the flapping of the alerts with too few transitions left in
+// the window and forgets the resolved alerts that stopped changing
+func (b *Bot) settleFlapping(now time.Time) {
+
+	if b.flapStore == nil || b.messageStore == nil || b.flapThreshold <= 0 {
+		return
+	}
+
+	flaps, err := b.flapStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get transitions from store", "err", err)
+		return
+	}
+
+	for _, f := range flaps {
+
+		forgot := f.forget(now.Add(-b.flapWindow))
+
+		switch {
+		case f.Flapping() && len(f.Transitions) < b.flapThreshold:
+			level.Info(b.logger).Log("msg", "alert stopped flapping", "fingerprint", f.Fingerprint)
+			messages := f.Messages
+			f.Since, f.Messages = time.Time{}, nil
+			b.saveFlap(f)
+			// The messages are edited one last time to show the alert isn't flapping anymore
+			b.editFlapping(messages, nil)
+		case !f.Flapping() && len(f.Transitions) == 0 && f.Status == string(model.AlertResolved):
+			if err := b.flapStore.Remove(f.Fingerprint); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove transitions from store", "err", err)
+			}
+		case forgot:
+			b.saveFlap(f)
+		}
+	}
+}
+
+// sendFlapping sends the flapping alert to every chat and returns the messages sent
+func (b *Bot) sendFlapping(data *vendor.Data, now time.Time) map[int64]int {
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		return nil
+	}
+
+	messages := map[int64]int{}
+	for _, chat := range chats {
+
+		settings, err := b.chatStore.Settings(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		}
+		silent := settings.Schedule.Quiet(now) && !settings.Schedule.Loud(data.Alerts[0])
+
+		if sent := b.sendData(chat, data, silent); len(sent) > 0 {
+			messages[chat.ID] = sent[len(sent)-1].ID
+		}
+	}
+
+	return messages
+}
+
+// editFlapping edits the messages of the flapping alert, updated with the alert received if any
+func (b *Bot) editFlapping(messages map[int64]int, alert *vendor.Alert) {
+
+	for chatID, messageID := range messages {
+
+		m, err := b.messageStore.Get(chatID, messageID)
+		if err == store.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get message from store", "err", err)
+			continue
+		}
+
+		if alert != nil {
+			m.Data = withAlerts(m.Data, vendor.Alerts{*alert})
+			if err := b.messageStore.Add(m); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to save message to store", "err", err)
+			}
+		}
+
+		b.editMessage(m)
+	}
+}
+
+// saveFlap saves the transitions of an alert, logging failures
+func (b *Bot) saveFlap(f Flap) {
+	if err := b.flapStore.Set(f); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save transitions to store", "err", err)
+	}
+}
+
+// flapping returns the transitions of the alert if it's flapping, nil otherwise
+func (b *Bot) flapping(alert vendor.Alert) *Flap {
+
+	if b.flapStore == nil {
+		return nil
+	}
+
+	f, err := b.flapStore.Get(alertFingerprint(alert))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get transitions from store", "err", err)
+		return nil
+	}
+	if f == nil || !f.Flapping() {
+		return nil
+	}
+
+	return f
+}
@@ -0,0 +1,117 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestFlap(t *testing.T) {
+
+	now := time.Now()
+	f := Flap{Fingerprint: "a"}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the first status isn't a transition, changes are
+	// ---------------------------------------------------------------------------
+	f.observe("firing", now, now.Add(-time.Hour))
+	f.observe("firing", now.Add(time.Minute), now.Add(-time.Hour))
+	assert.Empty(t, f.Transitions)
+	f.observe("resolved", now.Add(2*time.Minute), now.Add(-time.Hour))
+	f.observe("firing", now.Add(3*time.Minute), now.Add(-time.Hour))
+	assert.Equal(t, []time.Time{now.Add(2 * time.Minute), now.Add(3 * time.Minute)}, f.Transitions)
+	t.Log("Flap() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: transitions out of the window are forgotten
+	// ---------------------------------------------------------------------------
+	assert.True(t, f.forget(now.Add(150*time.Second)))
+	assert.Equal(t, []time.Time{now.Add(3 * time.Minute)}, f.Transitions)
+	assert.False(t, f.forget(now.Add(150*time.Second)))
+	t.Log("Flap() : Test 2 PASSED.")
+
+}
+
+func TestCollapseFlapping(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "flapping"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	flaps, _ := NewFlapStore(kvStore)
+	messages, _ := NewMessageStore(kvStore)
+	chats, _ := NewChatStore(kvStore)
+
+	bot := &Bot{
+		logger:        log.NewNopLogger(),
+		chatStore:     chats,
+		messageStore:  messages,
+		flapStore:     flaps,
+		flapWindow:    time.Hour,
+		flapThreshold: 3,
+	}
+
+	assert.NoError(t, flaps.Remove("flapping"))
+
+	now := time.Now()
+	alert := func(status string) *vendor.Data {
+		return &vendor.Data{Alerts: vendor.Alerts{{
+			Status:      status,
+			Fingerprint: "flapping",
+			Labels:      vendor.KV{"alertname": "Flapping"},
+		}}}
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: alerts are sent as usual until they change too often
+	// ---------------------------------------------------------------------------
+	for i, status := range []string{"firing", "resolved", "firing"} {
+		data := bot.collapseFlapping(alert(status), now.Add(time.Duration(i)*time.Minute))
+		assert.Len(t, data.Alerts, 1)
+		assert.False(t, bot.annotate(data).Alerts[0].IsFlapping)
+	}
+	t.Log("collapseFlapping() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: flapping alerts are collapsed and marked for the templates
+	// ---------------------------------------------------------------------------
+	for i, status := range []string{"resolved", "firing"} {
+		data := bot.collapseFlapping(alert(status), now.Add(time.Duration(3+i)*time.Minute))
+		assert.Empty(t, data.Alerts)
+	}
+	annotated := bot.annotate(alert("firing")).Alerts[0]
+	assert.True(t, annotated.IsFlapping)
+	assert.Equal(t, 4, annotated.Flaps)
+	t.Log("collapseFlapping() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: alerts stop flapping when the transitions leave the window
+	// ---------------------------------------------------------------------------
+	bot.settleFlapping(now.Add(2 * time.Hour))
+	assert.False(t, bot.annotate(alert("firing")).Alerts[0].IsFlapping)
+	data := bot.collapseFlapping(alert("resolved"), now.Add(2*time.Hour))
+	assert.Len(t, data.Alerts, 1)
+	t.Log("collapseFlapping() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: resolved alerts that stopped changing are forgotten
+	// ---------------------------------------------------------------------------
+	bot.settleFlapping(now.Add(4 * time.Hour))
+	f, err := flaps.Get("flapping")
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+	t.Log("collapseFlapping() : Test 4 PASSED.")
+
+}
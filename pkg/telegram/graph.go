@@ -0,0 +1,222 @@
+package telegram
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/chart"
+	"github.com/NobleD5/alertmanager-bot/pkg/prometheus"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	graphWidth  = 800
+	graphHeight = 400
+	// graphBefore is how long before the alert started its graph begins
+	graphBefore = time.Hour
+	// graphPoints is about how many samples of each series are drawn
+	graphPoints = 240
+	// maxCaption is how long the caption of a photo may be
+	maxCaption = 1024
+)
+
+// graphButton is sent with every firing alert whose expression can be graphed
+var graphButton = telebot.InlineButton{Unique: "graph"}
+
+// errNoExpr is returned when the generator URL of an alert has no expression
+var errNoExpr = errors.New("no expression in the generator URL")
+
+// generatorExpr returns the PromQL expression of the Prometheus graph link an alert was generated by
+func generatorExpr(generatorURL string) (string, error) {
+
+	u, err := url.Parse(generatorURL)
+	if err != nil {
+		return "", err
+	}
+
+	expr := strings.TrimSpace(u.Query().Get("g0.expr"))
+	if expr == "" {
+		return "", errNoExpr
+	}
+
+	return expr, nil
+}
+
+// graphRange returns the range and step the alert is graphed over: from a while before it started
+// until it was resolved or now
+func graphRange(alert *types.Alert, now time.Time) (time.Time, time.Time, time.Duration) {
+
+	start, end := alert.StartsAt.Add(-graphBefore), now
+	if alert.Resolved() && alert.EndsAt.Before(now) {
+		end = alert.EndsAt
+	}
+
+	return start, end, graphStep(end.Sub(start))
+}
+
+// graphStep returns the step a range is graphed with
+func graphStep(span time.Duration) time.Duration {
+	if step := span / graphPoints; step > time.Second {
+		return step
+	}
+	return time.Second
+}
+
+// truncateCaption cuts the last lines of the caption so that Telegram accepts it
+func truncateCaption(caption string) string {
+
+	if len([]rune(caption)) <= maxCaption {
+		return caption
+	}
+
+	lines := strings.Split(caption, "\n")
+	for len(lines) > 1 && len([]rune(strings.Join(lines, "\n")))+2 > maxCaption {
+		lines = lines[:len(lines)-1]
+	}
+	caption = strings.Join(lines, "\n")
+	if r := []rune(caption); len(r)+2 > maxCaption {
+		caption = string(r[:maxCaption-2])
+	}
+
+	return caption + "\n…"
+}
+
+// graphCaption describes a chart: what it shows, the range and values it was drawn with and
+// the series the colors stand for
+func graphCaption(p *translation.Printer, location *time.Location, title string, matrix model.Matrix, bounds chart.Bounds) string {
+
+	const timeFormat = "2006-01-02 15:04 MST"
+
+	var out strings.Builder
+
+	fmt.Fprintf(&out, "%s\n", title)
+	fmt.Fprintf(&out, "%s\n", p.Sprintf("responseGraphRange",
+		bounds.From.In(location).Format(timeFormat), bounds.To.In(location).Format(timeFormat)))
+	fmt.Fprintf(&out, "%s\n", p.Sprintf("responseGraphValues",
+		model.SampleValue(bounds.Min).String(), model.SampleValue(bounds.Max).String()))
+
+	for i, s := range matrix {
+		if i == len(chart.Legend) {
+			fmt.Fprintf(&out, "%s\n", p.Sprintf("responseGraphMore", len(matrix)-i))
+			break
+		}
+		fmt.Fprintf(&out, "%s %s\n", chart.Legend[i], s.Metric.String())
+	}
+
+	return truncateCaption(strings.TrimSuffix(out.String(), "\n"))
+}
+
+// replyChart replies to the message with the series drawn as a chart, the caption starting with the title
+func (b *Bot) replyChart(message *telebot.Message, p *translation.Printer, title string, matrix model.Matrix) {
+
+	var buf bytes.Buffer
+	bounds, err := chart.Line(&buf, matrix, graphWidth, graphHeight)
+	if err == chart.ErrNoData {
+		b.telegram.Reply(message, p.Sprintf("responseGraphNoData"))
+		return
+	}
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to draw chart", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseGraphFail", err))
+		return
+	}
+
+	photo := &telebot.Photo{
+		File:    telebot.FromReader(&buf),
+		Caption: graphCaption(p, b.location(message.Chat), title, matrix, bounds),
+	}
+	if _, err := b.telegram.Reply(message, photo); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send chart", "err", err)
+	}
+}
+
+// sendGraph replies to the message with a graph of the expression of the alert of the fingerprint
+func (b *Bot) sendGraph(message *telebot.Message, user *telebot.User, fingerprint string) {
+
+	p := b.printer(message.Chat, user)
+
+	if b.prometheus == nil {
+		b.telegram.Reply(message, p.Sprintf("responseGraphDisabled"))
+		return
+	}
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to list alerts", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseAlertsFail", err))
+		return
+	}
+	var alert *types.Alert
+	for _, a := range alerts {
+		if a.Fingerprint().String() == fingerprint {
+			alert = a
+			break
+		}
+	}
+	if alert == nil {
+		b.telegram.Reply(message, p.Sprintf("responseNoFingerprintFound"))
+		return
+	}
+
+	expr, err := generatorExpr(alert.GeneratorURL)
+	if err != nil {
+		level.Debug(b.logger).Log("msg", "failed to get the expression of the alert", "url", alert.GeneratorURL, "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseGraphNoExpr"))
+		return
+	}
+
+	start, end, step := graphRange(alert, time.Now())
+	matrix, err := prometheus.QueryRange(b.logger, b.prometheus.String(), expr, start, end, step)
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to query Prometheus", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseGraphFail", err))
+		return
+	}
+
+	b.replyChart(message, p, fmt.Sprintf("%s: %s", alert.Name(), expr), matrix)
+
+}
+
+// Graph the expression of an alert by its fingerprint
+func (b *Bot) handleGraph(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseNoFingerprint"))
+		return
+	}
+
+	b.sendGraph(message, message.Sender, args[0])
+
+}
+
+// handleGraphCallback replies to the alert message with a graph of the alert of the button
+func (b *Bot) handleGraphCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+	b.commandsCounter.WithLabelValues(commandGraph).Inc()
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{})
+	b.sendGraph(c.Message, c.Sender, c.Data)
+}
@@ -0,0 +1,120 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/chart"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestGraphExpr(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the expression of a Prometheus graph link
+	// ---------------------------------------------------------------------------
+	expr, err := generatorExpr("http://prometheus:9090/graph?g0.expr=up%7Bjob%3D%22node%22%7D+%3D%3D+0&g0.tab=1")
+	assert.NoError(t, err)
+	assert.Equal(t, `up{job="node"} == 0`, expr)
+	t.Log("generatorExpr() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: links without an expression
+	// ---------------------------------------------------------------------------
+	_, err = generatorExpr("http://prometheus:9090/graph?g0.tab=1")
+	assert.Equal(t, errNoExpr, err)
+	_, err = generatorExpr("")
+	assert.Equal(t, errNoExpr, err)
+	_, err = generatorExpr("http://[::1")
+	assert.Error(t, err)
+	t.Log("generatorExpr() : Test 2 PASSED.")
+
+}
+
+func TestGraphRange(t *testing.T) {
+
+	now := time.Date(2021, 3, 10, 15, 0, 0, 0, time.UTC)
+	alert := &types.Alert{}
+	alert.StartsAt = now.Add(-3 * time.Hour)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a firing alert is graphed until now
+	// ---------------------------------------------------------------------------
+	start, end, step := graphRange(alert, now)
+	assert.Equal(t, now.Add(-4*time.Hour), start)
+	assert.Equal(t, now, end)
+	assert.Equal(t, time.Minute, step)
+	t.Log("graphRange() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a resolved alert is graphed until it was resolved
+	// ---------------------------------------------------------------------------
+	alert.EndsAt = now.Add(-2 * time.Hour)
+	_, end, step = graphRange(alert, now)
+	assert.Equal(t, now.Add(-2*time.Hour), end)
+	assert.Equal(t, 30*time.Second, step)
+	t.Log("graphRange() : Test 2 PASSED.")
+
+}
+
+func TestGraphCaption(t *testing.T) {
+
+	cat, err := translation.NewCatalog(map[string]translation.Dictionary{"en": {
+		"responseGraphRange":  {Text: "From %s to %s"},
+		"responseGraphValues": {Text: "Values from %s to %s"},
+		"responseGraphMore":   {Text: "and %d more series"},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := cat.Printer()
+	start := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+	bounds := chart.Bounds{From: start, To: start.Add(time.Hour), Min: 0, Max: 1.5}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the range, the values and the legend
+	// ---------------------------------------------------------------------------
+	matrix := model.Matrix{
+		{Metric: model.Metric{"__name__": "up", "instance": "a"}},
+		{Metric: model.Metric{"__name__": "up", "instance": "b"}},
+	}
+	assert.Equal(t, strings.Join([]string{
+		"NodeDown: up == 0",
+		"From 2021-03-10 12:00 UTC to 2021-03-10 13:00 UTC",
+		"Values from 0 to 1.5",
+		"🟦 up{instance=\"a\"}",
+		"🟥 up{instance=\"b\"}",
+	}, "\n"), graphCaption(p, time.UTC, "NodeDown: up == 0", matrix, bounds))
+	t.Log("graphCaption() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the series not drawn are counted
+	// ---------------------------------------------------------------------------
+	matrix = nil
+	for i := 0; i < len(chart.Legend)+2; i++ {
+		matrix = append(matrix, &model.SampleStream{Metric: model.Metric{"instance": model.LabelValue(string(rune('a' + i)))}})
+	}
+	assert.True(t, strings.HasSuffix(graphCaption(p, time.UTC, "up", matrix, bounds), "⬛ {instance=\"h\"}\nand 2 more series"))
+	t.Log("graphCaption() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a caption too long is cut at a line
+	// ---------------------------------------------------------------------------
+	for _, s := range matrix {
+		s.Metric["instance"] = model.LabelValue(strings.Repeat("x", 200))
+	}
+	caption := graphCaption(p, time.UTC, "up", matrix, bounds)
+	assert.True(t, len([]rune(caption)) <= maxCaption)
+	assert.True(t, strings.HasSuffix(caption, "x\"}\n…"))
+	t.Log("graphCaption() : Test 3 PASSED.")
+
+}
@@ -0,0 +1,392 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramHistoryDirectory = "telegram/history"
+
+// Transition of an alert to a status at the time the bot received it
+type Transition struct {
+	Status string    `json:"status"`
+	At     time.Time `json:"at"`
+}
+
+// AlertRecord is a single firing of an alert, from its start until it is resolved
+type AlertRecord struct {
+	Fingerprint string       `json:"fingerprint"`
+	Status      string       `json:"status"`
+	Labels      vendor.KV    `json:"labels"`
+	Annotations vendor.KV    `json:"annotations"`
+	StartsAt    time.Time    `json:"startsAt"`
+	EndsAt      time.Time    `json:"endsAt"`
+	Transitions []Transition `json:"transitions"`
+}
+
+// Resolved returns whether the alert was resolved
+func (r AlertRecord) Resolved() bool {
+	return r.Status == string(model.AlertResolved)
+}
+
+// FiringTime returns how long the alert was firing within the given period
+func (r AlertRecord) FiringTime(from, to time.Time) time.Duration {
+
+	start, end := r.StartsAt, to
+	if r.Resolved() && r.EndsAt.Before(to) {
+		end = r.EndsAt
+	}
+	if start.Before(from) {
+		start = from
+	}
+	if end.Before(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
+
+// HistoryStore writes every alert received to a libkv store backend
+type HistoryStore struct {
+	kv store.Store
+}
+
+// NewHistoryStore stores the alert history in the provided kv backend
+func NewHistoryStore(kv store.Store) (*HistoryStore, error) {
+	return &HistoryStore{kv: kv}, nil
+}
+
+// Record the alerts received at the given time, adding a transition to the
+// alerts whose status changed
+func (s *HistoryStore) Record(at time.Time, alerts ...vendor.Alert) error {
+
+	for _, alert := range alerts {
+
+		fingerprint := alertFingerprint(alert)
+		key := fmt.Sprintf("%s/%s/%d", telegramHistoryDirectory, fingerprint, alert.StartsAt.Unix())
+
+		var record AlertRecord
+		kv, err := s.kv.Get(key)
+		switch err {
+		case nil:
+			if err := json.Unmarshal(kv.Value, &record); err != nil {
+				return err
+			}
+		case store.ErrKeyNotFound:
+			record = AlertRecord{Fingerprint: fingerprint, StartsAt: alert.StartsAt}
+		default:
+			return err
+		}
+
+		if n := len(record.Transitions); n == 0 || record.Transitions[n-1].Status != alert.Status {
+			record.Transitions = append(record.Transitions, Transition{Status: alert.Status, At: at})
+		}
+		record.Status = alert.Status
+		record.Labels = alert.Labels
+		record.Annotations = alert.Annotations
+		record.EndsAt = alert.EndsAt
+
+		b, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		if err := s.kv.Put(key, b, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Range returns the alerts firing at some point within the given period
+func (s *HistoryStore) Range(from, to time.Time) ([]AlertRecord, error) {
+
+	records, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var inRange []AlertRecord
+	for _, record := range records {
+		if record.StartsAt.Before(to) && (!record.Resolved() || record.EndsAt.After(from)) {
+			inRange = append(inRange, record)
+		}
+	}
+
+	return inRange, nil
+}
+
+// Prune removes the alerts resolved before the given time
+func (s *HistoryStore) Prune(before time.Time) error {
+
+	records, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		if !record.Resolved() || record.EndsAt.After(before) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%d", telegramHistoryDirectory, record.Fingerprint, record.StartsAt.Unix())
+		if err := s.kv.Delete(key); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list all records saved in the kv backend
+func (s *HistoryStore) list() ([]AlertRecord, error) {
+
+	kvPairs, err := s.kv.List(telegramHistoryDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var records []AlertRecord
+	for _, kv := range kvPairs {
+		var record AlertRecord
+		if err := json.Unmarshal(kv.Value, &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+const (
+	// defaultHistoryPeriod is how far back /history looks without a period
+	defaultHistoryPeriod = 24 * time.Hour
+	// defaultTopPeriod is how far back /top looks without a period
+	defaultTopPeriod = 7 * day
+	// historyEvents is how many of the most recent transitions /history shows
+	historyEvents = 50
+	// topAlerts is how many of the noisiest alertnames /top lists
+	topAlerts = 10
+)
+
+// fingerprintPattern matches the fingerprints of alerts as Alertmanager prints them
+var fingerprintPattern = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+// historyQuery is what /history is asked for: an alert by its fingerprint or the alerts matching the matchers
+type historyQuery struct {
+	fingerprint string
+	matchers    vendor.Matchers
+	period      time.Duration
+}
+
+// matches returns whether the record is of the alerts asked for
+func (q historyQuery) matches(r AlertRecord) bool {
+	if q.fingerprint != "" {
+		return r.Fingerprint == q.fingerprint
+	}
+	return q.matchers.Matches(labelSet(r.Labels))
+}
+
+// historyEvent is a transition of a recorded alert shown by /history
+type historyEvent struct {
+	Transition
+	Alertname   string
+	Fingerprint string
+}
+
+// parseHistoryQuery parses the arguments of /history: a fingerprint or matchers and an optional period
+func parseHistoryQuery(s string) (historyQuery, error) {
+
+	q := historyQuery{period: defaultHistoryPeriod}
+
+	tokens := splitQuoted(s)
+	if n := len(tokens); n > 1 {
+		if period, err := parseSilenceDuration(tokens[n-1]); err == nil {
+			q.period = period
+			tokens = tokens[:n-1]
+		}
+	}
+	if len(tokens) == 0 {
+		return q, errors.New("expected a fingerprint or matchers")
+	}
+	if len(tokens) == 1 && fingerprintPattern.MatchString(tokens[0]) {
+		q.fingerprint = tokens[0]
+		return q, nil
+	}
+
+	var matchers []string
+	for _, token := range tokens {
+		if token = strings.Trim(token, ","); token != "" {
+			matchers = append(matchers, token)
+		}
+	}
+	ms, err := vendor.ParseMatchers(strings.Join(matchers, ","))
+	if err != nil {
+		return q, err
+	}
+	q.matchers = ms
+
+	return q, nil
+}
+
+// historyTimeline returns the transitions of the records asked for within the given period, the most recent first
+func historyTimeline(records []AlertRecord, q historyQuery, from, to time.Time) ([]AlertRecord, []historyEvent) {
+
+	var (
+		matching []AlertRecord
+		events   []historyEvent
+	)
+	for _, r := range records {
+		if !q.matches(r) {
+			continue
+		}
+		matching = append(matching, r)
+		for _, t := range r.Transitions {
+			if t.At.Before(from) || !t.At.Before(to) {
+				continue
+			}
+			events = append(events, historyEvent{Transition: t, Alertname: r.Labels["alertname"], Fingerprint: r.Fingerprint})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+
+	return matching, events
+}
+
+// renderHistory renders the timeline of the alerts asked for as HTML
+func renderHistory(p *translation.Printer, location *time.Location, q historyQuery, records []AlertRecord, events []historyEvent, from, to time.Time) string {
+
+	var firing time.Duration
+	for _, r := range records {
+		firing += r.FiringTime(from, to)
+	}
+
+	lines := []string{p.Sprintf("responseHistory",
+		model.Duration(q.period).String(), len(records), firing.Round(time.Minute).String())}
+
+	if len(events) > historyEvents {
+		lines = append(lines, p.Sprintf("responseHistoryMore", historyEvents))
+		events = events[:historyEvents]
+	}
+	lines = append(lines, "")
+
+	for _, e := range events {
+		status := "🔥"
+		if e.Status == string(model.AlertResolved) {
+			status = "✅"
+		}
+		lines = append(lines, fmt.Sprintf("%s %s <b>%s</b> <code>%s</code>",
+			e.At.In(location).Format("2006-01-02 15:04"), status, html.EscapeString(e.Alertname), e.Fingerprint))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// renderTop renders the noisiest alertnames of the report as HTML
+func renderTop(p *translation.Printer, period time.Duration, report Report) string {
+
+	lines := []string{p.Sprintf("responseTop", model.Duration(period).String()), ""}
+	for i, s := range report.Alerts {
+		if i == topAlerts {
+			break
+		}
+		lines = append(lines, p.Sprintf("responseTopAlert", i+1, html.EscapeString(s.Alertname), s.Fired, s.FiringTime.String()))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Show the timeline of an alert by its fingerprint or of the alerts matching matchers
+func (b *Bot) handleHistory(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.historyStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseHistoryFail"))
+		return
+	}
+
+	q, err := parseHistoryQuery(commandArgs(message.Text))
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseHistoryInvalid", err.Error(), commandHistory))
+		return
+	}
+
+	to := time.Now()
+	from := to.Add(-q.period)
+	records, err := b.historyStore.Range(from, to)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get alert history from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseHistoryFail"))
+		return
+	}
+
+	records, events := historyTimeline(records, q, from, to)
+	if len(events) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseHistoryEmpty", model.Duration(q.period).String()))
+		return
+	}
+
+	text := renderHistory(p, b.location(message.Chat), q, records, events, from, to)
+	if _, err := b.telegram.Reply(message, text, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send alert history", "err", err)
+	}
+
+}
+
+// List the alertnames that fired the most and the longest
+func (b *Bot) handleTop(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.historyStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseHistoryFail"))
+		return
+	}
+
+	period := defaultTopPeriod
+	if args := strings.Fields(message.Text)[1:]; len(args) > 0 {
+		var err error
+		if period, err = parseSilenceDuration(args[0]); err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseTopInvalid", err.Error(), commandTop))
+			return
+		}
+	}
+
+	to := time.Now()
+	from := to.Add(-period)
+	records, err := b.historyStore.Range(from, to)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get alert history from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseHistoryFail"))
+		return
+	}
+
+	report := newReport(records, from, to)
+	if len(report.Alerts) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseHistoryEmpty", model.Duration(period).String()))
+		return
+	}
+
+	if _, err := b.telegram.Reply(message, renderTop(p, period, report), &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send top alerts", "err", err)
+	}
+
+}
@@ -0,0 +1,179 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestHistory(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "history"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewHistoryStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewHistoryStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	// Start from an empty history, the bucket outlives test runs
+	assert.NoError(t, s.Prune(time.Now().Add(time.Hour)))
+
+	startsAt := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	labels := vendor.KV{"alertname": "A"}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: status transitions are recorded once
+	// ---------------------------------------------------------------------------
+	firing := vendor.Alert{Status: "firing", Labels: labels, StartsAt: startsAt}
+	resolved := vendor.Alert{Status: "resolved", Labels: labels, StartsAt: startsAt, EndsAt: startsAt.Add(time.Hour)}
+
+	assert.NoError(t, s.Record(startsAt, firing))
+	assert.NoError(t, s.Record(startsAt.Add(time.Minute), firing))
+	assert.NoError(t, s.Record(startsAt.Add(time.Hour), resolved))
+
+	records, err := s.Range(startsAt, startsAt.Add(2*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.True(t, records[0].Resolved())
+		assert.Equal(t, []Transition{
+			{Status: "firing", At: startsAt},
+			{Status: "resolved", At: startsAt.Add(time.Hour)},
+		}, records[0].Transitions)
+	}
+	t.Log("Record() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: another firing of the same alert is another record
+	// ---------------------------------------------------------------------------
+	again := vendor.Alert{Status: "firing", Labels: labels, StartsAt: startsAt.Add(3 * time.Hour)}
+	assert.NoError(t, s.Record(again.StartsAt, again))
+
+	records, err = s.Range(startsAt.Add(2*time.Hour), startsAt.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	records, err = s.Range(startsAt, startsAt.Add(4*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	t.Log("Range() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only resolved alerts are pruned
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Prune(startsAt.Add(5*time.Hour)))
+	records, err = s.Range(startsAt, startsAt.Add(4*time.Hour))
+	assert.NoError(t, err)
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, again.StartsAt, records[0].StartsAt.UTC())
+	}
+	t.Log("Prune() : Test 1 PASSED.")
+
+}
+
+func TestAlertRecordFiringTime(t *testing.T) {
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	for i, c := range []struct {
+		record AlertRecord
+		want   time.Duration
+	}{
+		{AlertRecord{Status: "resolved", StartsAt: from.Add(time.Hour), EndsAt: from.Add(3 * time.Hour)}, 2 * time.Hour},
+		{AlertRecord{Status: "resolved", StartsAt: from.Add(-time.Hour), EndsAt: from.Add(time.Hour)}, time.Hour},
+		{AlertRecord{Status: "firing", StartsAt: to.Add(-time.Hour), EndsAt: to.Add(time.Hour)}, time.Hour},
+		{AlertRecord{Status: "resolved", StartsAt: from.Add(-2 * time.Hour), EndsAt: from.Add(-time.Hour)}, 0},
+	} {
+		if got := c.record.FiringTime(from, to); got != c.want {
+			t.Errorf("FiringTime() : Test %d FAILED, want %s, got %s", i+1, c.want, got)
+		} else {
+			t.Logf("FiringTime() : Test %d PASSED.", i+1)
+		}
+	}
+
+}
+
+func TestParseHistoryQuery(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a fingerprint for the last 24h
+	// ---------------------------------------------------------------------------
+	q, err := parseHistoryQuery("1a2b3c4d5e6f7a8b")
+	assert.NoError(t, err)
+	assert.Equal(t, "1a2b3c4d5e6f7a8b", q.fingerprint)
+	assert.Equal(t, 24*time.Hour, q.period)
+	t.Log("parseHistoryQuery() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: matchers and a period
+	// ---------------------------------------------------------------------------
+	q, err = parseHistoryQuery(`alertname="NodeDown", instance=~"web.*" 3d`)
+	assert.NoError(t, err)
+	assert.Empty(t, q.fingerprint)
+	assert.Len(t, q.matchers, 2)
+	assert.Equal(t, 3*day, q.period)
+	t.Log("parseHistoryQuery() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid queries
+	// ---------------------------------------------------------------------------
+	for _, s := range []string{"", "3d", "NodeDown"} {
+		_, err := parseHistoryQuery(s)
+		assert.Error(t, err, s)
+	}
+	t.Log("parseHistoryQuery() : Test 3 PASSED.")
+
+}
+
+func TestHistoryTimeline(t *testing.T) {
+
+	now := time.Date(2021, 3, 10, 15, 0, 0, 0, time.UTC)
+	records := []AlertRecord{
+		{
+			Fingerprint: "a", Status: "resolved", Labels: vendor.KV{"alertname": "A", "instance": "web-1"},
+			StartsAt: now.Add(-30 * time.Hour), EndsAt: now.Add(-2 * time.Hour),
+			Transitions: []Transition{
+				{Status: "firing", At: now.Add(-30 * time.Hour)},
+				{Status: "resolved", At: now.Add(-2 * time.Hour)},
+			},
+		},
+		{
+			Fingerprint: "b", Status: "firing", Labels: vendor.KV{"alertname": "B", "instance": "db-1"},
+			StartsAt:    now.Add(-time.Hour),
+			Transitions: []Transition{{Status: "firing", At: now.Add(-time.Hour)}},
+		},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the transitions within the period, the most recent first
+	// ---------------------------------------------------------------------------
+	q, _ := parseHistoryQuery(`instance=~".+"`)
+	matching, events := historyTimeline(records, q, now.Add(-q.period), now)
+	assert.Len(t, matching, 2)
+	if assert.Len(t, events, 2) {
+		assert.Equal(t, "B", events[0].Alertname)
+		assert.Equal(t, "resolved", events[1].Status)
+	}
+	t.Log("historyTimeline() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only the alert of the fingerprint
+	// ---------------------------------------------------------------------------
+	matching, events = historyTimeline(records, historyQuery{fingerprint: "a", period: 48 * time.Hour}, now.Add(-48*time.Hour), now)
+	assert.Len(t, matching, 1)
+	assert.Len(t, events, 2)
+	t.Log("historyTimeline() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,605 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramIncidentsDirectory = "telegram/incidents"
+
+// Kinds of the events of an incident timeline
+const (
+	incidentOpened   = "opened"
+	incidentFiring   = "firing"
+	incidentResolved = "resolved"
+	incidentSilenced = "silenced"
+	incidentAcked    = "acked"
+	incidentNote     = "note"
+	incidentClosed   = "closed"
+)
+
+// incidentEventResponses are the lines the kinds of events are shown with
+var incidentEventResponses = map[string]string{
+	incidentOpened:   "incidentEventOpened",
+	incidentFiring:   "incidentEventFiring",
+	incidentResolved: "incidentEventResolved",
+	incidentSilenced: "incidentEventSilenced",
+	incidentAcked:    "incidentEventAcked",
+	incidentNote:     "incidentEventNote",
+	incidentClosed:   "incidentEventClosed",
+}
+
+// IncidentEvent is an entry of the timeline of an incident
+type IncidentEvent struct {
+	At          time.Time `json:"at"`
+	Kind        string    `json:"kind"`
+	By          string    `json:"by,omitempty"`
+	Alertname   string    `json:"alertname,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Text        string    `json:"text,omitempty"`
+}
+
+// IncidentAlert is an alert attached to an incident with its last status
+type IncidentAlert struct {
+	Fingerprint string    `json:"fingerprint"`
+	Labels      vendor.KV `json:"labels"`
+	Status      string    `json:"status"`
+}
+
+// Incident groups the alerts of an outage in a chat with a timeline, a chat has at most one open incident
+type Incident struct {
+	ChatID   int64     `json:"chatId"`
+	Title    string    `json:"title"`
+	OpenedAt time.Time `json:"openedAt"`
+	OpenedBy string    `json:"openedBy"`
+	// MessageID of the message the incident was opened with, replies to it are notes
+	MessageID int `json:"messageId"`
+	// Matchers attach the alerts matching them as they are received
+	Matchers []string        `json:"matchers,omitempty"`
+	Alerts   []IncidentAlert `json:"alerts,omitempty"`
+	Timeline []IncidentEvent `json:"timeline"`
+}
+
+// has returns whether the alert of the fingerprint is attached to the incident
+func (i *Incident) has(fingerprint string) bool {
+	for _, a := range i.Alerts {
+		if a.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns whether the labels match the matchers of the incident
+func (i *Incident) matches(labels vendor.KV) bool {
+	if len(i.Matchers) == 0 {
+		return false
+	}
+	matchers, err := vendor.ParseMatchers(strings.Join(i.Matchers, ","))
+	if err != nil {
+		return false
+	}
+	return vendor.Matchers(matchers).Matches(labelSet(labels))
+}
+
+// attach adds the alert to the incident or updates its status, the changes of
+// status are added to the timeline. It returns whether the incident changed.
+func (i *Incident) attach(alert vendor.Alert, at time.Time) bool {
+
+	fingerprint := alertFingerprint(alert)
+
+	n := -1
+	for j, a := range i.Alerts {
+		if a.Fingerprint == fingerprint {
+			n = j
+			break
+		}
+	}
+	if n == -1 {
+		i.Alerts = append(i.Alerts, IncidentAlert{Fingerprint: fingerprint, Labels: alert.Labels})
+		n = len(i.Alerts) - 1
+	}
+	if i.Alerts[n].Status == alert.Status {
+		return false
+	}
+	i.Alerts[n].Status = alert.Status
+
+	kind := incidentFiring
+	if alert.Status == string(model.AlertResolved) {
+		kind = incidentResolved
+	}
+	i.Timeline = append(i.Timeline, IncidentEvent{
+		At:          at,
+		Kind:        kind,
+		Alertname:   alert.Labels["alertname"],
+		Fingerprint: fingerprint,
+	})
+
+	return true
+}
+
+// IncidentStore writes the open incidents of the chats to a libkv store backend
+type IncidentStore struct {
+	kv store.Store
+}
+
+// NewIncidentStore stores the incidents in the provided kv backend
+func NewIncidentStore(kv store.Store) (*IncidentStore, error) {
+	return &IncidentStore{kv: kv}, nil
+}
+
+// List the open incidents of all chats
+func (s *IncidentStore) List() ([]Incident, error) {
+
+	kvPairs, err := s.kv.List(telegramIncidentsDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var incidents []Incident
+	for _, kv := range kvPairs {
+		var i Incident
+		if err := json.Unmarshal(kv.Value, &i); err != nil {
+			return nil, err
+		}
+		incidents = append(incidents, i)
+	}
+
+	return incidents, nil
+}
+
+// Get the open incident of a chat, nil if there is none
+func (s *IncidentStore) Get(chatID int64) (*Incident, error) {
+
+	key := fmt.Sprintf("%s/%d", telegramIncidentsDirectory, chatID)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var i Incident
+	if err := json.Unmarshal(kv.Value, &i); err != nil {
+		return nil, err
+	}
+
+	return &i, nil
+}
+
+// Set the open incident of a chat
+func (s *IncidentStore) Set(i Incident) error {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d", telegramIncidentsDirectory, i.ChatID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove the open incident of a chat
+func (s *IncidentStore) Remove(chatID int64) error {
+	key := fmt.Sprintf("%s/%d", telegramIncidentsDirectory, chatID)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// incidentEventText renders an event of the timeline as Markdown
+func incidentEventText(p *translation.Printer, e IncidentEvent) string {
+	switch e.Kind {
+	case incidentOpened, incidentClosed:
+		return p.Sprintf(incidentEventResponses[e.Kind], e.By)
+	case incidentFiring, incidentResolved:
+		return p.Sprintf(incidentEventResponses[e.Kind], e.Alertname, e.Fingerprint)
+	case incidentSilenced:
+		return p.Sprintf(incidentEventResponses[e.Kind], e.Alertname, e.Fingerprint, e.Text)
+	case incidentAcked:
+		return p.Sprintf(incidentEventResponses[e.Kind], e.Alertname, e.Fingerprint, e.By)
+	case incidentNote:
+		return p.Sprintf(incidentEventResponses[e.Kind], e.By, e.Text)
+	}
+	return e.Text
+}
+
+// renderPostmortem renders the Markdown postmortem skeleton of a closed incident
+func renderPostmortem(p *translation.Printer, location *time.Location, i Incident, closedAt time.Time, closedBy string) string {
+
+	const timeFormat = "2006-01-02 15:04 MST"
+
+	var alerts []string
+	for _, a := range i.Alerts {
+		alerts = append(alerts, fmt.Sprintf("- `%s` `%s`: %s", a.Fingerprint, labelSet(a.Labels).String(), a.Status))
+	}
+	if len(alerts) == 0 {
+		alerts = append(alerts, p.Sprintf("postmortemNoAlerts"))
+	}
+
+	var timeline []string
+	for _, e := range i.Timeline {
+		timeline = append(timeline, fmt.Sprintf("- %s: %s", e.At.In(location).Format(timeFormat), incidentEventText(p, e)))
+	}
+
+	return p.Sprintf("postmortemIncident",
+		i.Title,
+		i.OpenedAt.In(location).Format(timeFormat), i.OpenedBy,
+		closedAt.In(location).Format(timeFormat), closedBy,
+		closedAt.Sub(i.OpenedAt).Round(time.Minute).String(),
+		strings.Join(alerts, "\n"),
+		strings.Join(timeline, "\n"),
+	)
+}
+
+// webhookAlert converts an alert of Alertmanager to the alert of a webhook
+func webhookAlert(alert *types.Alert) vendor.Alert {
+	labels := vendor.KV{}
+	for k, v := range alert.Labels {
+		labels[string(k)] = string(v)
+	}
+	return vendor.Alert{
+		Status:      string(alert.Status()),
+		Labels:      labels,
+		StartsAt:    alert.StartsAt,
+		Fingerprint: alert.Fingerprint().String(),
+	}
+}
+
+// trackIncidents attaches the alerts received to the open incidents they are attached to or matching
+func (b *Bot) trackIncidents(alerts vendor.Alerts, now time.Time) {
+
+	if b.incidentStore == nil {
+		return
+	}
+
+	b.incidentsMu.Lock()
+	defer b.incidentsMu.Unlock()
+
+	incidents, err := b.incidentStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get incidents from store", "err", err)
+		return
+	}
+
+	for _, i := range incidents {
+		changed := false
+		for _, alert := range alerts {
+			if i.has(alertFingerprint(alert)) || i.matches(alert.Labels) {
+				changed = i.attach(alert, now) || changed
+			}
+		}
+		if !changed {
+			continue
+		}
+		if err := b.incidentStore.Set(i); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+		}
+	}
+}
+
+// recordIncidentEvent adds the event about an alert to the open incidents the alert is attached to
+func (b *Bot) recordIncidentEvent(fingerprint string, e IncidentEvent) {
+
+	if b.incidentStore == nil {
+		return
+	}
+
+	b.incidentsMu.Lock()
+	defer b.incidentsMu.Unlock()
+
+	incidents, err := b.incidentStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get incidents from store", "err", err)
+		return
+	}
+
+	for _, i := range incidents {
+		for _, a := range i.Alerts {
+			if a.Fingerprint != fingerprint {
+				continue
+			}
+			e.At, e.Fingerprint, e.Alertname = time.Now(), fingerprint, a.Labels["alertname"]
+			i.Timeline = append(i.Timeline, e)
+			if err := b.incidentStore.Set(i); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+			}
+			break
+		}
+	}
+}
+
+// errIncidentClosed is returned when the incident was closed or opened again while it was being changed
+var errIncidentClosed = errors.New("the incident was closed meanwhile")
+
+// updateIncident changes the open incident of the chat and saves it, the incidents are changed one
+// at a time so the lock is held for the store only. It returns the incident changed, nil if there is none.
+func (b *Bot) updateIncident(chatID int64, change func(i *Incident) error) (*Incident, error) {
+
+	b.incidentsMu.Lock()
+	defer b.incidentsMu.Unlock()
+
+	i, err := b.incidentStore.Get(chatID)
+	if err != nil || i == nil {
+		return nil, err
+	}
+	if err := change(i); err != nil {
+		return nil, err
+	}
+
+	return i, b.incidentStore.Set(*i)
+}
+
+// incidentNote records a reply to the message an incident was opened with as a note,
+// it returns whether the message was such a reply
+func (b *Bot) incidentNote(message *telebot.Message) bool {
+
+	if b.incidentStore == nil || message.ReplyTo == nil || strings.HasPrefix(message.Text, "/") {
+		return false
+	}
+
+	i, err := b.updateIncident(message.Chat.ID, func(i *Incident) error {
+		if i.MessageID != message.ReplyTo.ID {
+			return errIncidentClosed
+		}
+		i.Timeline = append(i.Timeline, IncidentEvent{At: time.Now(), Kind: incidentNote, By: userName(message.Sender), Text: message.Text})
+		return nil
+	})
+	if err == errIncidentClosed || err == nil && i == nil {
+		return false
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+		return false
+	}
+
+	b.telegram.Reply(message, b.printer(message.Chat, message.Sender).Sprintf("responseIncidentNoted"))
+	return true
+}
+
+// incidentAlerts returns the alerts to attach to an incident: the alerts of the message replied to, or
+// the firing alerts matching the matchers, which are returned to attach the alerts received later too
+func (b *Bot) incidentAlerts(message *telebot.Message, args []string) (vendor.Alerts, []string, error) {
+
+	if len(args) == 0 {
+		if message.ReplyTo == nil || b.messageStore == nil {
+			return nil, nil, errors.New("reply to an alert message or give matchers")
+		}
+		m, err := b.messageStore.Get(message.Chat.ID, message.ReplyTo.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if m.Data == nil {
+			return nil, nil, nil
+		}
+		return m.Data.Alerts, nil, nil
+	}
+
+	matchers, err := vendor.ParseMatchers(strings.Join(args, ","))
+	if err != nil {
+		return nil, nil, err
+	}
+	var added []string
+	for _, m := range matchers {
+		added = append(added, m.String())
+	}
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	var matching vendor.Alerts
+	for _, alert := range alerts {
+		if alert.Resolved() || !vendor.Matchers(matchers).Matches(alert.Labels) {
+			continue
+		}
+		matching = append(matching, webhookAlert(alert))
+	}
+
+	return matching, added, nil
+}
+
+// openIncident saves the incident opened unless the chat got one meanwhile
+func (b *Bot) openIncident(i Incident) error {
+
+	b.incidentsMu.Lock()
+	defer b.incidentsMu.Unlock()
+
+	open, err := b.incidentStore.Get(i.ChatID)
+	if err != nil {
+		return err
+	}
+	if open != nil {
+		return fmt.Errorf("%q is already open", open.Title)
+	}
+
+	return b.incidentStore.Set(i)
+}
+
+// closeIncident removes the incident of the chat
+func (b *Bot) closeIncident(chatID int64) error {
+
+	b.incidentsMu.Lock()
+	defer b.incidentsMu.Unlock()
+
+	return b.incidentStore.Remove(chatID)
+}
+
+// Show, open or close the incident of this chat, attach alerts or add notes to it
+func (b *Bot) handleIncident(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.incidentStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+		return
+	}
+
+	i, err := b.incidentStore.Get(message.Chat.ID)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get incident from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+		return
+	}
+
+	usage := p.Sprintf("responseIncidentUsage", commandIncident, commandIncident, commandIncident, commandIncident)
+	location := b.location(message.Chat)
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		if i == nil {
+			b.telegram.Reply(message, p.Sprintf("responseIncidentNone")+"\n"+usage)
+			return
+		}
+		firing := 0
+		for _, a := range i.Alerts {
+			if a.Status == string(model.AlertFiring) {
+				firing++
+			}
+		}
+		b.telegram.Reply(message, p.Sprintf("responseIncident", i.Title,
+			i.OpenedAt.In(location).Format("2006-01-02 15:04 MST"), i.OpenedBy,
+			len(i.Alerts), firing, len(i.Timeline), strings.Join(i.Matchers, ", "))+"\n"+usage)
+		return
+	}
+
+	if args[0] != "open" && i == nil {
+		b.telegram.Reply(message, p.Sprintf("responseIncidentNone")+"\n"+usage)
+		return
+	}
+
+	switch args[0] {
+
+	case "open":
+		if i != nil {
+			b.telegram.Reply(message, p.Sprintf("responseIncidentAlreadyOpen", i.Title))
+			return
+		}
+		title := strings.TrimSpace(strings.Join(args[1:], " "))
+		if title == "" {
+			b.telegram.Reply(message, usage)
+			return
+		}
+
+		now := time.Now()
+		i = &Incident{
+			ChatID:   message.Chat.ID,
+			Title:    title,
+			OpenedAt: now,
+			OpenedBy: userName(message.Sender),
+			Timeline: []IncidentEvent{{At: now, Kind: incidentOpened, By: userName(message.Sender), Text: title}},
+		}
+
+		// Replies to this message are the notes of the incident
+		sent, err := b.telegram.Reply(message, p.Sprintf("responseIncidentOpened", title, commandIncident))
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send incident opened", "err", err)
+			return
+		}
+		i.MessageID = sent.ID
+
+		if err := b.openIncident(*i); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+			return
+		}
+
+	case "attach":
+		alerts, matchers, err := b.incidentAlerts(message, args[1:])
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseIncidentInvalid", err.Error())+"\n"+usage)
+			return
+		}
+		now := time.Now()
+		i, err = b.updateIncident(message.Chat.ID, func(i *Incident) error {
+			i.Matchers = append(i.Matchers, matchers...)
+			for _, alert := range alerts {
+				i.attach(alert, now)
+			}
+			return nil
+		})
+		if err != nil || i == nil {
+			level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseIncidentAttached", len(alerts), i.Title))
+
+	case "note":
+		text := strings.TrimSpace(strings.Join(args[1:], " "))
+		if text == "" {
+			b.telegram.Reply(message, usage)
+			return
+		}
+		i, err = b.updateIncident(message.Chat.ID, func(i *Incident) error {
+			i.Timeline = append(i.Timeline, IncidentEvent{At: time.Now(), Kind: incidentNote, By: userName(message.Sender), Text: text})
+			return nil
+		})
+		if err != nil || i == nil {
+			level.Warn(b.logger).Log("msg", "failed to save incident to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseIncidentNoted"))
+
+	case "close":
+		now := time.Now()
+		i.Timeline = append(i.Timeline, IncidentEvent{At: now, Kind: incidentClosed, By: userName(message.Sender)})
+
+		postmortem := renderPostmortem(p, location, *i, now, userName(message.Sender))
+		document := &telebot.Document{
+			File:     telebot.FromReader(strings.NewReader(postmortem)),
+			FileName: fmt.Sprintf("incident-%s.md", i.OpenedAt.In(location).Format("2006-01-02-1504")),
+			MIME:     "text/markdown",
+			Caption:  p.Sprintf("responseIncidentClosed", i.Title),
+		}
+		if _, err := b.telegram.Reply(message, document); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send incident postmortem", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseIncidentFail"))
+			return
+		}
+		if err := b.closeIncident(message.Chat.ID); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove incident from store", "err", err)
+		}
+
+		level.Info(b.logger).Log(
+			"msg", "user closed incident",
+			"username", message.Sender.Username,
+			"user_id", message.Sender.ID,
+			"title", i.Title,
+		)
+		return
+
+	default:
+		b.telegram.Reply(message, p.Sprintf("responseIncidentInvalid", fmt.Sprintf("unknown action %q", args[0]))+"\n"+usage)
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user changed incident",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"action", args[0],
+	)
+
+}
@@ -0,0 +1,192 @@
+package telegram
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestIncidents(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "incidents"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewIncidentStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewIncidentStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	bot := &Bot{logger: log.NewNopLogger(), incidentStore: s}
+
+	assert.NoError(t, s.Remove(1))
+	assert.NoError(t, s.Remove(2))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: no open incident
+	// ---------------------------------------------------------------------------
+	i, err := s.Get(1)
+	assert.NoError(t, err)
+	assert.Nil(t, i)
+	t.Log("IncidentStore.Get() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: alerts matching an incident are attached, their changes recorded once
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Set(Incident{ChatID: 1, Title: "Database outage", Matchers: []string{`team="db"`}}))
+	assert.NoError(t, s.Set(Incident{ChatID: 2, Title: "Web outage"}))
+
+	db := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "DBDown", "team": "db"}, Fingerprint: "a"}
+	web := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "WebDown", "team": "web"}, Fingerprint: "b"}
+	now := time.Now()
+
+	bot.trackIncidents(vendor.Alerts{db, web}, now)
+	bot.trackIncidents(vendor.Alerts{db, web}, now.Add(time.Minute))
+	db.Status = "resolved"
+	bot.trackIncidents(vendor.Alerts{db}, now.Add(2*time.Minute))
+
+	i, err = s.Get(1)
+	assert.NoError(t, err)
+	if assert.NotNil(t, i) && assert.Len(t, i.Alerts, 1) && assert.Len(t, i.Timeline, 2) {
+		assert.Equal(t, "resolved", i.Alerts[0].Status)
+		assert.Equal(t, incidentFiring, i.Timeline[0].Kind)
+		assert.Equal(t, incidentResolved, i.Timeline[1].Kind)
+	}
+	other, _ := s.Get(2)
+	if assert.NotNil(t, other) {
+		assert.Empty(t, other.Alerts)
+	}
+	t.Log("trackIncidents() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: events about attached alerts only
+	// ---------------------------------------------------------------------------
+	bot.recordIncidentEvent("a", IncidentEvent{Kind: incidentAcked, By: "@alice"})
+	bot.recordIncidentEvent("b", IncidentEvent{Kind: incidentAcked, By: "@bob"})
+
+	i, _ = s.Get(1)
+	if assert.NotNil(t, i) && assert.Len(t, i.Timeline, 3) {
+		assert.Equal(t, "DBDown", i.Timeline[2].Alertname)
+		assert.Equal(t, "@alice", i.Timeline[2].By)
+	}
+	other, _ = s.Get(2)
+	if assert.NotNil(t, other) {
+		assert.Empty(t, other.Timeline)
+	}
+	t.Log("recordIncidentEvent() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: alerts received while events are recorded are all kept
+	// ---------------------------------------------------------------------------
+	var wg sync.WaitGroup
+	for n := 0; n < 10; n++ {
+		wg.Add(2)
+		alert := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "DBSlow", "team": "db"}, Fingerprint: fmt.Sprintf("c%d", n)}
+		go func() {
+			defer wg.Done()
+			bot.trackIncidents(vendor.Alerts{alert}, now)
+		}()
+		go func() {
+			defer wg.Done()
+			bot.recordIncidentEvent("a", IncidentEvent{Kind: incidentNote, By: "@alice"})
+		}()
+	}
+	wg.Wait()
+
+	i, _ = s.Get(1)
+	if assert.NotNil(t, i) {
+		assert.Len(t, i.Alerts, 11)
+		assert.Len(t, i.Timeline, 23)
+	}
+	t.Log("trackIncidents() : Test 4 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: incidents are changed and opened under the lock, the chats without one are left alone
+	// ---------------------------------------------------------------------------
+	i, err = bot.updateIncident(1, func(i *Incident) error {
+		i.Matchers = append(i.Matchers, `team="web"`)
+		return nil
+	})
+	assert.NoError(t, err)
+	stored, _ := s.Get(1)
+	if assert.NotNil(t, i) && assert.NotNil(t, stored) {
+		assert.Equal(t, []string{`team="db"`, `team="web"`}, stored.Matchers)
+	}
+	i, err = bot.updateIncident(3, func(i *Incident) error { return nil })
+	assert.NoError(t, err)
+	assert.Nil(t, i)
+	_, err = bot.updateIncident(1, func(i *Incident) error { return errIncidentClosed })
+	assert.Equal(t, errIncidentClosed, err)
+	assert.Error(t, bot.openIncident(Incident{ChatID: 1, Title: "Again"}))
+	t.Log("updateIncident() : Test 5 PASSED.")
+
+	list, err := s.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	assert.NoError(t, s.Remove(1))
+	assert.NoError(t, s.Remove(2))
+	i, _ = s.Get(1)
+	assert.Nil(t, i)
+	t.Log("IncidentStore.Remove() : Test 6 PASSED.")
+
+}
+
+func TestRenderPostmortem(t *testing.T) {
+
+	cat, _ := translation.NewCatalog(nil)
+	p := cat.Printer()
+	openedAt := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	i := Incident{
+		Title:    "Database outage",
+		OpenedAt: openedAt,
+		OpenedBy: "@alice",
+		Alerts:   []IncidentAlert{{Fingerprint: "a", Labels: vendor.KV{"alertname": "DBDown"}, Status: "resolved"}},
+		Timeline: []IncidentEvent{
+			{At: openedAt, Kind: incidentOpened, By: "@alice"},
+			{At: openedAt.Add(time.Minute), Kind: incidentNote, By: "@bob", Text: "failed over"},
+		},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the events are rendered in the order of the timeline
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "incidentEventNote", incidentEventText(p, i.Timeline[1]))
+	out := renderPostmortem(p, time.UTC, i, openedAt.Add(90*time.Minute), "@bob")
+	assert.Equal(t, "postmortemIncident", out)
+	t.Log("renderPostmortem() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: with the translations
+	// ---------------------------------------------------------------------------
+	cat, err := translation.NewCatalog(map[string]translation.Dictionary{"en": {
+		"incidentEventOpened": {Text: "Incident opened by %s"},
+		"incidentEventNote":   {Text: "📝 %s: %s"},
+		"postmortemIncident":  {Text: "# %s\n%s %s\n%s %s\n%s\n%s\n%s"},
+	}})
+	if assert.NoError(t, err) {
+		out = renderPostmortem(cat.Printer(), time.UTC, i, openedAt.Add(90*time.Minute), "@bob")
+		assert.Contains(t, out, "# Database outage")
+		assert.Contains(t, out, "1h30m0s")
+		assert.Contains(t, out, "- `a` `{alertname=\"DBDown\"}`: resolved")
+		assert.Contains(t, out, "- 2021-03-10 12:01 UTC: 📝 @bob: failed over")
+	}
+	t.Log("renderPostmortem() : Test 2 PASSED.")
+
+}
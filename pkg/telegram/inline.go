@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"strings"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// inlineResults is how many alerts an inline query returns at most
+	inlineResults = 20
+	// inlineCacheTime is how many seconds Telegram may cache the results of an inline query
+	inlineCacheTime = 10
+)
+
+// inlineQuery is what an inline query searches the alerts for: matchers and words
+// the label values of the alerts contain
+type inlineQuery struct {
+	matchers vendor.Matchers
+	words    []string
+}
+
+// parseInlineQuery parses the text of an inline query, tokens like team="db" are matchers
+// and the others are searched for in the label values
+func parseInlineQuery(s string) (inlineQuery, error) {
+
+	var (
+		q        inlineQuery
+		matchers []string
+	)
+	for _, token := range splitQuoted(s) {
+		if token = strings.Trim(token, ","); token == "" {
+			continue
+		}
+		if strings.ContainsAny(token, "=~") {
+			matchers = append(matchers, token)
+		} else {
+			q.words = append(q.words, strings.ToLower(token))
+		}
+	}
+
+	if len(matchers) > 0 {
+		ms, err := vendor.ParseMatchers(strings.Join(matchers, ","))
+		if err != nil {
+			return q, err
+		}
+		q.matchers = ms
+	}
+
+	return q, nil
+}
+
+// matches returns whether the labels match the matchers and contain every word in their values
+func (q inlineQuery) matches(labels model.LabelSet) bool {
+
+	if !q.matchers.Matches(labels) {
+		return false
+	}
+
+	for _, word := range q.words {
+		found := false
+		for _, v := range labels {
+			if strings.Contains(strings.ToLower(string(v)), word) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// inlineAlerts returns the firing alerts matching the query, the most recent first
+func inlineAlerts(alerts []*types.Alert, q inlineQuery) []*types.Alert {
+
+	var firing []*types.Alert
+	for _, alert := range alerts {
+		if !alert.Resolved() && q.matches(alert.Labels) {
+			firing = append(firing, alert)
+		}
+	}
+
+	firing = filterAlerts(firing, alertsQuery{})
+	if len(firing) > inlineResults {
+		firing = firing[:inlineResults]
+	}
+
+	return firing
+}
+
+// inlineDescription returns the short description of an alert in the inline results:
+// its summary or else its labels besides the alertname
+func inlineDescription(alert *types.Alert) string {
+
+	if summary := alert.Annotations["summary"]; summary != "" {
+		return string(summary)
+	}
+
+	labels := alert.Labels.Clone()
+	delete(labels, model.AlertNameLabel)
+
+	return labels.String()
+}
+
+// silenceButtons returns the buttons of the silence presets the user may use for the alert of the fingerprint
+func (b *Bot) silenceButtons(user *telebot.User, fingerprint string) []telebot.InlineButton {
+	var buttons []telebot.InlineButton
+	for _, preset := range b.silencePresets {
+		if preset.Button && b.hasRole(user.ID, preset.Role) {
+			buttons = append(buttons, presetButton(preset, fingerprint))
+		}
+	}
+	return buttons
+}
+
+// privateChat returns the private chat of the bot with the user
+func privateChat(user *telebot.User) *telebot.Chat {
+	return &telebot.Chat{ID: int64(user.ID), Type: telebot.ChatPrivate, Username: user.Username, FirstName: user.FirstName, LastName: user.LastName}
+}
+
+// callbackChat returns the chat of the message of the button, the private chat of the user for
+// the buttons of messages sent inline, which only have an inline message ID
+func callbackChat(c *telebot.Callback) *telebot.Chat {
+	if c.Message != nil && c.Message.Chat != nil {
+		return c.Message.Chat
+	}
+	return privateChat(c.Sender)
+}
+
+// handleInlineQuery answers an inline query with the firing alerts it matches, each inserting
+// the alert rendered like the alert messages with the buttons silencing it
+func (b *Bot) handleInlineQuery(q *telebot.Query) {
+
+	response := &telebot.QueryResponse{CacheTime: inlineCacheTime, IsPersonal: true}
+
+	// The results of the others are empty, like the commands they aren't answered
+	if !b.isAdminID(q.From.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		if err := b.telegram.Answer(q, response); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to answer inline query", "err", err)
+		}
+		return
+	}
+	b.commandsCounter.WithLabelValues("inline").Inc()
+
+	query, err := parseInlineQuery(q.Text)
+	if err != nil {
+		level.Debug(b.logger).Log("msg", "failed to parse inline query", "query", q.Text, "err", err)
+		b.telegram.Answer(q, response)
+		return
+	}
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to list alerts", "err", err)
+		b.telegram.Answer(q, response)
+		return
+	}
+
+	// Inline queries have no chat, the private chat of the user tells the language and timezone
+	chat := privateChat(&q.From)
+	p := b.printer(chat, &q.From)
+
+	for _, alert := range inlineAlerts(alerts, query) {
+		out, err := b.tmplAlerts(p, b.location(chat), alert)
+		if err != nil {
+			continue
+		}
+
+		result := &telebot.ArticleResult{
+			Title:       string(alert.Name()),
+			Description: inlineDescription(alert),
+		}
+		result.SetResultID(alert.Fingerprint().String())
+		result.SetContent(&telebot.InputTextMessageContent{
+			Text:           b.truncateMessage(out),
+			ParseMode:      telebot.ModeHTML,
+			DisablePreview: true,
+		})
+		if buttons := b.silenceButtons(&q.From, alert.Fingerprint().String()); len(buttons) > 0 {
+			result.SetReplyMarkup([][]telebot.InlineButton{buttons})
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	if err := b.telegram.Answer(q, response); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to answer inline query", "err", err)
+	}
+	level.Debug(b.logger).Log("msg", "answered inline query", "query", q.Text, "results", len(response.Results))
+
+}
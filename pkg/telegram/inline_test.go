@@ -0,0 +1,144 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestInlineQuery(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: matchers and words
+	// ---------------------------------------------------------------------------
+	q, err := parseInlineQuery(`DB severity="critical", team=~"db|storage"`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db"}, q.words)
+	assert.Len(t, q.matchers, 2)
+
+	q, err = parseInlineQuery("")
+	assert.NoError(t, err)
+	assert.Empty(t, q.words)
+	assert.Empty(t, q.matchers)
+
+	_, err = parseInlineQuery(`team=~"("`)
+	assert.Error(t, err)
+	t.Log("parseInlineQuery() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: every word is in a label value and the matchers match
+	// ---------------------------------------------------------------------------
+	labels := model.LabelSet{"alertname": "DBDown", "instance": "db-1", "severity": "critical"}
+	for i, c := range []struct {
+		query   string
+		matches bool
+	}{
+		{"", true},
+		{"db", true},
+		{"DOWN db-1", true},
+		{"db web", false},
+		{`db severity="critical"`, true},
+		{`severity="warning"`, false},
+		{"severity", false},
+	} {
+		q, err := parseInlineQuery(c.query)
+		if assert.NoError(t, err) && assert.Equal(t, c.matches, q.matches(labels), c.query) {
+			t.Logf("inlineQuery.matches() : Test %d PASSED.", i+2)
+		}
+	}
+
+}
+
+func TestInlineAlerts(t *testing.T) {
+
+	now := time.Now()
+	alert := func(name, instance string, startsAt time.Time, resolved bool) *types.Alert {
+		a := &types.Alert{}
+		a.Labels = model.LabelSet{"alertname": model.LabelValue(name), "instance": model.LabelValue(instance)}
+		a.StartsAt = startsAt
+		if resolved {
+			a.EndsAt = now.Add(-time.Minute)
+		}
+		return a
+	}
+	older := alert("DBDown", "db-1", now.Add(-2*time.Hour), false)
+	newer := alert("DBSlow", "db-2", now.Add(-time.Hour), false)
+	resolved := alert("DBDown", "db-3", now.Add(-3*time.Hour), true)
+	web := alert("WebDown", "web-1", now, false)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the firing alerts matching, the most recent first
+	// ---------------------------------------------------------------------------
+	q, _ := parseInlineQuery("db")
+	assert.Equal(t, []*types.Alert{newer, older}, inlineAlerts([]*types.Alert{older, resolved, web, newer}, q))
+	t.Log("inlineAlerts() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: at most a page of results
+	// ---------------------------------------------------------------------------
+	var many []*types.Alert
+	for i := 0; i < inlineResults+5; i++ {
+		many = append(many, alert("DBDown", string(rune('a'+i)), now.Add(-time.Duration(i)*time.Minute), false))
+	}
+	assert.Len(t, inlineAlerts(many, inlineQuery{}), inlineResults)
+	t.Log("inlineAlerts() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the summary or else the labels describe an alert
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, `{instance="db-1"}`, inlineDescription(older))
+	older.Annotations = model.LabelSet{"summary": "db-1 is down"}
+	assert.Equal(t, "db-1 is down", inlineDescription(older))
+	assert.Equal(t, model.LabelValue("DBDown"), older.Labels["alertname"])
+	t.Log("inlineDescription() : Test 3 PASSED.")
+
+}
+
+func TestInlineButtons(t *testing.T) {
+
+	bot := &Bot{
+		logger: log.NewNopLogger(),
+		silencePresets: []SilencePreset{
+			{Command: "/s2h", Duration: model.Duration(2 * time.Hour), Button: true},
+			{Command: "/s1d", Duration: model.Duration(24 * time.Hour)},
+			{Command: "/s2w", Duration: model.Duration(14 * day), Button: true, Role: "leads"},
+		},
+		roles: map[string][]int{"leads": {42}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the buttons of the presets the user has the role of
+	// ---------------------------------------------------------------------------
+	buttons := bot.silenceButtons(&telebot.User{ID: 1}, "a")
+	if assert.Len(t, buttons, 1) {
+		assert.Equal(t, "s2h a", buttons[0].Data)
+	}
+	buttons = bot.silenceButtons(&telebot.User{ID: 42}, "a")
+	if assert.Len(t, buttons, 2) {
+		assert.Equal(t, "s2w a", buttons[1].Data)
+		assert.Equal(t, "🔕 2w", buttons[1].Text)
+	}
+	t.Log("silenceButtons() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the buttons of inline messages have a message without a chat
+	// ---------------------------------------------------------------------------
+	chat := &telebot.Chat{ID: -100}
+	assert.Equal(t, chat, callbackChat(&telebot.Callback{Message: &telebot.Message{Chat: chat}, Sender: &telebot.User{ID: 42}}))
+	inline := &telebot.Callback{MessageID: "BAAAAG", Message: &telebot.Message{InlineID: "BAAAAG"}, Sender: &telebot.User{ID: 42}}
+	if assert.NotNil(t, callbackChat(inline)) {
+		assert.Equal(t, int64(42), callbackChat(inline).ID)
+	}
+	assert.Equal(t, int64(42), callbackChat(&telebot.Callback{Sender: &telebot.User{ID: 42}}).ID)
+	t.Log("callbackChat() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,143 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+)
+
+const telegramMessagesDirectory = "telegram/messages"
+
+// messageRetention is how long sent alert messages are kept to be edited or replied to
+const messageRetention = 7 * 24 * time.Hour
+
+// SentMessage is an alert message sent to a chat, kept to edit it later and to know
+// which alerts it is about
+type SentMessage struct {
+	ChatID    int64        `json:"chatId"`
+	MessageID int          `json:"messageId"`
+	SentAt    time.Time    `json:"sentAt"`
+	Data      *vendor.Data `json:"data"`
+	// Parts is how many messages the rendered alerts were split into, only single
+	// messages are edited
+	Parts int `json:"parts"`
+}
+
+// Fingerprints returns the fingerprints of the alerts in the message
+func (m SentMessage) Fingerprints() []string {
+	var fingerprints []string
+	if m.Data == nil {
+		return fingerprints
+	}
+	for _, alert := range m.Data.Alerts {
+		fingerprints = append(fingerprints, alertFingerprint(alert))
+	}
+	return fingerprints
+}
+
+// MessageStore writes the sent alert messages to a libkv store backend
+type MessageStore struct {
+	kv store.Store
+}
+
+// NewMessageStore stores sent alert messages in the provided kv backend
+func NewMessageStore(kv store.Store) (*MessageStore, error) {
+	return &MessageStore{kv: kv}, nil
+}
+
+// Add a sent message to the kv backend
+func (s *MessageStore) Add(m SentMessage) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d/%d", telegramMessagesDirectory, m.ChatID, m.MessageID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Get a sent message, store.ErrKeyNotFound if it isn't an alert message or is too old
+func (s *MessageStore) Get(chatID int64, messageID int) (SentMessage, error) {
+	var m SentMessage
+
+	key := fmt.Sprintf("%s/%d/%d", telegramMessagesDirectory, chatID, messageID)
+
+	kv, err := s.kv.Get(key)
+	if err != nil {
+		return m, err
+	}
+
+	err = json.Unmarshal(kv.Value, &m)
+
+	return m, err
+}
+
+// ByFingerprint returns all messages sent about the alert with the given fingerprint
+func (s *MessageStore) ByFingerprint(fingerprint string) ([]SentMessage, error) {
+
+	messages, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []SentMessage
+	for _, m := range messages {
+		for _, fp := range m.Fingerprints() {
+			if fp == fingerprint {
+				found = append(found, m)
+				break
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// Prune removes the messages sent before the given time
+func (s *MessageStore) Prune(before time.Time) error {
+
+	messages, err := s.list()
+	if err != nil {
+		return err
+	}
+
+	for _, m := range messages {
+		if m.SentAt.After(before) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%d/%d", telegramMessagesDirectory, m.ChatID, m.MessageID)
+		if err := s.kv.Delete(key); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list all messages saved in the kv backend
+func (s *MessageStore) list() ([]SentMessage, error) {
+
+	kvPairs, err := s.kv.List(telegramMessagesDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []SentMessage
+	for _, kv := range kvPairs {
+		var m SentMessage
+		if err := json.Unmarshal(kv.Value, &m); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, nil
+}
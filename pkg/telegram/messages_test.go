@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestMessages(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "messages"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewMessageStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewMessageStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	// Start from an empty store, the bucket outlives test runs
+	assert.NoError(t, s.Prune(time.Now().Add(time.Hour)))
+
+	now := time.Now()
+	first := SentMessage{ChatID: 1, MessageID: 10, SentAt: now.Add(-time.Hour), Parts: 1, Data: &vendor.Data{
+		Alerts: vendor.Alerts{{Fingerprint: "a"}, {Fingerprint: "b"}},
+	}}
+	second := SentMessage{ChatID: 2, MessageID: 20, SentAt: now, Parts: 1, Data: &vendor.Data{
+		Alerts: vendor.Alerts{{Fingerprint: "b"}},
+	}}
+	assert.NoError(t, s.Add(first))
+	assert.NoError(t, s.Add(second))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: get by chat and message
+	// ---------------------------------------------------------------------------
+	m, err := s.Get(1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, m.Fingerprints())
+
+	_, err = s.Get(1, 20)
+	assert.Equal(t, store.ErrKeyNotFound, err)
+	t.Log("Get() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: find by fingerprint
+	// ---------------------------------------------------------------------------
+	found, err := s.ByFingerprint("b")
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	found, err = s.ByFingerprint("c")
+	assert.NoError(t, err)
+	assert.Empty(t, found)
+	t.Log("ByFingerprint() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: prune old messages
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Prune(now.Add(-time.Minute)))
+	found, err = s.ByFingerprint("b")
+	assert.NoError(t, err)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, int64(2), found[0].ChatID)
+	}
+	t.Log("Prune() : Test 1 PASSED.")
+
+}
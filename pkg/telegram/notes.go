@@ -0,0 +1,185 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramNotesDirectory = "telegram/notes"
+
+// templateNotes is how many of the most recent notes of an alert templates get in .Notes
+const templateNotes = 3
+
+// AlertNote is what someone noted about an alert by replying to a message about it
+type AlertNote struct {
+	Fingerprint string    `json:"fingerprint"`
+	By          string    `json:"by"`
+	UserID      int       `json:"userId"`
+	At          time.Time `json:"at"`
+	Text        string    `json:"text"`
+}
+
+// NoteStore writes the notes about alerts to a libkv store backend
+type NoteStore struct {
+	kv store.Store
+}
+
+// NewNoteStore stores the notes about alerts in the provided kv backend
+func NewNoteStore(kv store.Store) (*NoteStore, error) {
+	return &NoteStore{kv: kv}, nil
+}
+
+// Add a note about an alert
+func (s *NoteStore) Add(n AlertNote) error {
+	b, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s/%d", telegramNotesDirectory, n.Fingerprint, n.At.UnixNano())
+
+	return s.kv.Put(key, b, nil)
+}
+
+// ByFingerprint returns the notes about the alert of the fingerprint, the oldest first
+func (s *NoteStore) ByFingerprint(fingerprint string) ([]AlertNote, error) {
+
+	notes, err := s.list(fmt.Sprintf("%s/%s", telegramNotesDirectory, fingerprint))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(notes, func(i, j int) bool { return notes[i].At.Before(notes[j].At) })
+
+	return notes, nil
+}
+
+// Prune removes the notes written before the given time
+func (s *NoteStore) Prune(before time.Time) error {
+
+	notes, err := s.list(telegramNotesDirectory)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range notes {
+		if !n.At.Before(before) {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s/%d", telegramNotesDirectory, n.Fingerprint, n.At.UnixNano())
+		if err := s.kv.Delete(key); err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// list all notes saved under the directory of the kv backend
+func (s *NoteStore) list(directory string) ([]AlertNote, error) {
+
+	kvPairs, err := s.kv.List(directory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var notes []AlertNote
+	for _, kv := range kvPairs {
+		var n AlertNote
+		if err := json.Unmarshal(kv.Value, &n); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+
+	return notes, nil
+}
+
+// alertNotes returns the most recent notes about the alert of the fingerprint for templates
+func (b *Bot) alertNotes(fingerprint string) []vendor.Note {
+
+	if b.noteStore == nil {
+		return nil
+	}
+
+	notes, err := b.noteStore.ByFingerprint(fingerprint)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get notes from store", "err", err)
+		return nil
+	}
+	if len(notes) > templateNotes {
+		notes = notes[len(notes)-templateNotes:]
+	}
+
+	var out []vendor.Note
+	for _, n := range notes {
+		out = append(out, vendor.Note{By: n.By, At: n.At, Text: n.Text})
+	}
+
+	return out
+}
+
+// Note something about the alerts of the message replied to, or about an alert by its fingerprint
+func (b *Bot) handleNote(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.noteStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseNoteFail"))
+		return
+	}
+
+	var (
+		fingerprints []string
+		text         = commandArgs(message.Text)
+	)
+	if message.ReplyTo != nil && b.messageStore != nil {
+		// Resolved alerts are noted as well, for the next time they fire
+		m, err := b.messageStore.Get(message.Chat.ID, message.ReplyTo.ID)
+		if err != nil && err != store.ErrKeyNotFound {
+			level.Warn(b.logger).Log("msg", "failed to get message from store", "err", err)
+		}
+		fingerprints = m.Fingerprints()
+	} else if args := strings.Fields(text); len(args) > 0 && fingerprintPattern.MatchString(args[0]) {
+		fingerprints = []string{args[0]}
+		text = strings.TrimSpace(strings.TrimPrefix(text, args[0]))
+	}
+
+	text = strings.TrimSpace(text)
+	if len(fingerprints) == 0 || text == "" {
+		b.telegram.Reply(message, p.Sprintf("responseNoteUsage", commandNote, commandNote))
+		return
+	}
+
+	now := time.Now()
+	for _, fingerprint := range fingerprints {
+		n := AlertNote{Fingerprint: fingerprint, By: userName(message.Sender), UserID: message.Sender.ID, At: now, Text: text}
+		if err := b.noteStore.Add(n); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save note to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseNoteFail"))
+			return
+		}
+		b.recordIncidentEvent(fingerprint, IncidentEvent{Kind: incidentNote, By: n.By, Text: text})
+	}
+
+	b.telegram.Reply(message, p.Sprintf("responseNoted", len(fingerprints)))
+	level.Info(b.logger).Log(
+		"msg", "user noted alerts",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"fingerprints", strings.Join(fingerprints, ","),
+	)
+
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestNotes(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "notes"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewNoteStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewNoteStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	// Start from no notes, the bucket outlives test runs
+	assert.NoError(t, s.Prune(time.Now().Add(time.Hour)))
+
+	bot := &Bot{logger: log.NewNopLogger(), noteStore: s}
+	now := time.Now()
+
+	// ---------------------------------------------------------------------------
+	//  CASE: notes of an alert, the oldest first
+	// ---------------------------------------------------------------------------
+	for i, text := range []string{"first", "second", "third", "fourth"} {
+		assert.NoError(t, s.Add(AlertNote{Fingerprint: "1a2b3c4d5e6f7a8b", By: "@alice", At: now.Add(time.Duration(i) * time.Minute), Text: text}))
+	}
+	assert.NoError(t, s.Add(AlertNote{Fingerprint: "ffffffffffffffff", By: "@bob", At: now, Text: "other"}))
+
+	notes, err := s.ByFingerprint("1a2b3c4d5e6f7a8b")
+	assert.NoError(t, err)
+	if assert.Len(t, notes, 4) {
+		assert.Equal(t, "first", notes[0].Text)
+	}
+	t.Log("NoteStore.ByFingerprint() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: templates get the most recent notes
+	// ---------------------------------------------------------------------------
+	templated := bot.alertNotes("1a2b3c4d5e6f7a8b")
+	if assert.Len(t, templated, templateNotes) {
+		assert.Equal(t, "second", templated[0].Text)
+		assert.Equal(t, "fourth", templated[2].Text)
+	}
+	assert.Empty(t, bot.alertNotes("0000000000000000"))
+	t.Log("alertNotes() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: old notes are pruned
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Prune(now.Add(2*time.Minute)))
+	notes, _ = s.ByFingerprint("1a2b3c4d5e6f7a8b")
+	assert.Len(t, notes, 2)
+	notes, _ = s.ByFingerprint("ffffffffffffffff")
+	assert.Empty(t, notes)
+	t.Log("NoteStore.Prune() : Test 3 PASSED.")
+
+}
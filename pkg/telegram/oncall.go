@@ -0,0 +1,422 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramRotationsDirectory = "telegram/rotations"
+
+const day = 24 * time.Hour
+
+// Override hands the rotation temporarily to another user
+type Override struct {
+	User  string    `json:"user"`
+	From  time.Time `json:"from"`
+	Until time.Time `json:"until"`
+}
+
+// Rotation of Telegram users taking turns to be on call
+type Rotation struct {
+	Name string `json:"name"`
+	// Team is the value of the team label of the alerts this rotation is on call for
+	Team string `json:"team,omitempty"`
+	// Users in order, as @username
+	Users []string `json:"users"`
+	// Start is when the first user's shift began, the handoffs are every Length after it
+	Start    time.Time     `json:"start"`
+	Length   time.Duration `json:"length"`
+	Timezone string        `json:"timezone,omitempty"`
+
+	Overrides []Override `json:"overrides,omitempty"`
+}
+
+// Location returns the rotation's timezone, the local one if unset or unknown
+func (r Rotation) Location() *time.Location {
+	return (&Schedule{Timezone: r.Timezone}).Location()
+}
+
+// shift returns the number of the shift at t and when it started, shifts of whole
+// days hand off at the same wall clock time whatever the daylight saving time
+func (r Rotation) shift(t time.Time) (int, time.Time) {
+
+	start := r.Start.In(r.Location())
+
+	if r.Length%day != 0 {
+		n := floorDiv(int64(t.Sub(start)), int64(r.Length))
+		return int(n), start.Add(time.Duration(n) * r.Length)
+	}
+
+	days := int64(r.Length / day)
+	local := t.In(r.Location())
+	elapsed := civilDays(local) - civilDays(start)
+
+	n := floorDiv(elapsed, days)
+	begin := start.AddDate(0, 0, int(n*days))
+	if begin.After(t) {
+		n--
+		begin = start.AddDate(0, 0, int(n*days))
+	}
+
+	return int(n), begin
+}
+
+// OnCall returns who is on call at t and whether it's because of an override
+func (r Rotation) OnCall(t time.Time) (string, bool) {
+
+	for _, o := range r.Overrides {
+		if !t.Before(o.From) && t.Before(o.Until) {
+			return o.User, true
+		}
+	}
+
+	if len(r.Users) == 0 {
+		return "", false
+	}
+
+	n, _ := r.shift(t)
+
+	return r.Users[mod(n, len(r.Users))], false
+}
+
+// Next returns the next handoff after t and who takes over then
+func (r Rotation) Next(t time.Time) (time.Time, string) {
+
+	n, begin := r.shift(t)
+
+	next := begin.Add(r.Length)
+	if r.Length%day == 0 {
+		next = begin.AddDate(0, 0, int(r.Length/day))
+	}
+
+	if len(r.Users) == 0 {
+		return next, ""
+	}
+
+	return next, r.Users[mod(n+1, len(r.Users))]
+}
+
+// String returns the rotation in the same key=value form as it is added
+func (r Rotation) String() string {
+	fields := []string{r.Name}
+	if r.Team != "" {
+		fields = append(fields, "team="+r.Team)
+	}
+	fields = append(fields,
+		"users="+strings.Join(r.Users, ","),
+		"handoff="+r.Start.In(r.Location()).Format(clockLayout),
+		"length="+r.Length.String(),
+	)
+	if r.Timezone != "" {
+		fields = append(fields, "tz="+r.Timezone)
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseRotation parses the name and key=value arguments of a new rotation:
+// name team=db users=@alice,@bob handoff=09:00 length=168h tz=Europe/Berlin,
+// the first handoff is the next one at the handoff time after now
+func parseRotation(args []string, now time.Time) (Rotation, error) {
+
+	if len(args) == 0 || strings.Contains(args[0], "=") {
+		return Rotation{}, errors.New("missing rotation name")
+	}
+
+	r := Rotation{Name: args[0], Length: 7 * day}
+	handoff := "09:00"
+
+	for _, arg := range args[1:] {
+
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return Rotation{}, fmt.Errorf("expected key=value, got %q", arg)
+		}
+		key, value := strings.ToLower(kv[0]), kv[1]
+
+		switch key {
+		case "team":
+			r.Team = value
+		case "users":
+			r.Users = nil
+			for _, u := range strings.Split(value, ",") {
+				if u = strings.TrimSpace(u); u != "" {
+					r.Users = append(r.Users, "@"+strings.TrimPrefix(u, "@"))
+				}
+			}
+		case "handoff":
+			if _, err := time.Parse(clockLayout, value); err != nil {
+				return Rotation{}, fmt.Errorf("invalid time %q", value)
+			}
+			handoff = value
+		case "length":
+			length, err := time.ParseDuration(value)
+			if err != nil || length < time.Hour {
+				return Rotation{}, fmt.Errorf("invalid length %q, expected at least 1h", value)
+			}
+			r.Length = length
+		case "tz":
+			if _, err := time.LoadLocation(value); err != nil {
+				return Rotation{}, fmt.Errorf("unknown timezone %q", value)
+			}
+			r.Timezone = value
+		default:
+			return Rotation{}, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	if len(r.Users) == 0 {
+		return Rotation{}, errors.New("no users")
+	}
+
+	clock, _ := time.Parse(clockLayout, handoff)
+	local := now.In(r.Location())
+	r.Start = time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, r.Location())
+	if r.Start.Before(now) {
+		r.Start = r.Start.AddDate(0, 0, 1)
+	}
+	// The first user is on call from now until the first handoff
+	if r.Length%day == 0 {
+		r.Start = r.Start.AddDate(0, 0, -int(r.Length/day))
+	} else {
+		r.Start = r.Start.Add(-r.Length)
+	}
+
+	return r, nil
+}
+
+// RotationStore writes the on-call rotations to a libkv store backend
+type RotationStore struct {
+	kv store.Store
+}
+
+// NewRotationStore stores on-call rotations in the provided kv backend
+func NewRotationStore(kv store.Store) (*RotationStore, error) {
+	return &RotationStore{kv: kv}, nil
+}
+
+// List all rotations sorted by name
+func (s *RotationStore) List() ([]Rotation, error) {
+
+	kvPairs, err := s.kv.List(telegramRotationsDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rotations []Rotation
+	for _, kv := range kvPairs {
+		var r Rotation
+		if err := json.Unmarshal(kv.Value, &r); err != nil {
+			return nil, err
+		}
+		rotations = append(rotations, r)
+	}
+	sort.Slice(rotations, func(i, j int) bool { return rotations[i].Name < rotations[j].Name })
+
+	return rotations, nil
+}
+
+// Set a rotation, replacing the one with the same name
+func (s *RotationStore) Set(r Rotation) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramRotationsDirectory, r.Name)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove a rotation by its name
+func (s *RotationStore) Remove(name string) error {
+	key := fmt.Sprintf("%s/%s", telegramRotationsDirectory, name)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// Show who is on call, add or delete rotations and override them temporarily
+func (b *Bot) handleOnCall(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.rotationStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseOnCallFail"))
+		return
+	}
+
+	rotations, err := b.rotationStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get rotations from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseOnCallFail"))
+		return
+	}
+
+	now := time.Now()
+	args := strings.Fields(message.Text)[1:]
+
+	if len(args) == 0 {
+		if len(rotations) == 0 {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallEmpty", commandOnCall))
+			return
+		}
+		var lines []string
+		for _, r := range rotations {
+			current, overridden := r.OnCall(now)
+			if overridden {
+				current = p.Sprintf("responseOnCallOverridden", current)
+			}
+			handoff, next := r.Next(now)
+			lines = append(lines, p.Sprintf(
+				"responseOnCallRotation", r.Name, current, next, handoff.In(r.Location()).Format("2006-01-02 15:04 MST"),
+			))
+		}
+		b.telegram.Reply(message, p.Sprintf("responseOnCall", strings.Join(lines, "\n"), commandOnCall))
+		return
+	}
+
+	switch args[0] {
+
+	case "add":
+		r, err := parseRotation(args[1:], now)
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", err.Error(), commandOnCall))
+			return
+		}
+		if err := b.rotationStore.Set(r); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save rotation to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseOnCallFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseOnCallAdded", r.String()))
+
+	case "del":
+		if len(args) < 2 || findRotation(rotations, args[1]) == nil {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", "unknown rotation", commandOnCall))
+			return
+		}
+		if err := b.rotationStore.Remove(args[1]); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove rotation from store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseOnCallFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseOnCallDeleted", args[1]))
+
+	case "override":
+		if len(args) < 3 {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", "expected @user and a duration", commandOnCall))
+			return
+		}
+		duration, err := time.ParseDuration(args[2])
+		if err != nil || duration <= 0 {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", fmt.Sprintf("invalid duration %q", args[2]), commandOnCall))
+			return
+		}
+		var r *Rotation
+		switch {
+		case len(args) > 3:
+			r = findRotation(rotations, args[3])
+		case len(rotations) == 1:
+			r = &rotations[0]
+		}
+		if r == nil {
+			b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", "unknown rotation", commandOnCall))
+			return
+		}
+
+		user := "@" + strings.TrimPrefix(args[1], "@")
+		var overrides []Override
+		for _, o := range r.Overrides {
+			if o.Until.After(now) {
+				overrides = append(overrides, o)
+			}
+		}
+		r.Overrides = append([]Override{{User: user, From: now, Until: now.Add(duration)}}, overrides...)
+
+		if err := b.rotationStore.Set(*r); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save rotation to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseOnCallFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf(
+			"responseOnCallOverride", user, r.Name, now.Add(duration).In(r.Location()).Format("2006-01-02 15:04 MST"),
+		))
+
+	default:
+		b.telegram.Reply(message, p.Sprintf("responseOnCallInvalid", fmt.Sprintf("unknown action %q", args[0]), commandOnCall))
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user changed on-call rotations",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"action", args[0],
+	)
+
+}
+
+// onCall returns who is on call for the team, empty if no rotation is
+func (b *Bot) onCall(team string) string {
+
+	if b.rotationStore == nil || team == "" {
+		return ""
+	}
+
+	rotations, err := b.rotationStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get rotations from store", "err", err)
+		return ""
+	}
+
+	for _, r := range rotations {
+		if r.Team == team {
+			user, _ := r.OnCall(time.Now())
+			return user
+		}
+	}
+
+	return ""
+}
+
+// findRotation returns the rotation with the given name, nil if there is none
+func findRotation(rotations []Rotation, name string) *Rotation {
+	for i := range rotations {
+		if rotations[i].Name == name {
+			return &rotations[i]
+		}
+	}
+	return nil
+}
+
+// civilDays returns the number of days since the epoch of the date of t in its location
+func civilDays(t time.Time) int64 {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / int64(day/time.Second)
+}
+
+// mod returns the non-negative remainder of a divided by b
+func mod(a, b int) int {
+	return (a%b + b) % b
+}
+
+// floorDiv divides rounding towards negative infinity
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseRotation(t *testing.T) {
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, berlin)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the first user is on call until the next handoff
+	// ---------------------------------------------------------------------------
+	r, err := parseRotation([]string{"db", "team=database", "users=alice,@bob", "handoff=09:00", "length=168h", "tz=Europe/Berlin"}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "database", r.Team)
+	assert.Equal(t, []string{"@alice", "@bob"}, r.Users)
+	user, overridden := r.OnCall(now)
+	assert.Equal(t, "@alice", user)
+	assert.False(t, overridden)
+	next, user := r.Next(now)
+	assert.Equal(t, time.Date(2021, 3, 11, 9, 0, 0, 0, berlin), next)
+	assert.Equal(t, "@bob", user)
+	assert.Equal(t, "db team=database users=@alice,@bob handoff=09:00 length=168h0m0s tz=Europe/Berlin", r.String())
+	t.Log("parseRotation() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid rotations
+	// ---------------------------------------------------------------------------
+	for _, args := range [][]string{
+		{},
+		{"users=@alice"},
+		{"db"},
+		{"db", "users=@alice", "handoff=25:00"},
+		{"db", "users=@alice", "length=10m"},
+		{"db", "users=@alice", "tz=Mars/Olympus"},
+		{"db", "users=@alice", "foo=bar"},
+	} {
+		_, err := parseRotation(args, now)
+		assert.Error(t, err, args)
+	}
+	t.Log("parseRotation() : Test 2 PASSED.")
+
+}
+
+func TestRotation(t *testing.T) {
+
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+
+	r := Rotation{
+		Name:     "db",
+		Users:    []string{"@alice", "@bob", "@carol"},
+		Start:    time.Date(2021, 3, 22, 9, 0, 0, 0, berlin),
+		Length:   7 * day,
+		Timezone: "Europe/Berlin",
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: handoffs stay at the same wall clock time across daylight saving time
+	// ---------------------------------------------------------------------------
+	user, _ := r.OnCall(time.Date(2021, 3, 29, 8, 59, 0, 0, berlin))
+	assert.Equal(t, "@alice", user)
+	user, _ = r.OnCall(time.Date(2021, 3, 29, 9, 0, 0, 0, berlin))
+	assert.Equal(t, "@bob", user)
+	next, user := r.Next(time.Date(2021, 3, 29, 9, 0, 0, 0, berlin))
+	assert.Equal(t, time.Date(2021, 4, 5, 9, 0, 0, 0, berlin), next)
+	assert.Equal(t, "@carol", user)
+	user, _ = r.OnCall(time.Date(2021, 4, 12, 10, 0, 0, 0, berlin))
+	assert.Equal(t, "@alice", user)
+	t.Log("Rotation() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the rotation goes on backwards before its start
+	// ---------------------------------------------------------------------------
+	user, _ = r.OnCall(time.Date(2021, 3, 21, 9, 0, 0, 0, berlin))
+	assert.Equal(t, "@carol", user)
+	t.Log("Rotation() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: shifts shorter than a day
+	// ---------------------------------------------------------------------------
+	shifts := Rotation{Users: []string{"@alice", "@bob"}, Start: r.Start, Length: 12 * time.Hour}
+	user, _ = shifts.OnCall(r.Start.Add(13 * time.Hour))
+	assert.Equal(t, "@bob", user)
+	next, user = shifts.Next(r.Start.Add(13 * time.Hour))
+	assert.True(t, r.Start.Add(24*time.Hour).Equal(next))
+	assert.Equal(t, "@alice", user)
+	t.Log("Rotation() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an override hands the rotation over until it ends
+	// ---------------------------------------------------------------------------
+	now := time.Date(2021, 3, 23, 12, 0, 0, 0, berlin)
+	r.Overrides = []Override{{User: "@dave", From: now, Until: now.Add(4 * time.Hour)}}
+	user, overridden := r.OnCall(now.Add(time.Hour))
+	assert.Equal(t, "@dave", user)
+	assert.True(t, overridden)
+	user, overridden = r.OnCall(now.Add(4 * time.Hour))
+	assert.Equal(t, "@alice", user)
+	assert.False(t, overridden)
+	t.Log("Rotation() : Test 4 PASSED.")
+
+}
+
+func TestRotationStore(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "rotations"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, _ := NewRotationStore(kvStore)
+	bot := &Bot{logger: log.NewNopLogger(), rotationStore: s}
+
+	for _, name := range []string{"db", "web"} {
+		s.Remove(name)
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: no rotations
+	// ---------------------------------------------------------------------------
+	rotations, err := s.List()
+	assert.NoError(t, err)
+	assert.Empty(t, rotations)
+	assert.Equal(t, "", bot.onCall("database"))
+	t.Log("RotationStore() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the rotation of the team is on call
+	// ---------------------------------------------------------------------------
+	start := time.Now().Add(-time.Hour)
+	assert.NoError(t, s.Set(Rotation{Name: "web", Users: []string{"@carol"}, Start: start, Length: day}))
+	assert.NoError(t, s.Set(Rotation{Name: "db", Team: "database", Users: []string{"@alice", "@bob"}, Start: start, Length: day}))
+	rotations, err = s.List()
+	assert.NoError(t, err)
+	assert.Len(t, rotations, 2)
+	assert.Equal(t, "db", rotations[0].Name)
+	assert.Equal(t, "@alice", bot.onCall("database"))
+	assert.Equal(t, "", bot.onCall(""))
+	t.Log("RotationStore() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: removed rotations are not on call anymore
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Remove("db"))
+	assert.Equal(t, "", bot.onCall("database"))
+	t.Log("RotationStore() : Test 3 PASSED.")
+
+}
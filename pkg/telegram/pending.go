@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const telegramPendingDirectory = "telegram/pending"
+
+// Pending are the alerts held back from a chat until they are delivered at once
+type Pending struct {
+	// Since is when the first of the alerts was held
+	Since  time.Time     `json:"since"`
+	Alerts vendor.Alerts `json:"alerts"`
+}
+
+// Digest is what the telegram.digest template is executed with
+type Digest struct {
+	*vendor.Data
+	// Since is when the first of the alerts was held
+	Since time.Time
+}
+
+// PendingStore writes the held alerts to a libkv store backend
+type PendingStore struct {
+	kv store.Store
+}
+
+// NewPendingStore stores held alerts in the provided kv backend
+func NewPendingStore(kv store.Store) (*PendingStore, error) {
+	return &PendingStore{kv: kv}, nil
+}
+
+// Get the alerts held for a telegram chat, zero value if there are none
+func (s *PendingStore) Get(c telebot.Chat) (Pending, error) {
+	var pending Pending
+
+	key := fmt.Sprintf("%s/%d", telegramPendingDirectory, c.ID)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return pending, nil
+	}
+	if err != nil {
+		return pending, err
+	}
+
+	err = json.Unmarshal(kv.Value, &pending)
+
+	return pending, err
+}
+
+// Add alerts to the ones held for a telegram chat, an alert already held is
+// replaced by its latest state
+func (s *PendingStore) Add(c telebot.Chat, alerts ...vendor.Alert) error {
+
+	pending, err := s.Get(c)
+	if err != nil {
+		return err
+	}
+
+	if len(pending.Alerts) == 0 {
+		pending.Since = time.Now()
+	}
+
+	for _, alert := range alerts {
+		replaced := false
+		for i, held := range pending.Alerts {
+			if alertFingerprint(held) == alertFingerprint(alert) {
+				pending.Alerts[i] = alert
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			pending.Alerts = append(pending.Alerts, alert)
+		}
+	}
+
+	b, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%d", telegramPendingDirectory, c.ID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove all alerts held for a telegram chat
+func (s *PendingStore) Remove(c telebot.Chat) error {
+	key := fmt.Sprintf("%s/%d", telegramPendingDirectory, c.ID)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// alertFingerprint returns the alert's fingerprint, calculated from its labels
+// for Alertmanager versions not sending it
+func alertFingerprint(a vendor.Alert) string {
+	if a.Fingerprint != "" {
+		return a.Fingerprint
+	}
+
+	return labelSet(a.Labels).Fingerprint().String()
+}
+
+// labelSet converts template labels back to a label set for matchers and fingerprints
+func labelSet(kv vendor.KV) model.LabelSet {
+	labels := make(model.LabelSet, len(kv))
+	for k, v := range kv {
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return labels
+}
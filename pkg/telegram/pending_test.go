@@ -0,0 +1,73 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestPending(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "pending"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, err := NewPendingStore(kvStore)
+	if err != nil {
+		t.Fatalf("NewPendingStore() : Test 1 FAILED, got error: %s", err)
+	}
+
+	chat := telebot.Chat{ID: time.Now().UnixNano()}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing held yet
+	// ---------------------------------------------------------------------------
+	pending, err := s.Get(chat)
+	assert.NoError(t, err)
+	assert.Empty(t, pending.Alerts)
+	t.Log("Get() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: alerts are deduplicated keeping their latest state
+	// ---------------------------------------------------------------------------
+	firing := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "A"}}
+	resolved := vendor.Alert{Status: "resolved", Labels: vendor.KV{"alertname": "A"}}
+	other := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "B"}, Fingerprint: "b"}
+
+	assert.NoError(t, s.Add(chat, firing, other))
+	pending, err = s.Get(chat)
+	assert.NoError(t, err)
+	since := pending.Since
+	assert.False(t, since.IsZero())
+
+	assert.NoError(t, s.Add(chat, resolved))
+	pending, err = s.Get(chat)
+	assert.NoError(t, err)
+	assert.Len(t, pending.Alerts, 2)
+	assert.Equal(t, "resolved", pending.Alerts[0].Status)
+	assert.True(t, since.Equal(pending.Since))
+	t.Log("Add() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: removing twice is fine
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.Remove(chat))
+	assert.NoError(t, s.Remove(chat))
+	pending, err = s.Get(chat)
+	assert.NoError(t, err)
+	assert.Empty(t, pending.Alerts)
+	t.Log("Remove() : Test 1 PASSED.")
+
+}
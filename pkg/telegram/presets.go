@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// defaultSilenceComment is the comment of the silences created with the bot's commands
+const defaultSilenceComment = "Enacted by administrator command"
+
+// silencePresetButton is sent with every firing alert for the presets with a button
+var silencePresetButton = telebot.InlineButton{Unique: "silence"}
+
+// presetCommand is the syntax of the Telegram commands
+var presetCommand = regexp.MustCompile(`^/[a-z0-9_]{1,32}$`)
+
+// defaultSilencePresets are used when none are configured
+var defaultSilencePresets = []SilencePreset{
+	{Command: "/s2h", Duration: model.Duration(2 * time.Hour), Comment: defaultSilenceComment, Button: true},
+	{Command: "/s48h", Duration: model.Duration(48 * time.Hour), Comment: defaultSilenceComment, Button: true},
+	{Command: "/s2w", Duration: model.Duration(14 * day), Comment: defaultSilenceComment, Button: true},
+}
+
+// SilencePreset is a command silencing an alert by its fingerprint for a fixed duration
+type SilencePreset struct {
+	// Command like /s2h
+	Command string `yaml:"command"`
+	// Duration in Prometheus syntax like 2h or 2w
+	Duration model.Duration `yaml:"duration"`
+	// Comment of the silences created, defaults to defaultSilenceComment
+	Comment string `yaml:"comment"`
+	// Role an admin needs to use the preset, any admin can if empty
+	Role string `yaml:"role"`
+	// Button sent with the firing alerts
+	Button bool `yaml:"button"`
+}
+
+// compile validates the preset and sets its defaults
+func (p *SilencePreset) compile(roles map[string][]int) error {
+
+	if !presetCommand.MatchString(p.Command) {
+		return fmt.Errorf("invalid command %q, expected a slash followed by lowercase letters, digits or underscores", p.Command)
+	}
+	if p.Duration <= 0 {
+		return errors.New("expected a positive duration")
+	}
+	if _, ok := roles[p.Role]; p.Role != "" && !ok {
+		return fmt.Errorf("unknown role %q", p.Role)
+	}
+	if p.Comment == "" {
+		p.Comment = defaultSilenceComment
+	}
+
+	return nil
+}
+
+// parseSilenceDuration parses a duration in Go syntax like 1h30m or Prometheus syntax like 2w
+func parseSilenceDuration(s string) (time.Duration, error) {
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		md, merr := model.ParseDuration(s)
+		if merr != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d = time.Duration(md)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q, expected a positive one", s)
+	}
+
+	return d, nil
+}
+
+// silencePreset returns the preset of the command, nil if there is none
+func (b *Bot) silencePreset(command string) *SilencePreset {
+	for i := range b.silencePresets {
+		if b.silencePresets[i].Command == command {
+			return &b.silencePresets[i]
+		}
+	}
+	return nil
+}
+
+// hasRole returns whether the user has the role, everybody has the empty role
+func (b *Bot) hasRole(id int, role string) bool {
+	if role == "" {
+		return true
+	}
+	for _, user := range b.roles[role] {
+		if user == id {
+			return true
+		}
+	}
+	return false
+}
+
+// silencePresetButtons returns the buttons of the presets for a firing alert
+func (b *Bot) silencePresetButtons(fingerprint string) []telebot.InlineButton {
+	var buttons []telebot.InlineButton
+	for _, preset := range b.silencePresets {
+		if !preset.Button {
+			continue
+		}
+		buttons = append(buttons, presetButton(preset, fingerprint))
+	}
+	return buttons
+}
+
+// presetButton returns the button silencing the alert of the fingerprint with the preset
+func presetButton(preset SilencePreset, fingerprint string) telebot.InlineButton {
+	button := *silencePresetButton.With(strings.TrimPrefix(preset.Command, "/") + " " + fingerprint)
+	button.Text = "🔕 " + preset.Duration.String()
+	return button
+}
+
+// handleSilencePreset silences the alert of the fingerprint given, or the alerts of the message replied to,
+// for the duration of the preset
+func (b *Bot) handleSilencePreset(message *telebot.Message, preset SilencePreset) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if !b.hasRole(message.Sender.ID, preset.Role) {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceRole", preset.Role, preset.Command))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 && message.ReplyTo != nil {
+		b.silenceReply(message, preset.Command)
+		return
+	}
+	if len(args) == 0 {
+		b.telegram.Reply(message, p.Sprintf("responseNoFingerprint"))
+		return
+	}
+
+	id, err := b.silence(args[0], time.Duration(preset.Duration), preset.Comment)
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+		return
+	}
+	b.watchSilence(id, message.Chat)
+	b.telegram.Reply(message, p.Sprintf("responseSilenceCreated"))
+
+}
+
+// Silence the alert of the fingerprint given, or the alerts of the message replied to, for any duration
+func (b *Bot) handleSilenceFor(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if !b.hasRole(message.Sender.ID, b.silenceRole) {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceRole", b.silenceRole, commandSilenceFor))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 1 && message.ReplyTo != nil {
+		b.silenceReply(message, args[0])
+		return
+	}
+	if len(args) != 2 {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceForUsage", commandSilenceFor, commandSilenceFor))
+		return
+	}
+
+	duration, err := parseSilenceDuration(args[0])
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+		return
+	}
+
+	id, err := b.silence(args[1], duration, defaultSilenceComment)
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+		return
+	}
+	b.watchSilence(id, message.Chat)
+	b.telegram.Reply(message, p.Sprintf("responseSilenceCreated"))
+
+}
+
+// handleSilencePresetCallback silences the alert of the button for the duration of its preset
+func (b *Bot) handleSilencePresetCallback(c *telebot.Callback) {
+
+	chat := callbackChat(c)
+	p := b.printer(chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	args := strings.Fields(c.Data)
+	if len(args) != 2 {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceFail", "invalid button"), ShowAlert: true})
+		return
+	}
+	preset := b.silencePreset("/" + args[0])
+	if preset == nil {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceFail", "unknown preset"), ShowAlert: true})
+		return
+	}
+	if !b.hasRole(c.Sender.ID, preset.Role) {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceRole", preset.Role, preset.Command), ShowAlert: true})
+		return
+	}
+	b.commandsCounter.WithLabelValues(preset.Command).Inc()
+
+	id, err := b.silence(args[1], time.Duration(preset.Duration), preset.Comment)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to silence alert", "err", err)
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceFail", err), ShowAlert: true})
+		return
+	}
+	b.watchSilence(id, chat)
+	b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceCreated")})
+
+	level.Info(b.logger).Log(
+		"msg", "user silenced alert",
+		"username", c.Sender.Username,
+		"user_id", c.Sender.ID,
+		"fingerprint", args[1],
+		"preset", preset.Command,
+	)
+
+}
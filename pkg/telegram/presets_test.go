@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseSilenceDuration(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: Go and Prometheus duration syntax
+	// ---------------------------------------------------------------------------
+	for s, expected := range map[string]time.Duration{
+		"90m":   90 * time.Minute,
+		"1h30m": 90 * time.Minute,
+		"1.5h":  90 * time.Minute,
+		"3d":    3 * day,
+		"2w":    14 * day,
+		"1d12h": 36 * time.Hour,
+	} {
+		d, err := parseSilenceDuration(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, expected, d, s)
+	}
+	t.Log("parseSilenceDuration() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid durations
+	// ---------------------------------------------------------------------------
+	for _, s := range []string{"", "0", "-1h", "soon", "2 days"} {
+		_, err := parseSilenceDuration(s)
+		assert.Error(t, err, s)
+	}
+	t.Log("parseSilenceDuration() : Test 2 PASSED.")
+
+}
+
+func TestSilencePresets(t *testing.T) {
+
+	cat, _ := translation.NewCatalog(nil)
+	bot := &Bot{
+		logger:         log.NewNopLogger(),
+		catalog:        cat,
+		silencePresets: defaultSilencePresets,
+		roles:          map[string][]int{"leads": {42}},
+	}
+
+	firing := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "A"}, Fingerprint: "a"}
+	resolved := vendor.Alert{Status: "resolved", Labels: vendor.KV{"alertname": "B"}, Fingerprint: "b"}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: firing alerts get a button per preset
+	// ---------------------------------------------------------------------------
+	markup := bot.alertsMarkup(cat.Printer(), &vendor.Data{Alerts: vendor.Alerts{firing, resolved}})
+	if assert.NotNil(t, markup) && assert.Len(t, markup.InlineKeyboard, 1) && assert.Len(t, markup.InlineKeyboard[0], 4) {
+		assert.Equal(t, "silence", markup.InlineKeyboard[0][0].Unique)
+		assert.Equal(t, "s2h a", markup.InlineKeyboard[0][0].Data)
+		assert.Equal(t, "🔕 2w", markup.InlineKeyboard[0][2].Text)
+	}
+	t.Log("alertsMarkup() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: presets without a button
+	// ---------------------------------------------------------------------------
+	bot.silencePresets = []SilencePreset{{Command: "/s1d", Duration: 1, Role: "leads"}}
+	markup = bot.alertsMarkup(cat.Printer(), &vendor.Data{Alerts: vendor.Alerts{firing}})
+	if assert.NotNil(t, markup) && assert.Len(t, markup.InlineKeyboard[0], 1) {
+		assert.Equal(t, "alert_details", markup.InlineKeyboard[0][0].Unique)
+	}
+	assert.NotNil(t, bot.silencePreset("/s1d"))
+	assert.Nil(t, bot.silencePreset("/s2h"))
+	t.Log("alertsMarkup() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: roles
+	// ---------------------------------------------------------------------------
+	assert.True(t, bot.hasRole(42, "leads"))
+	assert.False(t, bot.hasRole(43, "leads"))
+	assert.True(t, bot.hasRole(43, ""))
+	t.Log("hasRole() : Test 3 PASSED.")
+
+}
+
+func TestSilencePresetComment(t *testing.T) {
+
+	alerts, err := ioutil.ReadFile("../test/alerts.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var posted vendor.Silence
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/alerts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(alerts)
+	})
+	mux.HandleFunc("/api/v2/silences", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&posted)
+		w.Write([]byte(`{"silenceID":"a5e1a0f4-6f0c-4b5e-9a1e-0c1f3b0a7d21"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	alertmanagerURL, _ := url.Parse(server.URL)
+
+	logger := log.NewNopLogger()
+	firing, err := alertmanager.ListAlerts(logger, server.URL)
+	if err != nil || len(firing) == 0 {
+		t.Fatalf("ListAlerts() : got error: %v", err)
+	}
+	bot := &Bot{logger: logger, alertmanager: alertmanagerURL, silencePresets: defaultSilencePresets}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the silences of the default presets have a comment
+	// ---------------------------------------------------------------------------
+	preset := bot.silencePreset("/s2h")
+	id, err := bot.silence(firing[0].Fingerprint().String(), time.Duration(preset.Duration), preset.Comment)
+	assert.NoError(t, err)
+	assert.Equal(t, "a5e1a0f4-6f0c-4b5e-9a1e-0c1f3b0a7d21", id)
+	assert.Equal(t, defaultSilenceComment, posted.Comment)
+	t.Log("silence() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: presets given without a comment get the default one
+	// ---------------------------------------------------------------------------
+	posted = vendor.Silence{}
+	_, err = bot.silence(firing[0].Fingerprint().String(), time.Hour, "")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultSilenceComment, posted.Comment)
+	t.Log("silence() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/prometheus"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// queryRows is how many rows of a query result are shown at most
+	queryRows = 40
+	// queryLength is about how long the table of a query result may get, to fit in a message
+	queryLength = 3500
+	// queryLabelsWidth is the widest the label sets are padded to, longer ones push their value aside
+	queryLabelsWidth = 40
+	// defaultQueryRange is how far back /query_range goes without a range
+	defaultQueryRange = time.Hour
+)
+
+// queryRow is a row of the table of a query result: a label set and its value
+type queryRow struct {
+	Labels string
+	Value  string
+}
+
+// queryTable returns the rows of a query result, the samples of a series of a matrix follow its label set
+func queryTable(location *time.Location, value model.Value) []queryRow {
+
+	const timeFormat = "2006-01-02 15:04:05 MST"
+
+	var rows []queryRow
+	switch v := value.(type) {
+	case *model.Scalar:
+		rows = append(rows, queryRow{Labels: "scalar", Value: v.Value.String()})
+	case *model.String:
+		rows = append(rows, queryRow{Labels: "string", Value: v.Value})
+	case model.Vector:
+		for _, s := range v {
+			rows = append(rows, queryRow{Labels: s.Metric.String(), Value: s.Value.String()})
+		}
+	case model.Matrix:
+		for _, s := range v {
+			for i, pair := range s.Values {
+				row := queryRow{Value: fmt.Sprintf("%s @ %s", pair.Value, pair.Timestamp.Time().In(location).Format(timeFormat))}
+				if i == 0 {
+					row.Labels = s.Metric.String()
+				}
+				rows = append(rows, row)
+			}
+		}
+	}
+
+	return rows
+}
+
+// renderQuery renders a query result as an HTML table, cut after the rows fitting in a message
+func renderQuery(p *translation.Printer, location *time.Location, value model.Value) string {
+
+	rows := queryTable(location, value)
+	if len(rows) == 0 {
+		return p.Sprintf("responseQueryEmpty")
+	}
+
+	width := 0
+	for _, row := range rows {
+		if n := len([]rune(row.Labels)); n > width {
+			width = n
+		}
+	}
+	if width > queryLabelsWidth {
+		width = queryLabelsWidth
+	}
+
+	var table strings.Builder
+	shown := 0
+	for _, row := range rows {
+		line := fmt.Sprintf("%-*s  %s\n", width, row.Labels, row.Value)
+		if shown == queryRows || table.Len()+len(line) > queryLength {
+			break
+		}
+		table.WriteString(line)
+		shown++
+	}
+
+	out := fmt.Sprintf("%s\n<pre>%s</pre>", value.Type().String()+", "+p.Sprintf("responseQuery", len(rows)),
+		html.EscapeString(strings.TrimSuffix(table.String(), "\n")))
+	if shown < len(rows) {
+		out += "\n" + p.Sprintf("responseQueryMore", len(rows)-shown)
+	}
+
+	return out
+}
+
+// parseQueryRange returns the range and the expression of /query_range, the range goes first if given
+func parseQueryRange(args string) (time.Duration, string) {
+
+	args = strings.TrimSpace(args)
+	fields := strings.Fields(args)
+	if len(fields) > 1 {
+		if d, err := model.ParseDuration(fields[0]); err == nil && d > 0 {
+			return time.Duration(d), strings.TrimSpace(strings.TrimPrefix(args, fields[0]))
+		}
+	}
+
+	return defaultQueryRange, args
+}
+
+// queryAllowed tells the sender of the message when Prometheus can't be queried or they may not
+func (b *Bot) queryAllowed(message *telebot.Message, command string) bool {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.prometheus == nil {
+		b.telegram.Reply(message, p.Sprintf("responseQueryDisabled"))
+		return false
+	}
+	if !b.hasRole(message.Sender.ID, b.queryRole) {
+		b.telegram.Reply(message, p.Sprintf("responseQueryRole", b.queryRole, command))
+		return false
+	}
+
+	return true
+}
+
+// Evaluate a PromQL expression now and show the result as a table
+func (b *Bot) handleQuery(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if !b.queryAllowed(message, commandQuery) {
+		return
+	}
+
+	expr := strings.TrimSpace(commandArgs(message.Text))
+	if expr == "" {
+		b.telegram.Reply(message, p.Sprintf("responseQueryUsage", commandQuery, commandQuery))
+		return
+	}
+
+	value, err := prometheus.Query(b.logger, b.prometheus.String(), expr, time.Now())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to query Prometheus", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseQueryFail", err))
+		return
+	}
+
+	out := renderQuery(p, b.location(message.Chat), value)
+	if _, err := b.telegram.Reply(message, out, &telebot.SendOptions{ParseMode: telebot.ModeHTML}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send query result", "err", err)
+	}
+	level.Info(b.logger).Log(
+		"msg", "user queried Prometheus",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"query", expr,
+	)
+
+}
+
+// Evaluate a PromQL expression over the last hour or a range and show it as a chart
+func (b *Bot) handleQueryRange(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if !b.queryAllowed(message, commandQueryRange) {
+		return
+	}
+
+	period, expr := parseQueryRange(commandArgs(message.Text))
+	if expr == "" {
+		b.telegram.Reply(message, p.Sprintf("responseQueryRangeUsage", commandQueryRange, commandQueryRange))
+		return
+	}
+
+	end := time.Now()
+	matrix, err := prometheus.QueryRange(b.logger, b.prometheus.String(), expr, end.Add(-period), end, graphStep(period))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to query Prometheus", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseQueryFail", err))
+		return
+	}
+
+	b.replyChart(message, p, expr, matrix)
+	level.Info(b.logger).Log(
+		"msg", "user queried Prometheus",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"query", expr,
+		"range", period,
+	)
+
+}
@@ -0,0 +1,119 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestQueryTable(t *testing.T) {
+
+	at := model.TimeFromUnix(time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC).Unix())
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a row per sample of a vector and per scalar
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []queryRow{
+		{Labels: `{instance="a"}`, Value: "1"},
+		{Labels: `{instance="b"}`, Value: "0.5"},
+	}, queryTable(time.UTC, model.Vector{
+		{Metric: model.Metric{"instance": "a"}, Value: 1, Timestamp: at},
+		{Metric: model.Metric{"instance": "b"}, Value: 0.5, Timestamp: at},
+	}))
+	assert.Equal(t, []queryRow{{Labels: "scalar", Value: "2"}}, queryTable(time.UTC, &model.Scalar{Value: 2, Timestamp: at}))
+	assert.Equal(t, []queryRow{{Labels: "string", Value: "hi"}}, queryTable(time.UTC, &model.String{Value: "hi", Timestamp: at}))
+	t.Log("queryTable() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the samples of a series of a matrix follow its label set
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []queryRow{
+		{Labels: `up{instance="a"}`, Value: "1 @ 2021-03-10 12:00:00 UTC"},
+		{Value: "0 @ 2021-03-10 12:01:00 UTC"},
+	}, queryTable(time.UTC, model.Matrix{
+		{Metric: model.Metric{"__name__": "up", "instance": "a"}, Values: []model.SamplePair{{Timestamp: at, Value: 1}, {Timestamp: at + 60000, Value: 0}}},
+	}))
+	assert.Empty(t, queryTable(time.UTC, model.Vector{}))
+	t.Log("queryTable() : Test 2 PASSED.")
+
+}
+
+func TestQueryRender(t *testing.T) {
+
+	cat, err := translation.NewCatalog(map[string]translation.Dictionary{"en": {
+		"responseQuery":      {Plural: map[string]string{"one": "%d row:", "other": "%d rows:"}},
+		"responseQueryMore":  {Plural: map[string]string{"one": "and %d more row", "other": "and %d more rows"}},
+		"responseQueryEmpty": {Text: "No result."},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	p := cat.Printer()
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an aligned and escaped table
+	// ---------------------------------------------------------------------------
+	vector := model.Vector{
+		{Metric: model.Metric{"instance": "a"}, Value: 1},
+		{Metric: model.Metric{"instance": "bb"}, Value: 0},
+	}
+	assert.Equal(t, "vector, 2 rows:\n<pre>{instance=&#34;a&#34;}   1\n{instance=&#34;bb&#34;}  0</pre>", renderQuery(p, time.UTC, vector))
+	assert.Equal(t, "scalar, 1 row:\n<pre>scalar  2</pre>", renderQuery(p, time.UTC, &model.Scalar{Value: 2}))
+	assert.Equal(t, "No result.", renderQuery(p, time.UTC, model.Vector{}))
+	t.Log("renderQuery() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: large results are cut and the rows left out counted
+	// ---------------------------------------------------------------------------
+	vector = nil
+	for i := 0; i < queryRows+5; i++ {
+		vector = append(vector, &model.Sample{Metric: model.Metric{"i": model.LabelValue(strings.Repeat("x", i))}, Value: model.SampleValue(i)})
+	}
+	out := renderQuery(p, time.UTC, vector)
+	assert.True(t, strings.HasPrefix(out, "vector, 45 rows:\n"))
+	assert.True(t, strings.HasSuffix(out, "</pre>\nand 5 more rows"))
+	assert.Equal(t, queryRows, strings.Count(out, "{i="))
+
+	vector = nil
+	for i := 0; i < 20; i++ {
+		vector = append(vector, &model.Sample{Metric: model.Metric{"i": model.LabelValue(strings.Repeat("x", 300))}, Value: model.SampleValue(i)})
+	}
+	out = renderQuery(p, time.UTC, vector)
+	assert.True(t, len(out) < 4096)
+	assert.Contains(t, out, "more rows")
+	t.Log("renderQuery() : Test 2 PASSED.")
+
+}
+
+func TestQueryRangeArgs(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the range goes first, the last hour without one
+	// ---------------------------------------------------------------------------
+	for i, c := range []struct {
+		args   string
+		period time.Duration
+		expr   string
+	}{
+		{"6h rate(x[5m])", 6 * time.Hour, "rate(x[5m])"},
+		{" rate(x[5m]) ", time.Hour, "rate(x[5m])"},
+		{"up offset 1h", time.Hour, "up offset 1h"},
+		{"1d", time.Hour, "1d"},
+		{"", time.Hour, ""},
+	} {
+		period, expr := parseQueryRange(c.args)
+		if assert.Equal(t, c.period, period) && assert.Equal(t, c.expr, expr) {
+			t.Logf("parseQueryRange() : Test %d PASSED.", i+1)
+		}
+	}
+
+}
@@ -0,0 +1,283 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// minReminderInterval is the shortest interval reminders can be sent at
+const minReminderInterval = 10 * time.Minute
+
+// ReminderRule of a chat: the firing alerts matched by all its matchers are reminded of every interval
+type ReminderRule struct {
+	// Matchers like `severity="critical"`, none matches every alert
+	Matchers []string      `json:"matchers,omitempty"`
+	Interval time.Duration `json:"interval"`
+}
+
+// Matches returns whether the rule applies to the alert labels
+func (r ReminderRule) Matches(labels vendor.KV) bool {
+	var matchers vendor.Matchers
+	for _, s := range r.Matchers {
+		m, err := vendor.ParseMatcher(s)
+		if err != nil {
+			return false
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers.Matches(labelSet(labels))
+}
+
+// String returns the rule as it is listed
+func (r ReminderRule) String() string {
+	if len(r.Matchers) == 0 {
+		return r.Interval.String()
+	}
+	return r.Interval.String() + " " + strings.Join(r.Matchers, " ")
+}
+
+// parseReminderRule parses an interval followed by the matchers of a new rule
+func parseReminderRule(args []string) (ReminderRule, error) {
+
+	if len(args) == 0 {
+		return ReminderRule{}, errors.New("expected an interval")
+	}
+
+	interval, err := time.ParseDuration(args[0])
+	if err != nil || interval < minReminderInterval {
+		return ReminderRule{}, fmt.Errorf("invalid interval %q, expected at least %s", args[0], minReminderInterval)
+	}
+
+	for _, s := range args[1:] {
+		if _, err := vendor.ParseMatcher(s); err != nil {
+			return ReminderRule{}, err
+		}
+	}
+
+	return ReminderRule{Matchers: args[1:], Interval: interval}, nil
+}
+
+// dueReminders returns the firing alerts the chat is to be reminded of now and
+// when the chat was reminded of the alerts matching its rules, silenced alerts are skipped
+func dueReminders(settings ChatSettings, reminded map[string]time.Time, firing []AlertRecord, silences []vendor.Silence, now time.Time) ([]AlertRecord, map[string]time.Time) {
+
+	var due []AlertRecord
+	next := map[string]time.Time{}
+
+	for _, record := range firing {
+
+		var rule *ReminderRule
+		for i := range settings.Reminders {
+			if settings.Reminders[i].Matches(record.Labels) {
+				rule = &settings.Reminders[i]
+				break
+			}
+		}
+		if rule == nil || alertmanager.Silenced(silences, labelSet(record.Labels)) {
+			continue
+		}
+
+		// The reminders are relative to when the bot first got the alert firing
+		last := record.StartsAt
+		if len(record.Transitions) > 0 {
+			last = record.Transitions[0].At
+		}
+		if at, ok := reminded[record.Fingerprint]; ok && at.After(last) {
+			last = at
+		}
+
+		if now.Sub(last) >= rule.Interval {
+			due = append(due, record)
+			last = now
+		}
+		next[record.Fingerprint] = last
+	}
+
+	return due, next
+}
+
+// Show, add or delete the reminder rules of this chat
+func (b *Bot) handleRemind(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.historyStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseRemindFail"))
+		return
+	}
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseRemindFail"))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		if len(settings.Reminders) == 0 {
+			b.telegram.Reply(message, p.Sprintf("responseRemindersEmpty", commandRemind))
+			return
+		}
+		var lines []string
+		for i, r := range settings.Reminders {
+			lines = append(lines, fmt.Sprintf("%d. %s", i+1, r.String()))
+		}
+		b.telegram.Reply(message, p.Sprintf("responseReminders", strings.Join(lines, "\n"), commandRemind))
+		return
+	}
+
+	switch args[0] {
+
+	case "add":
+		rule, err := parseReminderRule(args[1:])
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseRemindInvalid", err.Error(), commandRemind))
+			return
+		}
+		settings.Reminders = append(settings.Reminders, rule)
+		if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseRemindFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseRemindAdded", rule.String()))
+
+	case "del":
+		n := 0
+		if len(args) > 1 {
+			n, _ = strconv.Atoi(args[1])
+		}
+		if n < 1 || n > len(settings.Reminders) {
+			b.telegram.Reply(message, p.Sprintf("responseRemindInvalid", "unknown rule number", commandRemind))
+			return
+		}
+		settings.Reminders = append(settings.Reminders[:n-1], settings.Reminders[n:]...)
+		if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseRemindFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseRemindDeleted", n))
+
+	default:
+		b.telegram.Reply(message, p.Sprintf("responseRemindInvalid", fmt.Sprintf("unknown action %q", args[0]), commandRemind))
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user changed chat reminders",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"action", args[0],
+	)
+
+}
+
+// remind sends the reminders that are due about the alerts still firing
+func (b *Bot) remind(now time.Time) {
+
+	if b.historyStore == nil {
+		return
+	}
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		return
+	}
+
+	var (
+		loaded   bool
+		firing   []AlertRecord
+		silences []vendor.Silence
+	)
+
+	for _, chat := range chats {
+
+		settings, err := b.chatStore.Settings(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+			continue
+		}
+		reminded, err := b.chatStore.Reminded(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get reminders from store", "err", err)
+			continue
+		}
+		if len(settings.Reminders) == 0 && len(reminded) == 0 {
+			continue
+		}
+
+		if !loaded {
+			records, err := b.historyStore.Range(now, now)
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to get alert history from store", "err", err)
+				return
+			}
+			for _, record := range records {
+				if !record.Resolved() {
+					firing = append(firing, record)
+				}
+			}
+			silences, err = alertmanager.ListSilences(b.logger, b.alertmanager.String())
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to get silences, postponing reminders", "err", err)
+				return
+			}
+			loaded = true
+		}
+
+		due, next := dueReminders(settings, reminded, firing, silences, now)
+		for _, record := range due {
+			loud := settings.Schedule.Loud(vendor.Alert{Labels: record.Labels})
+			b.sendReminder(chat, record, now, settings.Schedule.Quiet(now) && !loud)
+		}
+
+		if len(due) == 0 && len(next) == len(reminded) {
+			continue
+		}
+		if err := b.chatStore.SetReminded(chat, next); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save reminders to store", "err", err)
+		}
+	}
+}
+
+// sendReminder replies to the last message sent to the chat about the alert that it is still firing,
+// the reminder is sent on its own if there is no such message
+func (b *Bot) sendReminder(chat telebot.Chat, record AlertRecord, now time.Time, silent bool) {
+
+	options := &telebot.SendOptions{DisableNotification: silent}
+
+	if b.messageStore != nil {
+		messages, err := b.messageStore.ByFingerprint(record.Fingerprint)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get messages from store", "err", err)
+		}
+		var last *SentMessage
+		for i, m := range messages {
+			if m.ChatID == chat.ID && !m.SentAt.Before(record.StartsAt) && (last == nil || m.SentAt.After(last.SentAt)) {
+				last = &messages[i]
+			}
+		}
+		if last != nil {
+			options.ReplyTo = &telebot.Message{ID: last.MessageID, Chat: &chat}
+		}
+	}
+
+	p := b.printer(&chat, nil)
+	text := p.Sprintf("responseReminder", record.Labels["alertname"], now.Sub(record.StartsAt).Round(time.Minute).String())
+
+	if _, err := b.telegram.Send(&chat, text, options); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send reminder", "chat", chat.ID, "err", err)
+	}
+}
@@ -0,0 +1,97 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseReminderRule(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: interval and matchers
+	// ---------------------------------------------------------------------------
+	rule, err := parseReminderRule([]string{"3h", `severity="critical"`})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Hour, rule.Interval)
+	assert.True(t, rule.Matches(vendor.KV{"alertname": "A", "severity": "critical"}))
+	assert.False(t, rule.Matches(vendor.KV{"alertname": "A", "severity": "warning"}))
+	assert.Equal(t, `3h0m0s severity="critical"`, rule.String())
+	t.Log("parseReminderRule() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a rule without matchers matches every alert
+	// ---------------------------------------------------------------------------
+	rule, err = parseReminderRule([]string{"1h"})
+	assert.NoError(t, err)
+	assert.True(t, rule.Matches(vendor.KV{"alertname": "A"}))
+	t.Log("parseReminderRule() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid rules
+	// ---------------------------------------------------------------------------
+	for _, args := range [][]string{{}, {"soon"}, {"1m"}, {"1h", "severity"}} {
+		_, err := parseReminderRule(args)
+		assert.Error(t, err, args)
+	}
+	t.Log("parseReminderRule() : Test 3 PASSED.")
+
+}
+
+func TestDueReminders(t *testing.T) {
+
+	now := time.Now()
+	settings := ChatSettings{Reminders: []ReminderRule{{Matchers: []string{`severity="critical"`}, Interval: time.Hour}}}
+
+	record := func(fingerprint, severity string, got time.Time) AlertRecord {
+		return AlertRecord{
+			Fingerprint: fingerprint,
+			Status:      "firing",
+			Labels:      vendor.KV{"alertname": fingerprint, "severity": severity},
+			StartsAt:    got.Add(-time.Minute),
+			Transitions: []Transition{{Status: "firing", At: got}},
+		}
+	}
+	old := record("old", "critical", now.Add(-2*time.Hour))
+	recent := record("recent", "critical", now.Add(-30*time.Minute))
+	warning := record("warning", "warning", now.Add(-2*time.Hour))
+	silenced := AlertRecord{
+		Fingerprint: "silenced",
+		Status:      "firing",
+		Labels:      vendor.KV{"alertname": "alertname_1", "environment": "monitoring", "severity": "critical"},
+		StartsAt:    now.Add(-2 * time.Hour),
+	}
+	silences := []vendor.Silence{{
+		Matchers: vendor.Matchers{{Name: "environment", Value: "monitoring"}},
+		Status:   vendor.SilenceStatus{State: vendor.SilenceStateActive},
+	}}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only the matching alerts firing for longer than the interval are due
+	// ---------------------------------------------------------------------------
+	due, reminded := dueReminders(settings, nil, []AlertRecord{old, recent, warning, silenced}, silences, now)
+	assert.Len(t, due, 1)
+	assert.Equal(t, "old", due[0].Fingerprint)
+	assert.Equal(t, map[string]time.Time{"old": now, "recent": recent.Transitions[0].At}, reminded)
+	t.Log("dueReminders() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the next reminder is due an interval after the last one
+	// ---------------------------------------------------------------------------
+	due, _ = dueReminders(settings, reminded, []AlertRecord{old, recent}, nil, now.Add(45*time.Minute))
+	assert.Len(t, due, 1)
+	assert.Equal(t, "recent", due[0].Fingerprint)
+	due, reminded = dueReminders(settings, reminded, []AlertRecord{old}, nil, now.Add(time.Hour))
+	assert.Len(t, due, 1)
+	assert.Equal(t, "old", due[0].Fingerprint)
+	assert.Len(t, reminded, 1)
+	t.Log("dueReminders() : Test 2 PASSED.")
+
+}
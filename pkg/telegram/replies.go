@@ -0,0 +1,258 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// silenceAllAlerts is the target of the button silencing every alert of the message replied to
+const silenceAllAlerts = "all"
+
+// silenceChoiceButton is sent to choose which alert of the message replied to is silenced
+var silenceChoiceButton = telebot.InlineButton{Unique: "silence_choice"}
+
+// silenceSpec resolves what a silence command asks for, a preset command like /s2h or a duration
+// like 90m given to /silence_for, into the duration, comment and role of the silences: the role of
+// the preset or the silence role
+func (b *Bot) silenceSpec(spec string) (time.Duration, string, string, error) {
+
+	if strings.HasPrefix(spec, "/") {
+		preset := b.silencePreset(spec)
+		if preset == nil {
+			return 0, "", "", errors.New("unknown preset")
+		}
+		return time.Duration(preset.Duration), preset.Comment, preset.Role, nil
+	}
+
+	d, err := parseSilenceDuration(spec)
+	return d, defaultSilenceComment, b.silenceRole, err
+}
+
+// maxChoiceSpec is how long the spec of a silence choice button may be for its data to fit in the 64 bytes
+// Telegram accepts, with the "\fsilence_choice|" prefix, a space and a fingerprint
+const maxChoiceSpec = 64 - len("\fsilence_choice|") - 1 - 16
+
+// choiceSpec returns the spec the buttons of the silence choice carry: the command of the preset
+// without the slash, its index and a checksum of its command if it's too long, or the duration after a +
+func (b *Bot) choiceSpec(spec string, duration time.Duration) string {
+	for i, preset := range b.silencePresets {
+		if preset.Command != spec {
+			continue
+		}
+		if name := strings.TrimPrefix(spec, "/"); len(name) <= maxChoiceSpec {
+			return name
+		}
+		return fmt.Sprintf("#%d.%s", i, presetChecksum(spec))
+	}
+	return "+" + model.Duration(duration).String()
+}
+
+// presetChecksum tells whether the preset of an index is still the one a button was sent with
+func presetChecksum(command string) string {
+	h := fnv.New32a()
+	h.Write([]byte(command))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// choicePreset returns the spec of a silence choice button as silenceSpec takes it. An index whose
+// preset changed since the button was sent is an unknown preset rather than another one.
+func (b *Bot) choicePreset(spec string) string {
+
+	switch {
+	case strings.HasPrefix(spec, "+"):
+		return strings.TrimPrefix(spec, "+")
+	case strings.HasPrefix(spec, "#"):
+		parts := strings.SplitN(strings.TrimPrefix(spec, "#"), ".", 2)
+		i, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 || i < 0 || i >= len(b.silencePresets) ||
+			presetChecksum(b.silencePresets[i].Command) != parts[1] {
+			return "/" + spec
+		}
+		return b.silencePresets[i].Command
+	case strings.HasPrefix(spec, "/"):
+		// The buttons sent before carried the preset command as it was typed
+		return spec
+	case b.silencePreset("/"+spec) != nil:
+		return "/" + spec
+	}
+
+	// The buttons sent before carried the duration as it was typed
+	return spec
+}
+
+// silenceChoiceKeyboard returns the buttons silencing each alert of the message replied to, or all of them
+func (b *Bot) silenceChoiceKeyboard(p *translation.Printer, spec string, duration time.Duration, alerts vendor.Alerts, messageID int) [][]telebot.InlineButton {
+
+	spec = b.choiceSpec(spec, duration)
+
+	var keyboard [][]telebot.InlineButton
+	for _, alert := range alerts {
+		if len(keyboard) == maxAlertButtons {
+			break
+		}
+		fingerprint := alertFingerprint(alert)
+		button := *silenceChoiceButton.With(spec + " " + fingerprint)
+		button.Text = p.Sprintf("buttonSilenceAlert", alert.Labels["alertname"], fingerprint)
+		keyboard = append(keyboard, []telebot.InlineButton{button})
+	}
+	all := *silenceChoiceButton.With(spec + " " + silenceAllAlerts + " " + strconv.Itoa(messageID))
+	all.Text = p.Sprintf("buttonSilenceAll", len(alerts))
+	keyboard = append(keyboard, []telebot.InlineButton{all})
+
+	return keyboard
+}
+
+// firingAlerts returns the alerts of the message that were firing when it was sent or last edited
+func firingAlerts(m SentMessage) vendor.Alerts {
+	if m.Data == nil {
+		return nil
+	}
+	return m.Data.Alerts.Firing()
+}
+
+// repliedAlerts returns the firing alerts of an alert message sent to the chat, store.ErrKeyNotFound if it isn't one
+func (b *Bot) repliedAlerts(chatID int64, messageID int) (vendor.Alerts, error) {
+
+	if b.messageStore == nil {
+		return nil, store.ErrKeyNotFound
+	}
+
+	m, err := b.messageStore.Get(chatID, messageID)
+	if err != nil {
+		return nil, err
+	}
+
+	return firingAlerts(m), nil
+}
+
+// silenceReply silences the firing alerts of the alert message replied to, asking which one
+// with buttons when there are several
+func (b *Bot) silenceReply(message *telebot.Message, spec string) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	duration, comment, _, err := b.silenceSpec(spec)
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+		return
+	}
+
+	alerts, err := b.repliedAlerts(message.Chat.ID, message.ReplyTo.ID)
+	if err == store.ErrKeyNotFound {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceReplyUnknown"))
+		return
+	}
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get message from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+		return
+	}
+
+	switch len(alerts) {
+	case 0:
+		b.telegram.Reply(message, p.Sprintf("responseSilenceReplyNotFiring"))
+
+	case 1:
+		id, err := b.silence(alertFingerprint(alerts[0]), duration, comment)
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseSilenceFail", err))
+			return
+		}
+		b.watchSilence(id, message.Chat)
+		b.telegram.Reply(message, p.Sprintf("responseSilenceCreated"))
+
+	default:
+		keyboard := b.silenceChoiceKeyboard(p, spec, duration, alerts, message.ReplyTo.ID)
+		b.telegram.Reply(
+			message,
+			p.Sprintf("responseSilenceChoose", model.Duration(duration).String()),
+			&telebot.SendOptions{ReplyMarkup: &telebot.ReplyMarkup{InlineKeyboard: keyboard}},
+		)
+	}
+
+}
+
+// handleSilenceChoiceCallback silences the alert chosen, or all alerts of the message replied to
+func (b *Bot) handleSilenceChoiceCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	args := strings.Fields(c.Data)
+	if len(args) < 2 {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceFail", "invalid button"), ShowAlert: true})
+		return
+	}
+	spec := b.choicePreset(args[0])
+	duration, comment, role, err := b.silenceSpec(spec)
+	if err != nil {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceFail", err), ShowAlert: true})
+		return
+	}
+	if !b.hasRole(c.Sender.ID, role) {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceRole", role, spec), ShowAlert: true})
+		return
+	}
+
+	fingerprints := args[1:2]
+	if args[1] == silenceAllAlerts && len(args) == 3 {
+		messageID, _ := strconv.Atoi(args[2])
+		alerts, err := b.repliedAlerts(c.Message.Chat.ID, messageID)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get message from store", "err", err)
+			b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceReplyUnknown"), ShowAlert: true})
+			return
+		}
+		fingerprints = nil
+		for _, alert := range alerts {
+			fingerprints = append(fingerprints, alertFingerprint(alert))
+		}
+	}
+
+	silenced := 0
+	for _, fingerprint := range fingerprints {
+		id, err := b.silence(fingerprint, duration, comment)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to silence alert", "fingerprint", fingerprint, "err", err)
+			continue
+		}
+		b.watchSilence(id, c.Message.Chat)
+		silenced++
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user silenced alerts of a message",
+		"username", c.Sender.Username,
+		"user_id", c.Sender.ID,
+		"silenced", silenced,
+		"duration", duration,
+	)
+
+	text := p.Sprintf("responseSilencedAlerts", silenced, len(fingerprints), model.Duration(duration).String())
+	b.telegram.Respond(c, &telebot.CallbackResponse{Text: text, ShowAlert: silenced < len(fingerprints)})
+
+	// The question is answered, its buttons are removed
+	if _, err := b.telegram.Edit(c.Message, text); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit silence choice", "err", err)
+	}
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestSilenceSpec(t *testing.T) {
+
+	bot := &Bot{
+		logger: log.NewNopLogger(),
+		silencePresets: []SilencePreset{
+			{Command: "/s2h", Duration: model.Duration(2 * time.Hour), Comment: "Maintenance", Role: "leads"},
+		},
+		silenceRole: "oncall",
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a preset
+	// ---------------------------------------------------------------------------
+	d, comment, role, err := bot.silenceSpec("/s2h")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, d)
+	assert.Equal(t, "Maintenance", comment)
+	assert.Equal(t, "leads", role)
+	t.Log("silenceSpec() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a duration given to /silence_for
+	// ---------------------------------------------------------------------------
+	d, comment, role, err = bot.silenceSpec("3d")
+	assert.NoError(t, err)
+	assert.Equal(t, 3*day, d)
+	assert.Equal(t, defaultSilenceComment, comment)
+	assert.Equal(t, "oncall", role)
+	t.Log("silenceSpec() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: unknown presets and invalid durations
+	// ---------------------------------------------------------------------------
+	_, _, _, err = bot.silenceSpec("/s1d")
+	assert.Error(t, err)
+	_, _, _, err = bot.silenceSpec("soon")
+	assert.Error(t, err)
+	t.Log("silenceSpec() : Test 3 PASSED.")
+
+}
+
+func TestSilenceChoiceKeyboard(t *testing.T) {
+
+	cat, _ := translation.NewCatalog(nil)
+	long := "/" + strings.Repeat("s", 32)
+	bot := &Bot{
+		logger: log.NewNopLogger(),
+		silencePresets: []SilencePreset{
+			{Command: "/s2h", Duration: model.Duration(2 * time.Hour)},
+			{Command: long, Duration: model.Duration(14 * day)},
+		},
+	}
+	alerts := vendor.Alerts{
+		{Status: "firing", Labels: vendor.KV{"alertname": "A"}, Fingerprint: "0123456789abcdef"},
+		{Status: "firing", Labels: vendor.KV{"alertname": "B"}, Fingerprint: "fedcba9876543210"},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the data of the buttons fits the 64 bytes Telegram accepts for the longest preset
+	// ---------------------------------------------------------------------------
+	for _, spec := range []string{long, "1y2w3d4h5m6s7ms"} {
+		duration, _, _, err := bot.silenceSpec(spec)
+		assert.NoError(t, err)
+		keyboard := bot.silenceChoiceKeyboard(cat.Printer(), spec, duration, alerts, 2147483647)
+		assert.Len(t, keyboard, 3)
+		for _, row := range keyboard {
+			data := "\f" + row[0].Unique + "|" + row[0].Data
+			assert.True(t, len(data) <= 64, data)
+		}
+	}
+	t.Log("silenceChoiceKeyboard() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the buttons tell the preset by its command, by its index if too long, and the duration
+	// ---------------------------------------------------------------------------
+	keyboard := bot.silenceChoiceKeyboard(cat.Printer(), "/s2h", 2*time.Hour, alerts, 42)
+	assert.Equal(t, "s2h 0123456789abcdef", keyboard[0][0].Data)
+	assert.Equal(t, "s2h all 42", keyboard[2][0].Data)
+	assert.Equal(t, "/s2h", bot.choicePreset("s2h"))
+	keyboard = bot.silenceChoiceKeyboard(cat.Printer(), long, 14*day, alerts, 42)
+	spec := "#1." + presetChecksum(long)
+	assert.Equal(t, spec+" 0123456789abcdef", keyboard[0][0].Data)
+	assert.Equal(t, long, bot.choicePreset(spec))
+	keyboard = bot.silenceChoiceKeyboard(cat.Printer(), "90m", 90*time.Minute, alerts, 42)
+	assert.Equal(t, "+1h30m 0123456789abcdef", keyboard[0][0].Data)
+	assert.Equal(t, "1h30m", bot.choicePreset("+1h30m"))
+	t.Log("silenceChoiceKeyboard() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: buttons sent before the presets changed or in the former format
+	// ---------------------------------------------------------------------------
+	bot.silencePresets = []SilencePreset{bot.silencePresets[1], bot.silencePresets[0]}
+	_, _, _, err := bot.silenceSpec(bot.choicePreset(spec))
+	assert.Error(t, err)
+	_, _, _, err = bot.silenceSpec(bot.choicePreset("#7.00000000"))
+	assert.Error(t, err)
+	assert.Equal(t, long, bot.choicePreset("#0."+presetChecksum(long)))
+	assert.Equal(t, "/s2h", bot.choicePreset("/s2h"))
+	assert.Equal(t, "90m", bot.choicePreset("90m"))
+	t.Log("silenceChoiceKeyboard() : Test 2 PASSED.")
+
+}
+
+func TestRepliedAlerts(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "replies"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, _ := NewMessageStore(kvStore)
+	bot := &Bot{logger: log.NewNopLogger(), messageStore: s}
+
+	assert.NoError(t, s.Add(SentMessage{ChatID: 1, MessageID: 10, SentAt: time.Now(), Parts: 1, Data: &vendor.Data{
+		Alerts: vendor.Alerts{
+			{Status: "firing", Fingerprint: "a"},
+			{Status: "resolved", Fingerprint: "b"},
+			{Status: "firing", Fingerprint: "c"},
+		},
+	}}))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the firing alerts of an alert message
+	// ---------------------------------------------------------------------------
+	alerts, err := bot.repliedAlerts(1, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, alerts, 2) {
+		assert.Equal(t, "a", alertFingerprint(alerts[0]))
+		assert.Equal(t, "c", alertFingerprint(alerts[1]))
+	}
+	t.Log("repliedAlerts() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a message that isn't an alert message of the chat
+	// ---------------------------------------------------------------------------
+	_, err = bot.repliedAlerts(2, 10)
+	assert.Equal(t, store.ErrKeyNotFound, err)
+	_, err = (&Bot{logger: log.NewNopLogger()}).repliedAlerts(1, 10)
+	assert.Equal(t, store.ErrKeyNotFound, err)
+	t.Log("repliedAlerts() : Test 2 PASSED.")
+
+}
@@ -0,0 +1,349 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/robfig/cron/v3"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// topReportAlerts is how many of the most noisy alerts a report lists in .Top
+const topReportAlerts = 5
+
+// reportPeriods are the names accepted for the period a report covers
+var reportPeriods = map[string]time.Duration{
+	"daily":  24 * time.Hour,
+	"weekly": 7 * 24 * time.Hour,
+}
+
+// ReportSchedule of a chat: when a report is sent and the period before it the report covers
+type ReportSchedule struct {
+	// Cron is a standard 5 fields cron expression or a descriptor like @weekly,
+	// evaluated in the chat's timezone
+	Cron   string        `json:"cron"`
+	Period time.Duration `json:"period"`
+	// Last is when the report was added, when it was sent is kept apart by ChatStore.ReportsSent
+	Last time.Time `json:"last"`
+}
+
+// Key identifies the report among the reports of a chat, the same ones are sent together
+func (r ReportSchedule) Key() string {
+	return fmt.Sprintf("%s %s", r.Period, r.Cron)
+}
+
+// Next returns when the report is due next time
+func (r ReportSchedule) Next(location *time.Location) (time.Time, error) {
+	schedule, err := cron.ParseStandard(r.Cron)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return schedule.Next(r.Last.In(location)), nil
+}
+
+// withSent returns the reports with Last set to when they were sent, if later than when they were added
+func withSent(reports []ReportSchedule, sent map[string]time.Time) []ReportSchedule {
+	var out []ReportSchedule
+	for _, r := range reports {
+		if at, ok := sent[r.Key()]; ok && at.After(r.Last) {
+			r.Last = at
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// AlertStats are the statistics of a single alertname within a report
+type AlertStats struct {
+	Alertname string
+	// Fired is how many times the alert started firing
+	Fired int
+	// Resolved is how many times the alert was resolved
+	Resolved int
+	// FiringTime is the total time the alert was firing
+	FiringTime time.Duration
+	// MTTR is the mean time until the alert was resolved
+	MTTR time.Duration
+}
+
+// Report is what the telegram.report template is executed with
+type Report struct {
+	From, To   time.Time
+	Fired      int
+	Resolved   int
+	FiringTime time.Duration
+	MTTR       time.Duration
+	// Alerts are the statistics per alertname, the most fired first
+	Alerts []AlertStats
+	// Top are the first few of the Alerts
+	Top []AlertStats
+}
+
+// newReport summarizes the recorded alerts within the given period
+func newReport(records []AlertRecord, from, to time.Time) Report {
+
+	report := Report{From: from, To: to}
+
+	var total time.Duration
+	stats := map[string]*AlertStats{}
+	repairs := map[string]time.Duration{}
+
+	for _, record := range records {
+
+		name := record.Labels["alertname"]
+		s, ok := stats[name]
+		if !ok {
+			s = &AlertStats{Alertname: name}
+			stats[name] = s
+		}
+
+		if !record.StartsAt.Before(from) && record.StartsAt.Before(to) {
+			s.Fired++
+			report.Fired++
+		}
+		if record.Resolved() && !record.EndsAt.Before(from) && record.EndsAt.Before(to) {
+			s.Resolved++
+			report.Resolved++
+			repairs[name] += record.EndsAt.Sub(record.StartsAt)
+			total += record.EndsAt.Sub(record.StartsAt)
+		}
+
+		firing := record.FiringTime(from, to)
+		s.FiringTime += firing
+		report.FiringTime += firing
+	}
+
+	for name, s := range stats {
+		if s.Resolved > 0 {
+			s.MTTR = (repairs[name] / time.Duration(s.Resolved)).Round(time.Second)
+		}
+		s.FiringTime = s.FiringTime.Round(time.Second)
+		report.Alerts = append(report.Alerts, *s)
+	}
+	if report.Resolved > 0 {
+		report.MTTR = (total / time.Duration(report.Resolved)).Round(time.Second)
+	}
+	report.FiringTime = report.FiringTime.Round(time.Second)
+
+	sort.Slice(report.Alerts, func(i, j int) bool {
+		if report.Alerts[i].Fired != report.Alerts[j].Fired {
+			return report.Alerts[i].Fired > report.Alerts[j].Fired
+		}
+		if report.Alerts[i].FiringTime != report.Alerts[j].FiringTime {
+			return report.Alerts[i].FiringTime > report.Alerts[j].FiringTime
+		}
+		return report.Alerts[i].Alertname < report.Alerts[j].Alertname
+	})
+
+	report.Top = report.Alerts
+	if len(report.Top) > topReportAlerts {
+		report.Top = report.Top[:topReportAlerts]
+	}
+
+	return report
+}
+
+// parseReportPeriod parses daily, weekly or a duration
+func parseReportPeriod(value string) (time.Duration, error) {
+	if period, ok := reportPeriods[value]; ok {
+		return period, nil
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid period %q", value)
+	}
+	return period, nil
+}
+
+// Show, add or delete the scheduled reports of this chat, or send one right now
+func (b *Bot) handleReport(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.historyStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseReportFail"))
+		return
+	}
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseReportFail"))
+		return
+	}
+	location := settings.Schedule.Location()
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		if len(settings.Reports) == 0 {
+			b.telegram.Reply(message, p.Sprintf("responseReportsEmpty", commandReport))
+			return
+		}
+		sent, err := b.chatStore.ReportsSent(*message.Chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get reports from store", "err", err)
+		}
+		var lines []string
+		for i, r := range withSent(settings.Reports, sent) {
+			next, _ := r.Next(location)
+			lines = append(lines, fmt.Sprintf("%d. %s, %s, %s", i+1, r.Cron, r.Period, next.Format("2006-01-02 15:04 MST")))
+		}
+		b.telegram.Reply(message, p.Sprintf("responseReports", strings.Join(lines, "\n"), commandReport))
+		return
+	}
+
+	switch args[0] {
+
+	case "now":
+		period := reportPeriods["daily"]
+		if len(args) > 1 {
+			if period, err = parseReportPeriod(args[1]); err != nil {
+				b.telegram.Reply(message, p.Sprintf("responseReportInvalid", err.Error(), commandReport))
+				return
+			}
+		}
+		now := time.Now()
+		if err := b.sendReport(*message.Chat, now.Add(-period), now); err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseReportFail"))
+		}
+		return
+
+	case "add":
+		if len(args) < 3 {
+			b.telegram.Reply(message, p.Sprintf("responseReportInvalid", "expected a period and a cron expression", commandReport))
+			return
+		}
+		period, err := parseReportPeriod(args[1])
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseReportInvalid", err.Error(), commandReport))
+			return
+		}
+		report := ReportSchedule{Cron: strings.Join(args[2:], " "), Period: period, Last: time.Now()}
+		next, err := report.Next(location)
+		if err != nil {
+			b.telegram.Reply(message, p.Sprintf("responseReportInvalid", err.Error(), commandReport))
+			return
+		}
+		settings.Reports = append(settings.Reports, report)
+		if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseReportFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseReportAdded", next.Format("2006-01-02 15:04 MST")))
+
+	case "del":
+		n := 0
+		if len(args) > 1 {
+			n, _ = strconv.Atoi(args[1])
+		}
+		if n < 1 || n > len(settings.Reports) {
+			b.telegram.Reply(message, p.Sprintf("responseReportInvalid", "unknown report number", commandReport))
+			return
+		}
+		settings.Reports = append(settings.Reports[:n-1], settings.Reports[n:]...)
+		if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+			b.telegram.Reply(message, p.Sprintf("responseReportFail"))
+			return
+		}
+		b.telegram.Reply(message, p.Sprintf("responseReportDeleted", n))
+
+	default:
+		b.telegram.Reply(message, p.Sprintf("responseReportInvalid", fmt.Sprintf("unknown action %q", args[0]), commandReport))
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user changed chat reports",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"action", args[0],
+	)
+
+}
+
+// sendReports sends the reports that are due to their chats
+func (b *Bot) sendReports(now time.Time) {
+
+	if b.historyStore == nil {
+		return
+	}
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		return
+	}
+
+	for _, chat := range chats {
+
+		settings, err := b.chatStore.Settings(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+			continue
+		}
+
+		if len(settings.Reports) == 0 {
+			continue
+		}
+		sent, err := b.chatStore.ReportsSent(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get reports from store", "err", err)
+			continue
+		}
+
+		// The times of the reports deleted are dropped
+		next := map[string]time.Time{}
+		due := false
+		for _, r := range withSent(settings.Reports, sent) {
+			if _, ok := next[r.Key()]; ok {
+				continue
+			}
+			next[r.Key()] = r.Last
+			at, err := r.Next(settings.Schedule.Location())
+			if err != nil || now.Before(at) {
+				continue
+			}
+			// The report is due again on the next tick until it could be sent
+			if err := b.sendReport(chat, now.Add(-r.Period), now); err != nil {
+				continue
+			}
+			next[r.Key()] = now
+			due = true
+		}
+
+		if due || len(next) != len(sent) {
+			if err := b.chatStore.SetReportsSent(chat, next); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to save reports to store", "err", err)
+			}
+		}
+	}
+}
+
+// sendReport renders the report of the given period with the report template and sends it
+func (b *Bot) sendReport(chat telebot.Chat, from, to time.Time) error {
+
+	records, err := b.historyStore.Range(from, to)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get alert history from store", "err", err)
+		return err
+	}
+
+	out, err := b.tmplData("telegram.report", b.printer(&chat, nil), b.location(&chat), newReport(records, from, to))
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to template report", "err", err)
+		return err
+	}
+
+	if len(b.sendHTML(chat, out, false, nil)) == 0 {
+		return errors.New("failed to send report")
+	}
+
+	return nil
+}
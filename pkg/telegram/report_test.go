@@ -0,0 +1,171 @@
+package telegram
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	telebot "gopkg.in/tucnak/telebot.v2"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestNewReport(t *testing.T) {
+
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+
+	records := []AlertRecord{
+		{Status: "resolved", Labels: vendor.KV{"alertname": "A"}, StartsAt: from.Add(time.Hour), EndsAt: from.Add(2 * time.Hour)},
+		{Status: "resolved", Labels: vendor.KV{"alertname": "A"}, StartsAt: from.Add(3 * time.Hour), EndsAt: from.Add(6 * time.Hour)},
+		{Status: "firing", Labels: vendor.KV{"alertname": "B"}, StartsAt: to.Add(-time.Hour)},
+		// Started before the report, counted for its firing time only
+		{Status: "resolved", Labels: vendor.KV{"alertname": "C"}, StartsAt: from.Add(-time.Hour), EndsAt: from.Add(time.Hour)},
+	}
+
+	report := newReport(records, from, to)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: totals
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, 3, report.Fired)
+	assert.Equal(t, 3, report.Resolved)
+	assert.Equal(t, 6*time.Hour, report.FiringTime)
+	// (1h + 3h + 2h) / 3
+	assert.Equal(t, 2*time.Hour, report.MTTR)
+	t.Log("newReport() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: per alertname, the most fired first
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []AlertStats{
+		{Alertname: "A", Fired: 2, Resolved: 2, FiringTime: 4 * time.Hour, MTTR: 2 * time.Hour},
+		{Alertname: "B", Fired: 1, FiringTime: time.Hour},
+		{Alertname: "C", Resolved: 1, FiringTime: time.Hour, MTTR: 2 * time.Hour},
+	}, report.Alerts)
+	assert.Equal(t, report.Alerts, report.Top)
+	t.Log("newReport() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: empty report
+	// ---------------------------------------------------------------------------
+	empty := newReport(nil, from, to)
+	assert.Zero(t, empty.Fired)
+	assert.Empty(t, empty.Top)
+	t.Log("newReport() : Test 3 PASSED.")
+
+}
+
+func TestReportSchedule(t *testing.T) {
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time.LoadLocation() : no timezone database: %s", err)
+	}
+
+	// 2021-03-01 is a Monday
+	r := ReportSchedule{Cron: "0 9 * * 1", Period: 7 * 24 * time.Hour, Last: time.Date(2021, 3, 1, 9, 0, 0, 0, berlin)}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: next run in the chat's timezone
+	// ---------------------------------------------------------------------------
+	next, err := r.Next(berlin)
+	assert.NoError(t, err)
+	assert.True(t, next.Equal(time.Date(2021, 3, 8, 9, 0, 0, 0, berlin)), next)
+	t.Log("Next() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: descriptors and invalid expressions
+	// ---------------------------------------------------------------------------
+	r.Cron = "@daily"
+	next, err = r.Next(berlin)
+	assert.NoError(t, err)
+	assert.True(t, next.Equal(time.Date(2021, 3, 2, 0, 0, 0, 0, berlin)), next)
+
+	r.Cron = "every monday"
+	_, err = r.Next(berlin)
+	assert.Error(t, err)
+	t.Log("Next() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: periods
+	// ---------------------------------------------------------------------------
+	period, err := parseReportPeriod("weekly")
+	assert.NoError(t, err)
+	assert.Equal(t, 7*24*time.Hour, period)
+	period, err = parseReportPeriod("12h")
+	assert.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, period)
+	_, err = parseReportPeriod("-1h")
+	assert.Error(t, err)
+	_, err = parseReportPeriod("fortnightly")
+	assert.Error(t, err)
+	t.Log("parseReportPeriod() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the reports are due after they were sent, not after they were added
+	// ---------------------------------------------------------------------------
+	added := time.Date(2021, 3, 1, 9, 0, 0, 0, berlin)
+	reports := []ReportSchedule{
+		{Cron: "0 9 * * 1", Period: 7 * 24 * time.Hour, Last: added},
+		{Cron: "@daily", Period: 24 * time.Hour, Last: added},
+	}
+	sent := map[string]time.Time{
+		reports[0].Key(): added.AddDate(0, 0, 7),
+		reports[1].Key(): added.AddDate(0, 0, -7),
+	}
+	reports = withSent(reports, sent)
+	assert.True(t, reports[0].Last.Equal(added.AddDate(0, 0, 7)), reports[0].Last)
+	assert.True(t, reports[1].Last.Equal(added), reports[1].Last)
+	assert.Equal(t, "168h0m0s 0 9 * * 1", reports[0].Key())
+	t.Log("withSent() : Test 1 PASSED.")
+
+}
+
+// failingHistory is a history store whose records can't be read
+type failingHistory struct{}
+
+func (failingHistory) Record(time.Time, ...vendor.Alert) error { return nil }
+func (failingHistory) Range(from, to time.Time) ([]AlertRecord, error) {
+	return nil, errors.New("store unavailable")
+}
+func (failingHistory) Prune(before time.Time) error { return nil }
+
+func TestSendReports(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "reports"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	chats, _ := NewChatStore(kvStore)
+	chat := telebot.Chat{ID: 3030}
+	added := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, chats.Add(chat))
+	assert.NoError(t, chats.SetSettings(chat, ChatSettings{Reports: []ReportSchedule{{Cron: "@daily", Period: 24 * time.Hour, Last: added}}}))
+	assert.NoError(t, chats.SetReportsSent(chat, map[string]time.Time{}))
+
+	bot := &Bot{logger: log.NewNopLogger(), chatStore: chats, historyStore: failingHistory{}}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a report that couldn't be sent stays due
+	// ---------------------------------------------------------------------------
+	bot.sendReports(time.Now())
+	sent, err := chats.ReportsSent(chat)
+	assert.NoError(t, err)
+	assert.True(t, sent["24h0m0s @daily"].Equal(added), sent)
+	t.Log("sendReports() : Test 1 PASSED.")
+
+	assert.NoError(t, chats.Remove(chat))
+
+}
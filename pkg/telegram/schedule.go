@@ -0,0 +1,262 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+)
+
+const clockLayout = "15:04"
+
+// severityLevels orders the usual values of the severity label, unknown or
+// missing severities rank lowest
+var severityLevels = map[string]int{
+	"info":     1,
+	"warning":  2,
+	"minor":    2,
+	"error":    3,
+	"major":    3,
+	"critical": 4,
+	"page":     4,
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule of a chat: its timezone and the quiet hours when only alerts of
+// the given severity or above notify loudly
+type Schedule struct {
+	// Timezone is an IANA name, empty means the bot's local time
+	Timezone string `json:"timezone,omitempty"`
+	// QuietFrom and QuietTo are "15:04" clock times, the window may cross midnight,
+	// equal times mean the whole day and empty ones disable the quiet hours
+	QuietFrom string `json:"quietFrom,omitempty"`
+	QuietTo   string `json:"quietTo,omitempty"`
+	// Days the quiet hours start on, empty means every day
+	Days []time.Weekday `json:"days,omitempty"`
+	// Severity still notifying as usual during quiet hours, empty means none
+	Severity string `json:"severity,omitempty"`
+	// Hold alerts during quiet hours and send them once they end instead of silently
+	Hold bool `json:"hold,omitempty"`
+}
+
+// Location returns the schedule's timezone, the local one if unset or unknown
+func (s *Schedule) Location() *time.Location {
+	if s == nil || s.Timezone == "" {
+		return time.Local
+	}
+	location, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return location
+}
+
+// Quiet returns whether t falls into the quiet hours
+func (s *Schedule) Quiet(t time.Time) bool {
+
+	if s == nil || s.QuietFrom == "" || s.QuietTo == "" {
+		return false
+	}
+
+	from, err := time.Parse(clockLayout, s.QuietFrom)
+	if err != nil {
+		return false
+	}
+	to, err := time.Parse(clockLayout, s.QuietTo)
+	if err != nil {
+		return false
+	}
+
+	local := t.In(s.Location())
+	now := local.Hour()*60 + local.Minute()
+	start := from.Hour()*60 + from.Minute()
+	end := to.Hour()*60 + to.Minute()
+	day := local.Weekday()
+
+	switch {
+	case start == end:
+		return s.onDay(day)
+	case start < end:
+		return now >= start && now < end && s.onDay(day)
+	default:
+		// The window crosses midnight, the early hours belong to the previous day
+		if now >= start {
+			return s.onDay(day)
+		}
+		return now < end && s.onDay((day+6)%7)
+	}
+}
+
+// Loud returns whether the alert notifies as usual even during quiet hours
+func (s *Schedule) Loud(alert vendor.Alert) bool {
+	if s == nil || s.Severity == "" {
+		return false
+	}
+	return severityLevels[strings.ToLower(alert.Labels["severity"])] >= severityLevels[strings.ToLower(s.Severity)]
+}
+
+// onDay returns whether the quiet hours start on the given day
+func (s *Schedule) onDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the schedule in the same key=value form as it is set
+func (s *Schedule) String() string {
+
+	if s == nil {
+		return ""
+	}
+
+	var fields []string
+	if s.Timezone != "" {
+		fields = append(fields, "tz="+s.Timezone)
+	}
+	if s.QuietFrom != "" {
+		fields = append(fields, fmt.Sprintf("quiet=%s-%s", s.QuietFrom, s.QuietTo))
+	}
+	if len(s.Days) > 0 {
+		var days []string
+		for _, d := range s.Days {
+			days = append(days, strings.ToLower(d.String()[:3]))
+		}
+		fields = append(fields, "days="+strings.Join(days, ","))
+	}
+	if s.Severity != "" {
+		fields = append(fields, "severity="+s.Severity)
+	}
+	if s.QuietFrom != "" {
+		mode := "silent"
+		if s.Hold {
+			mode = "hold"
+		}
+		fields = append(fields, "mode="+mode)
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// parseSchedule applies key=value arguments on top of the current schedule:
+// tz=Europe/Berlin quiet=22:00-08:00 days=mon-fri,sun severity=critical mode=hold|silent,
+// quiet=off disables the quiet hours
+func parseSchedule(current *Schedule, args []string) (*Schedule, error) {
+
+	s := &Schedule{}
+	if current != nil {
+		*s = *current
+	}
+
+	for _, arg := range args {
+
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("expected key=value, got %q", arg)
+		}
+		key, value := strings.ToLower(kv[0]), kv[1]
+
+		switch key {
+		case "tz":
+			if _, err := time.LoadLocation(value); err != nil {
+				return nil, fmt.Errorf("unknown timezone %q", value)
+			}
+			s.Timezone = value
+
+		case "quiet":
+			if value == "off" {
+				s.QuietFrom, s.QuietTo = "", ""
+				continue
+			}
+			window := strings.SplitN(value, "-", 2)
+			if len(window) != 2 {
+				return nil, fmt.Errorf("expected quiet=15:04-15:04, got %q", value)
+			}
+			for _, clock := range window {
+				if _, err := time.Parse(clockLayout, clock); err != nil {
+					return nil, fmt.Errorf("invalid time %q", clock)
+				}
+			}
+			s.QuietFrom, s.QuietTo = window[0], window[1]
+
+		case "days":
+			days, err := parseWeekdays(value)
+			if err != nil {
+				return nil, err
+			}
+			s.Days = days
+
+		case "severity":
+			if _, ok := severityLevels[strings.ToLower(value)]; !ok && value != "none" {
+				return nil, fmt.Errorf("unknown severity %q", value)
+			}
+			s.Severity = strings.ToLower(value)
+			if s.Severity == "none" {
+				s.Severity = ""
+			}
+
+		case "mode":
+			switch value {
+			case "silent":
+				s.Hold = false
+			case "hold":
+				s.Hold = true
+			default:
+				return nil, fmt.Errorf("unknown mode %q, expected silent or hold", value)
+			}
+
+		default:
+			return nil, fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	return s, nil
+}
+
+// parseWeekdays parses comma separated days and ranges like "mon-fri,sun", "all" means every day
+func parseWeekdays(value string) ([]time.Weekday, error) {
+
+	if value == "all" {
+		return nil, nil
+	}
+
+	var days []time.Weekday
+	for _, part := range strings.Split(strings.ToLower(value), ",") {
+
+		bounds := strings.SplitN(part, "-", 2)
+		first, ok := weekdays[bounds[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", bounds[0])
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, ok = weekdays[bounds[1]]; !ok {
+				return nil, fmt.Errorf("unknown day %q", bounds[1])
+			}
+		}
+
+		for day := first; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return days, nil
+}
@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestSchedule(t *testing.T) {
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time.LoadLocation() : no timezone database: %s", err)
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: no schedule is never quiet
+	// ---------------------------------------------------------------------------
+	var none *Schedule
+	assert.False(t, none.Quiet(time.Now()))
+	assert.Equal(t, time.Local, none.Location())
+	t.Log("Quiet() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the window crosses midnight in the chat's timezone
+	// ---------------------------------------------------------------------------
+	s := &Schedule{Timezone: "Europe/Berlin", QuietFrom: "22:00", QuietTo: "08:00"}
+	assert.True(t, s.Quiet(time.Date(2021, 3, 1, 23, 30, 0, 0, berlin)))
+	assert.True(t, s.Quiet(time.Date(2021, 3, 2, 3, 0, 0, 0, berlin)))
+	assert.False(t, s.Quiet(time.Date(2021, 3, 2, 8, 0, 0, 0, berlin)))
+	assert.False(t, s.Quiet(time.Date(2021, 3, 2, 12, 0, 0, 0, berlin)))
+	// 02:00 UTC is 03:00 in Berlin
+	assert.True(t, s.Quiet(time.Date(2021, 3, 2, 2, 0, 0, 0, time.UTC)))
+	// 21:30 UTC is 22:30 in Berlin
+	assert.True(t, s.Quiet(time.Date(2021, 3, 2, 21, 30, 0, 0, time.UTC)))
+	t.Log("Quiet() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the early hours belong to the day the window started on
+	// ---------------------------------------------------------------------------
+	s.Days = []time.Weekday{time.Friday}
+	// 2021-03-05 is a Friday
+	assert.True(t, s.Quiet(time.Date(2021, 3, 5, 23, 0, 0, 0, berlin)))
+	assert.True(t, s.Quiet(time.Date(2021, 3, 6, 3, 0, 0, 0, berlin)))
+	assert.False(t, s.Quiet(time.Date(2021, 3, 5, 3, 0, 0, 0, berlin)))
+	t.Log("Quiet() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: equal times are quiet all day
+	// ---------------------------------------------------------------------------
+	s = &Schedule{QuietFrom: "00:00", QuietTo: "00:00", Days: []time.Weekday{time.Saturday, time.Sunday}}
+	assert.True(t, s.Quiet(time.Date(2021, 3, 6, 12, 0, 0, 0, time.Local)))
+	assert.False(t, s.Quiet(time.Date(2021, 3, 8, 12, 0, 0, 0, time.Local)))
+	t.Log("Quiet() : Test 4 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: severity threshold
+	// ---------------------------------------------------------------------------
+	s.Severity = "critical"
+	assert.True(t, s.Loud(vendor.Alert{Labels: vendor.KV{"severity": "critical"}}))
+	assert.True(t, s.Loud(vendor.Alert{Labels: vendor.KV{"severity": "Page"}}))
+	assert.False(t, s.Loud(vendor.Alert{Labels: vendor.KV{"severity": "warning"}}))
+	assert.False(t, s.Loud(vendor.Alert{Labels: vendor.KV{}}))
+	s.Severity = ""
+	assert.False(t, s.Loud(vendor.Alert{Labels: vendor.KV{"severity": "critical"}}))
+	t.Log("Loud() : Test 1 PASSED.")
+
+}
+
+func TestParseSchedule(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: all keys
+	// ---------------------------------------------------------------------------
+	s, err := parseSchedule(nil, []string{"tz=UTC", "quiet=22:00-08:00", "days=mon-fri,sun", "severity=Critical", "mode=hold"})
+	if err != nil {
+		t.Fatalf("parseSchedule() : Test 1 FAILED, got error: %s", err)
+	}
+	assert.Equal(t, &Schedule{
+		Timezone:  "UTC",
+		QuietFrom: "22:00",
+		QuietTo:   "08:00",
+		Days:      []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Sunday},
+		Severity:  "critical",
+		Hold:      true,
+	}, s)
+	assert.Equal(t, "tz=UTC quiet=22:00-08:00 days=mon,tue,wed,thu,fri,sun severity=critical mode=hold", s.String())
+	t.Log("parseSchedule() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: applied on top of the current schedule, which is left untouched
+	// ---------------------------------------------------------------------------
+	updated, err := parseSchedule(s, []string{"quiet=off", "days=all", "severity=none"})
+	if err != nil {
+		t.Fatalf("parseSchedule() : Test 2 FAILED, got error: %s", err)
+	}
+	assert.Equal(t, "tz=UTC", updated.String())
+	assert.Equal(t, "22:00", s.QuietFrom)
+	t.Log("parseSchedule() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: ranges wrap around the week
+	// ---------------------------------------------------------------------------
+	days, err := parseWeekdays("fri-mon")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Weekday{time.Friday, time.Saturday, time.Sunday, time.Monday}, days)
+	t.Log("parseWeekdays() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid arguments
+	// ---------------------------------------------------------------------------
+	for _, args := range [][]string{
+		{"quiet"},
+		{"tz=Nowhere/Special"},
+		{"quiet=22-08"},
+		{"quiet=22:00"},
+		{"days=someday"},
+		{"severity=apocalyptic"},
+		{"mode=loud"},
+		{"color=red"},
+	} {
+		_, err := parseSchedule(nil, args)
+		assert.Error(t, err, args)
+	}
+	t.Log("parseSchedule() : Test 3 PASSED.")
+
+}
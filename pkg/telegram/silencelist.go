@@ -0,0 +1,350 @@
+package telegram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	// silencesPerPage is how many silences a page of /silences shows
+	silencesPerPage = 5
+	// silencesExtension is how long the button of /silences extends a silence by
+	silencesExtension = 4 * time.Hour
+)
+
+var (
+	// silencesPageButton turns the pages of /silences, the query is read again from the command replied to
+	silencesPageButton = telebot.InlineButton{Unique: "silences_page"}
+	// silencesExpireButton expires a silence of /silences, its data is the silence ID and the page
+	silencesExpireButton = telebot.InlineButton{Unique: "silences_expire"}
+	// silencesExtendButton extends a silence of /silences by silencesExtension
+	silencesExtendButton = telebot.InlineButton{Unique: "silences_extend"}
+	// silencesCloneButton creates a silence again with the matchers and the duration of a silence of /silences
+	silencesCloneButton = telebot.InlineButton{Unique: "silences_clone"}
+)
+
+// silencesQuery is what /silences is asked for
+type silencesQuery struct {
+	states   map[vendor.SilenceState]bool
+	matchers vendor.Matchers
+}
+
+// parseSilencesQuery parses the arguments of /silences: the states active, pending or
+// expired and matchers. Without a state the active and pending silences are listed.
+func parseSilencesQuery(s string) (silencesQuery, error) {
+
+	q := silencesQuery{states: map[vendor.SilenceState]bool{}}
+
+	var matchers []string
+	for _, token := range splitQuoted(s) {
+		switch state := vendor.SilenceState(token); state {
+		case vendor.SilenceStateActive, vendor.SilenceStatePending, vendor.SilenceStateExpired:
+			q.states[state] = true
+		default:
+			if token = strings.Trim(token, ","); token != "" {
+				matchers = append(matchers, token)
+			}
+		}
+	}
+
+	if len(q.states) == 0 {
+		q.states[vendor.SilenceStateActive] = true
+		q.states[vendor.SilenceStatePending] = true
+	}
+
+	if len(matchers) > 0 {
+		ms, err := vendor.ParseMatchers(strings.Join(matchers, ","))
+		if err != nil {
+			return q, err
+		}
+		q.matchers = ms
+	}
+
+	return q, nil
+}
+
+// matches returns whether the silence is in a state of the query and its equality
+// matchers, taken as labels, match the matchers of the query
+func (q silencesQuery) matches(s vendor.Silence) bool {
+
+	if !q.states[s.Status.State] {
+		return false
+	}
+
+	labels := model.LabelSet{}
+	for _, m := range s.Matchers {
+		if m.Type == vendor.MatchEqual {
+			labels[model.LabelName(m.Name)] = model.LabelValue(m.Value)
+		}
+	}
+
+	return q.matchers.Matches(labels)
+}
+
+// filterSilences returns the silences matching the query in the order given
+func filterSilences(silences []vendor.Silence, q silencesQuery) []vendor.Silence {
+
+	var filtered []vendor.Silence
+	for _, s := range silences {
+		if q.matches(s) {
+			filtered = append(filtered, s)
+		}
+	}
+
+	return filtered
+}
+
+// silencesButtons returns a row of buttons for each silence of the page, numbered as listed
+func silencesButtons(p *translation.Printer, silences []vendor.Silence, page int) [][]telebot.InlineButton {
+
+	// Callback data is limited to 64 bytes, 4h is shorter than 4h0m0s
+	extension := strings.TrimSuffix(silencesExtension.String(), "0m0s")
+
+	var rows [][]telebot.InlineButton
+	for i, s := range silences {
+		data := s.ID + " " + strconv.Itoa(page)
+
+		var row []telebot.InlineButton
+		if s.Status.State != vendor.SilenceStateExpired {
+			expire := *silencesExpireButton.With(data)
+			expire.Text = p.Sprintf("buttonSilencesExpire", i+1)
+			extend := *silencesExtendButton.With(data)
+			extend.Text = p.Sprintf("buttonSilencesExtend", i+1, extension)
+			row = append(row, expire, extend)
+		}
+		clone := *silencesCloneButton.With(data)
+		clone.Text = p.Sprintf("buttonSilencesClone", i+1)
+		rows = append(rows, append(row, clone))
+	}
+
+	return rows
+}
+
+// silencesPage returns the text and the buttons of the page of /silences
+func (b *Bot) silencesPage(p *translation.Printer, q silencesQuery, page int) (string, *telebot.ReplyMarkup, error) {
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		return "", nil, err
+	}
+	silences = filterSilences(silences, q)
+
+	if len(silences) == 0 {
+		return p.Sprintf("responseNoSilences"), nil, nil
+	}
+
+	from, to, page, pages := pageOf(len(silences), page, silencesPerPage)
+
+	var out string
+	if pages > 1 {
+		// The page is told first, a long page is truncated at its end
+		out = p.Sprintf("responseSilencesPage", page, pages, len(silences)) + "\n\n"
+	}
+	for i, s := range silences[from:to] {
+		out += fmt.Sprintf("%d. %s\n", i+1, alertmanager.SilenceMessage(s))
+	}
+
+	keyboard := silencesButtons(p, silences[from:to], page)
+
+	var row []telebot.InlineButton
+	if page > 1 {
+		prev := *silencesPageButton.With(strconv.Itoa(page - 1))
+		prev.Text = p.Sprintf("buttonPrevious")
+		row = append(row, prev)
+	}
+	if page < pages {
+		next := *silencesPageButton.With(strconv.Itoa(page + 1))
+		next.Text = p.Sprintf("buttonNext")
+		row = append(row, next)
+	}
+	if len(row) > 0 {
+		keyboard = append(keyboard, row)
+	}
+
+	return b.truncateMessage(out), &telebot.ReplyMarkup{InlineKeyboard: keyboard}, nil
+}
+
+// cloneSilence creates a silence again from now with the matchers, the comment and the
+// duration of the silence of the ID. The new silence is watched for the chat.
+func (b *Bot) cloneSilence(id string, chat *telebot.Chat) (vendor.Silence, error) {
+
+	s, err := b.findSilence(id)
+	if err != nil {
+		return vendor.Silence{}, err
+	}
+
+	now := time.Now()
+	s.ID, s.CreatedBy = "", silenceCreatedBy
+	s.StartsAt, s.EndsAt, s.UpdatedAt = now, now.Add(s.EndsAt.Sub(s.StartsAt)), now
+	s.Status = vendor.SilenceStatus{State: vendor.CalcSilenceState(s.StartsAt, s.EndsAt)}
+
+	created, err := alertmanager.PostSilence(b.logger, b.alertmanager.String(), s)
+	if err != nil {
+		return vendor.Silence{}, err
+	}
+	if created != "" {
+		s.ID = created
+	}
+	b.watchSilence(s.ID, chat)
+
+	return s, nil
+}
+
+// List the silences, filtered by state and matchers and paginated with buttons to change them
+func (b *Bot) handleSilences(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	q, err := parseSilencesQuery(commandArgs(message.Text))
+	if err != nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilencesInvalid", err.Error(), commandSilences))
+		return
+	}
+
+	text, markup, err := b.silencesPage(p, q, 1)
+	if err != nil {
+		b.telegram.Send(message.Chat, p.Sprintf("responseSilencesFail", err))
+		level.Error(b.logger).Log("msg", "failed to get silences", "err", err)
+		return
+	}
+
+	// The pages reply to the command to read the query again when turned
+	_, err = b.telegram.Reply(message, text, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown, ReplyMarkup: markup})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send list of silences", "err", err)
+	}
+
+}
+
+// editSilencesPage edits the list of silences of the callback to show the page again
+func (b *Bot) editSilencesPage(c *telebot.Callback, p *translation.Printer, page int) error {
+
+	// Without the command replied to, e.g. when it was deleted, the active and pending silences are listed
+	var args string
+	if c.Message.ReplyTo != nil {
+		args = commandArgs(c.Message.ReplyTo.Text)
+	}
+	q, _ := parseSilencesQuery(args)
+
+	text, markup, err := b.silencesPage(p, q, page)
+	if err != nil {
+		return err
+	}
+
+	_, err = b.telegram.Edit(c.Message, text, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown, ReplyMarkup: markup})
+	return err
+}
+
+// handleSilencesPageCallback edits the list of silences to show the page of the button
+func (b *Bot) handleSilencesPageCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.Data)
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{})
+	if err := b.editSilencesPage(c, p, page); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit list of silences", "err", err)
+	}
+}
+
+// handleSilencesAction changes the silence of the pressed button of /silences and shows its page again
+func (b *Bot) handleSilencesAction(c *telebot.Callback, action string, change func(id string) (string, error)) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	// The buttons silence for any duration like /silence_for
+	if !b.hasRole(c.Sender.ID, b.silenceRole) {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceRole", b.silenceRole, commandSilences), ShowAlert: true})
+		return
+	}
+
+	args := strings.Fields(c.Data)
+	if len(args) != 2 {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilencesActionFail", c.Data), ShowAlert: true})
+		return
+	}
+	page, _ := strconv.Atoi(args[1])
+
+	response, err := change(args[0])
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to "+action+" silence", "silence", args[0], "err", err)
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilencesActionFail", err), ShowAlert: true})
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user changed silence",
+		"action", action,
+		"username", c.Sender.Username,
+		"user_id", c.Sender.ID,
+		"silence", args[0],
+	)
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{Text: response})
+	if err := b.editSilencesPage(c, p, page); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit list of silences", "err", err)
+	}
+}
+
+// handleSilencesExpireCallback expires the silence of the pressed button of /silences
+func (b *Bot) handleSilencesExpireCallback(c *telebot.Callback) {
+	b.handleSilencesAction(c, "expire", func(id string) (string, error) {
+		if err := alertmanager.DeleteSuperSilence(b.logger, b.alertmanager.String(), id); err != nil {
+			return "", err
+		}
+		return b.printer(c.Message.Chat, c.Sender).Sprintf("responseSilencesExpired"), nil
+	})
+}
+
+// handleSilencesExtendCallback extends the silence of the pressed button of /silences
+func (b *Bot) handleSilencesExtendCallback(c *telebot.Callback) {
+	b.handleSilencesAction(c, "extend", func(id string) (string, error) {
+		s, err := b.extendSilence(id, silencesExtension, c.Message.Chat)
+		if err != nil {
+			return "", err
+		}
+		until := s.EndsAt.In(b.location(c.Message.Chat)).Format("2006-01-02 15:04 MST")
+		return b.printer(c.Message.Chat, c.Sender).Sprintf("responseSilenceExtended", until), nil
+	})
+}
+
+// handleSilencesCloneCallback creates the silence of the pressed button of /silences again
+func (b *Bot) handleSilencesCloneCallback(c *telebot.Callback) {
+	b.handleSilencesAction(c, "clone", func(id string) (string, error) {
+		s, err := b.cloneSilence(id, c.Message.Chat)
+		if err != nil {
+			return "", err
+		}
+		until := s.EndsAt.In(b.location(c.Message.Chat)).Format("2006-01-02 15:04 MST")
+		return b.printer(c.Message.Chat, c.Sender).Sprintf("responseSilencesCloned", until), nil
+	})
+}
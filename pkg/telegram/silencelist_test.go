@@ -0,0 +1,107 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/translation"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseSilencesQuery(t *testing.T) {
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the active and pending silences by default
+	// ---------------------------------------------------------------------------
+	q, err := parseSilencesQuery("")
+	assert.NoError(t, err)
+	assert.Equal(t, map[vendor.SilenceState]bool{vendor.SilenceStateActive: true, vendor.SilenceStatePending: true}, q.states)
+	assert.Empty(t, q.matchers)
+	t.Log("parseSilencesQuery() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: states and matchers
+	// ---------------------------------------------------------------------------
+	q, err = parseSilencesQuery(`expired alertname="NodeDown", instance=~"web.*"`)
+	assert.NoError(t, err)
+	assert.Equal(t, map[vendor.SilenceState]bool{vendor.SilenceStateExpired: true}, q.states)
+	assert.Len(t, q.matchers, 2)
+	t.Log("parseSilencesQuery() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: invalid matchers
+	// ---------------------------------------------------------------------------
+	for _, s := range []string{"expiring", `team=~"("`} {
+		_, err := parseSilencesQuery(s)
+		assert.Error(t, err, s)
+	}
+	t.Log("parseSilencesQuery() : Test 3 PASSED.")
+
+}
+
+func TestFilterSilences(t *testing.T) {
+
+	silence := func(id string, state vendor.SilenceState, matchers ...*vendor.Matcher) vendor.Silence {
+		return vendor.Silence{ID: id, Matchers: matchers, Status: vendor.SilenceStatus{State: state}}
+	}
+	nodeDown := &vendor.Matcher{Type: vendor.MatchEqual, Name: "alertname", Value: "NodeDown"}
+	web := &vendor.Matcher{Type: vendor.MatchEqual, Name: "instance", Value: "web-1"}
+	anyWeb := &vendor.Matcher{Type: vendor.MatchRegexp, Name: "instance", Value: "web.*"}
+
+	silences := []vendor.Silence{
+		silence("a", vendor.SilenceStateActive, nodeDown, web),
+		silence("b", vendor.SilenceStatePending, nodeDown),
+		silence("c", vendor.SilenceStateExpired, nodeDown, web),
+		silence("d", vendor.SilenceStateActive, nodeDown, anyWeb),
+	}
+	ids := func(silences []vendor.Silence) []string {
+		var ids []string
+		for _, s := range silences {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the long expired silences are left out
+	// ---------------------------------------------------------------------------
+	q, _ := parseSilencesQuery("")
+	assert.Equal(t, []string{"a", "b", "d"}, ids(filterSilences(silences, q)))
+	t.Log("filterSilences() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: matchers are checked against the equality matchers of the silences
+	// ---------------------------------------------------------------------------
+	q, _ = parseSilencesQuery(`active expired instance=~"web-.*"`)
+	assert.Equal(t, []string{"a", "c"}, ids(filterSilences(silences, q)))
+	t.Log("filterSilences() : Test 2 PASSED.")
+
+}
+
+func TestSilencesButtons(t *testing.T) {
+
+	cat, _ := translation.NewCatalog(nil)
+	silences := []vendor.Silence{
+		{ID: "a", Status: vendor.SilenceStatus{State: vendor.SilenceStateActive}, EndsAt: time.Now().Add(time.Hour)},
+		{ID: "b", Status: vendor.SilenceStatus{State: vendor.SilenceStateExpired}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: expired silences can only be cloned
+	// ---------------------------------------------------------------------------
+	rows := silencesButtons(cat.Printer(), silences, 2)
+	if assert.Len(t, rows, 2) && assert.Len(t, rows[0], 3) && assert.Len(t, rows[1], 1) {
+		assert.Equal(t, "silences_expire", rows[0][0].Unique)
+		assert.Equal(t, "a 2", rows[0][0].Data)
+		assert.Equal(t, "silences_clone", rows[1][0].Unique)
+		assert.Equal(t, "b 2", rows[1][0].Data)
+	}
+	t.Log("silencesButtons() : Test 1 PASSED.")
+
+}
@@ -0,0 +1,631 @@
+package telegram
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/go-kit/kit/log/level"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+const (
+	telegramSilencesDirectory = "telegram/silences"
+	telegramSeenSilencesKey   = "telegram/seen_silences"
+)
+
+// silenceCreatedBy is who the silences created with the bot are created by
+const silenceCreatedBy = "alertmanager-bot"
+
+// Kinds of silence events posted to the chats that opted in
+const (
+	silenceCreated = "created"
+	silenceUpdated = "updated"
+	silenceExpired = "expired"
+)
+
+// silenceEventResponses are the messages the kinds of silence events are posted with
+var silenceEventResponses = map[string]string{
+	silenceCreated: "responseSilenceEventCreated",
+	silenceUpdated: "responseSilenceEventUpdated",
+	silenceExpired: "responseSilenceEventExpired",
+}
+
+// SilenceEvent is a change of a silence seen when polling Alertmanager
+type SilenceEvent struct {
+	Kind    string
+	Silence vendor.Silence
+}
+
+// silenceExtensions are the durations offered to extend an expiring silence by
+var silenceExtensions = []time.Duration{time.Hour, 4 * time.Hour, 24 * time.Hour}
+
+// errSilenceNotFound is returned when acting on a silence Alertmanager doesn't know
+var errSilenceNotFound = errors.New("silence not found")
+
+var (
+	// silenceExtendButton extends an expiring silence by the duration in its data
+	silenceExtendButton = telebot.InlineButton{Unique: "silence_extend"}
+	// silenceExpireButton lets an expiring silence expire
+	silenceExpireButton = telebot.InlineButton{Unique: "silence_expire"}
+)
+
+// SilenceWatch is what the bot keeps about a silence to warn before it expires
+type SilenceWatch struct {
+	ID string `json:"id"`
+	// ChatID is the chat the silence was created from, zero if it was created outside the bot
+	ChatID int64 `json:"chatId,omitempty"`
+	// Warned is the end of the silence the chat was warned about, an extended silence is warned about again
+	Warned time.Time `json:"warned"`
+}
+
+// SilenceStore writes what the bot keeps about silences to a libkv store backend
+type SilenceStore struct {
+	kv store.Store
+}
+
+// NewSilenceStore stores silences watched in the provided kv backend
+func NewSilenceStore(kv store.Store) (*SilenceStore, error) {
+	return &SilenceStore{kv: kv}, nil
+}
+
+// List all silences watched
+func (s *SilenceStore) List() ([]SilenceWatch, error) {
+
+	kvPairs, err := s.kv.List(telegramSilencesDirectory)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var watches []SilenceWatch
+	for _, kv := range kvPairs {
+		var w SilenceWatch
+		if err := json.Unmarshal(kv.Value, &w); err != nil {
+			return nil, err
+		}
+		watches = append(watches, w)
+	}
+
+	return watches, nil
+}
+
+// Get a silence watched, nil if it isn't
+func (s *SilenceStore) Get(id string) (*SilenceWatch, error) {
+
+	key := fmt.Sprintf("%s/%s", telegramSilencesDirectory, id)
+
+	kv, err := s.kv.Get(key)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var w SilenceWatch
+	if err := json.Unmarshal(kv.Value, &w); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// Set a silence watched
+func (s *SilenceStore) Set(w SilenceWatch) error {
+	b, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("%s/%s", telegramSilencesDirectory, w.ID)
+
+	return s.kv.Put(key, b, nil)
+}
+
+// Remove a silence watched
+func (s *SilenceStore) Remove(id string) error {
+	key := fmt.Sprintf("%s/%s", telegramSilencesDirectory, id)
+	err := s.kv.Delete(key)
+	if err == store.ErrKeyNotFound {
+		return nil
+	}
+	return err
+}
+
+// Seen returns the silences not expired the last time they were polled, nil if they weren't yet
+func (s *SilenceStore) Seen() (map[string]vendor.Silence, error) {
+
+	kv, err := s.kv.Get(telegramSeenSilencesKey)
+	if err == store.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]vendor.Silence{}
+	err = json.Unmarshal(kv.Value, &seen)
+
+	return seen, err
+}
+
+// SetSeen saves the silences not expired polled, nil forgets them
+func (s *SilenceStore) SetSeen(seen map[string]vendor.Silence) error {
+
+	if seen == nil {
+		err := s.kv.Delete(telegramSeenSilencesKey)
+		if err == store.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+
+	b, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+
+	return s.kv.Put(telegramSeenSilencesKey, b, nil)
+}
+
+// watchSilence remembers the chat a silence was created from to warn it before the silence expires
+func (b *Bot) watchSilence(id string, chat *telebot.Chat) {
+
+	if b.silenceStore == nil || id == "" {
+		return
+	}
+
+	if err := b.silenceStore.Set(SilenceWatch{ID: id, ChatID: chat.ID}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save silence to store", "err", err)
+	}
+}
+
+// expiringSilences returns the active silences ending within the warning time not warned about yet
+func expiringSilences(silences []vendor.Silence, watches map[string]SilenceWatch, warning time.Duration, now time.Time) []vendor.Silence {
+
+	var expiring []vendor.Silence
+	for _, s := range silences {
+		if s.Status.State != vendor.SilenceStateActive || !s.EndsAt.After(now) || s.EndsAt.Sub(now) > warning {
+			continue
+		}
+		if w, ok := watches[s.ID]; ok && w.Warned.Equal(s.EndsAt) {
+			continue
+		}
+		expiring = append(expiring, s)
+	}
+
+	return expiring
+}
+
+// warningChats returns the chats warned about the silence of the watch: the chat it was created
+// from, or the private chats of all admins for the silences created outside the bot
+func (b *Bot) warningChats(w SilenceWatch) []telebot.Chat {
+
+	if w.ChatID != 0 {
+		return []telebot.Chat{{ID: w.ChatID}}
+	}
+
+	var chats []telebot.Chat
+	for _, admin := range b.admins {
+		chats = append(chats, telebot.Chat{ID: int64(admin), Type: telebot.ChatPrivate})
+	}
+
+	return chats
+}
+
+// warnSilences warns the chats the silences ending soon were created from, the silences
+// created outside the bot are warned about to the admins, the watches of expired silences are removed
+func (b *Bot) warnSilences(now time.Time) {
+
+	if b.silenceStore == nil || b.silenceWarning <= 0 {
+		return
+	}
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get silences, postponing expiry warnings", "err", err)
+		return
+	}
+
+	list, err := b.silenceStore.List()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get silences from store", "err", err)
+		return
+	}
+	watches := map[string]SilenceWatch{}
+	for _, w := range list {
+		watches[w.ID] = w
+	}
+
+	for _, s := range expiringSilences(silences, watches, b.silenceWarning, now) {
+
+		w := watches[s.ID]
+		w.ID = s.ID
+		for _, chat := range b.warningChats(w) {
+			b.sendSilenceWarning(chat, s, now)
+		}
+
+		w.Warned = s.EndsAt
+		watches[s.ID] = w
+		if err := b.silenceStore.Set(w); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to save silence to store", "err", err)
+		}
+	}
+
+	active := map[string]bool{}
+	for _, s := range silences {
+		if s.Status.State != vendor.SilenceStateExpired {
+			active[s.ID] = true
+		}
+	}
+	for id := range watches {
+		if active[id] {
+			continue
+		}
+		if err := b.silenceStore.Remove(id); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove silence from store", "err", err)
+		}
+	}
+}
+
+// sendSilenceWarning sends the silence about to expire with the buttons to extend it
+func (b *Bot) sendSilenceWarning(chat telebot.Chat, s vendor.Silence, now time.Time) {
+
+	p := b.printer(&chat, nil)
+
+	var extend []telebot.InlineButton
+	for _, d := range silenceExtensions {
+		// Callback data is limited to 64 bytes, 24h is shorter than 24h0m0s
+		short := strings.TrimSuffix(d.String(), "0m0s")
+		button := *silenceExtendButton.With(s.ID + " " + short)
+		button.Text = p.Sprintf("buttonSilenceExtend", short)
+		extend = append(extend, button)
+	}
+	expire := *silenceExpireButton.With(s.ID)
+	expire.Text = p.Sprintf("buttonSilenceExpire")
+
+	text := p.Sprintf("responseSilenceExpiring", s.EndsAt.Sub(now).Round(time.Minute).String()) + "\n" + alertmanager.SilenceMessage(s)
+
+	_, err := b.telegram.Send(&chat, text, &telebot.SendOptions{
+		ParseMode:   telebot.ModeMarkdown,
+		ReplyMarkup: &telebot.ReplyMarkup{InlineKeyboard: [][]telebot.InlineButton{extend, {expire}}},
+	})
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to send silence expiry warning", "chat", chat.ID, "err", err)
+	}
+}
+
+// Extend the silence of the pressed button
+func (b *Bot) handleSilenceExtendCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	// Extending silences for any duration like /silence_for
+	if !b.hasRole(c.Sender.ID, b.silenceRole) {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceRole", b.silenceRole, commandSilenceFor), ShowAlert: true})
+		return
+	}
+
+	args := strings.Fields(c.Data)
+	if len(args) != 2 {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceExtendFail"), ShowAlert: true})
+		return
+	}
+	duration, err := time.ParseDuration(args[1])
+	if err != nil {
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceExtendFail"), ShowAlert: true})
+		return
+	}
+
+	s, err := b.extendSilence(args[0], duration, c.Message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to extend silence", "err", err)
+		b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceExtendFail"), ShowAlert: true})
+		return
+	}
+
+	level.Info(b.logger).Log(
+		"msg", "user extended silence",
+		"username", c.Sender.Username,
+		"user_id", c.Sender.ID,
+		"silence", s.ID,
+		"duration", duration,
+	)
+
+	until := s.EndsAt.In(b.location(c.Message.Chat)).Format("2006-01-02 15:04 MST")
+	b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceExtended", until)})
+
+	// The warning shows the extended silence without the buttons
+	text := p.Sprintf("responseSilenceExtended", until) + "\n" + alertmanager.SilenceMessage(s)
+	if _, err := b.telegram.Edit(c.Message, text, &telebot.SendOptions{ParseMode: telebot.ModeMarkdown}); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit silence expiry warning", "err", err)
+	}
+}
+
+// Let the silence of the pressed button expire
+func (b *Bot) handleSilenceExpireCallback(c *telebot.Callback) {
+
+	p := b.printer(c.Message.Chat, c.Sender)
+
+	if !b.isAdminID(c.Sender.ID) {
+		b.commandsCounter.WithLabelValues("dropped").Inc()
+		b.telegram.Respond(c, &telebot.CallbackResponse{
+			Text:      p.Sprintf("responseNonAdmin", c.Sender.Username, c.Sender.FirstName, c.Sender.LastName),
+			ShowAlert: true,
+		})
+		return
+	}
+
+	b.telegram.Respond(c, &telebot.CallbackResponse{Text: p.Sprintf("responseSilenceExpire")})
+	if _, err := b.telegram.EditReplyMarkup(c.Message, nil); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to edit silence expiry warning", "err", err)
+	}
+}
+
+// findSilence returns the silence of the ID from Alertmanager
+func (b *Bot) findSilence(id string) (vendor.Silence, error) {
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		return vendor.Silence{}, err
+	}
+
+	for _, s := range silences {
+		if s.ID == id {
+			return s, nil
+		}
+	}
+
+	return vendor.Silence{}, errSilenceNotFound
+}
+
+// extendSilence extends the silence by the duration, a silence that already expired is
+// created again from now. The silence is watched for the chat afterwards.
+func (b *Bot) extendSilence(id string, duration time.Duration, chat *telebot.Chat) (vendor.Silence, error) {
+
+	s, err := b.findSilence(id)
+	if err != nil {
+		return vendor.Silence{}, err
+	}
+
+	now := time.Now()
+	if s.EndsAt.After(now) {
+		s.EndsAt = s.EndsAt.Add(duration)
+	} else {
+		s.ID, s.StartsAt, s.EndsAt = "", now, now.Add(duration)
+	}
+	s.UpdatedAt = now
+	s.Status = vendor.SilenceStatus{State: vendor.CalcSilenceState(s.StartsAt, s.EndsAt)}
+
+	created, err := alertmanager.PostSilence(b.logger, b.alertmanager.String(), s)
+	if err != nil {
+		return vendor.Silence{}, err
+	}
+	if created != "" {
+		s.ID = created
+	}
+	b.watchSilence(s.ID, chat)
+
+	return s, nil
+}
+
+// diffSilences returns the events between the silences seen last time and the ones polled now
+func diffSilences(seen map[string]vendor.Silence, silences []vendor.Silence) []SilenceEvent {
+
+	var events []SilenceEvent
+	polled := map[string]bool{}
+
+	for _, s := range silences {
+
+		polled[s.ID] = true
+		old, ok := seen[s.ID]
+		expired := s.Status.State == vendor.SilenceStateExpired
+
+		switch {
+		case !ok && !expired:
+			events = append(events, SilenceEvent{Kind: silenceCreated, Silence: s})
+		case ok && expired:
+			events = append(events, SilenceEvent{Kind: silenceExpired, Silence: s})
+		case ok && (!old.UpdatedAt.Equal(s.UpdatedAt) || !old.EndsAt.Equal(s.EndsAt) || old.Comment != s.Comment):
+			events = append(events, SilenceEvent{Kind: silenceUpdated, Silence: s})
+		}
+	}
+
+	// Silences disappear once Alertmanager garbage collects them
+	var gone []string
+	for id := range seen {
+		if !polled[id] {
+			gone = append(gone, id)
+		}
+	}
+	sort.Strings(gone)
+	for _, id := range gone {
+		events = append(events, SilenceEvent{Kind: silenceExpired, Silence: seen[id]})
+	}
+
+	return events
+}
+
+// pollSilences posts the silences created, updated or expired outside the bot
+// to the chats that opted in
+func (b *Bot) pollSilences() {
+
+	if b.silenceStore == nil {
+		return
+	}
+
+	chats, err := b.chatStore.List()
+	if err != nil {
+		level.Error(b.logger).Log("msg", "failed to get chat list from store", "err", err)
+		return
+	}
+
+	var subscribed []telebot.Chat
+	for _, chat := range chats {
+		settings, err := b.chatStore.Settings(chat)
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+			continue
+		}
+		if settings.SilenceEvents {
+			subscribed = append(subscribed, chat)
+		}
+	}
+
+	// Nobody is interested, the next chat opting in starts from the silences then
+	if len(subscribed) == 0 {
+		if err := b.silenceStore.SetSeen(nil); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove seen silences from store", "err", err)
+		}
+		return
+	}
+
+	silences, err := alertmanager.ListSilences(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get silences, postponing silence events", "err", err)
+		return
+	}
+
+	seen, err := b.silenceStore.Seen()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get seen silences from store", "err", err)
+		return
+	}
+
+	// The first poll only remembers the silences as they are
+	if seen != nil {
+		var events []SilenceEvent
+		for _, e := range diffSilences(seen, silences) {
+			if e.Silence.CreatedBy != silenceCreatedBy {
+				events = append(events, e)
+			}
+		}
+		if len(events) > 0 {
+			b.sendSilenceEvents(subscribed, events)
+		}
+	}
+
+	polled := map[string]vendor.Silence{}
+	for _, s := range silences {
+		if s.Status.State != vendor.SilenceStateExpired {
+			polled[s.ID] = s
+		}
+	}
+	if err := b.silenceStore.SetSeen(polled); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save seen silences to store", "err", err)
+	}
+}
+
+// sendSilenceEvents posts the events with the number of firing alerts each silence affects
+func (b *Bot) sendSilenceEvents(chats []telebot.Chat, events []SilenceEvent) {
+
+	alerts, err := alertmanager.ListAlerts(b.logger, b.alertmanager.String())
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get alerts, silence events are sent without their count", "err", err)
+	}
+
+	for _, e := range events {
+
+		affected := 0
+		for _, alert := range alerts {
+			if !alert.Resolved() && e.Silence.Matchers.Matches(alert.Labels) {
+				affected++
+			}
+		}
+
+		var matchers []string
+		for _, m := range e.Silence.Matchers {
+			matchers = append(matchers, m.String())
+		}
+
+		for _, chat := range chats {
+			p := b.printer(&chat, nil)
+			text := p.Sprintf(silenceEventResponses[e.Kind], e.Silence.CreatedBy) + "\n" + p.Sprintf(
+				"responseSilenceEventDetails",
+				strings.Join(matchers, ", "),
+				e.Silence.Comment,
+				e.Silence.EndsAt.In(b.location(&chat)).Format("2006-01-02 15:04 MST"),
+				affected,
+			)
+			if _, err := b.telegram.Send(&chat, text); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to send silence event", "chat", chat.ID, "err", err)
+			}
+		}
+	}
+}
+
+// Show or change whether this chat is told about the silences changed outside the bot
+func (b *Bot) handleSilenceEvents(message *telebot.Message) {
+
+	p := b.printer(message.Chat, message.Sender)
+
+	if b.silenceStore == nil {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsFail"))
+		return
+	}
+
+	settings, err := b.chatStore.Settings(*message.Chat)
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to get chat settings from store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsFail"))
+		return
+	}
+
+	args := strings.Fields(message.Text)[1:]
+	if len(args) == 0 {
+		current := "off"
+		if settings.SilenceEvents {
+			current = "on"
+		}
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEvents", current, commandSilenceEvents))
+		return
+	}
+
+	switch args[0] {
+	case "on":
+		settings.SilenceEvents = true
+	case "off":
+		settings.SilenceEvents = false
+	default:
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsInvalid", args[0], commandSilenceEvents))
+		return
+	}
+
+	if err := b.chatStore.SetSettings(*message.Chat, settings); err != nil {
+		level.Warn(b.logger).Log("msg", "failed to save chat settings to store", "err", err)
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsFail"))
+		return
+	}
+
+	if settings.SilenceEvents {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsOn"))
+	} else {
+		b.telegram.Reply(message, p.Sprintf("responseSilenceEventsOff"))
+	}
+	level.Info(b.logger).Log(
+		"msg", "user changed chat silence events",
+		"username", message.Sender.Username,
+		"user_id", message.Sender.ID,
+		"silence_events", settings.SilenceEvents,
+	)
+
+}
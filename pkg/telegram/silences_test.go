@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/docker/libkv/store"
+	"github.com/docker/libkv/store/boltdb"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestExpiringSilences(t *testing.T) {
+
+	now := time.Now()
+	active := vendor.SilenceStatus{State: vendor.SilenceStateActive}
+	silences := []vendor.Silence{
+		{ID: "soon", EndsAt: now.Add(10 * time.Minute), Status: active},
+		{ID: "later", EndsAt: now.Add(time.Hour), Status: active},
+		{ID: "warned", EndsAt: now.Add(5 * time.Minute), Status: active},
+		{ID: "expired", EndsAt: now.Add(-time.Minute), Status: vendor.SilenceStatus{State: vendor.SilenceStateExpired}},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: only the silences ending within the warning time not warned about yet
+	// ---------------------------------------------------------------------------
+	watches := map[string]SilenceWatch{"warned": {ID: "warned", Warned: now.Add(5 * time.Minute)}}
+	expiring := expiringSilences(silences, watches, 15*time.Minute, now)
+	assert.Len(t, expiring, 1)
+	assert.Equal(t, "soon", expiring[0].ID)
+	t.Log("expiringSilences() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an extended silence is warned about again
+	// ---------------------------------------------------------------------------
+	silences[2].EndsAt = now.Add(12 * time.Minute)
+	expiring = expiringSilences(silences, watches, 15*time.Minute, now)
+	assert.Len(t, expiring, 2)
+	t.Log("expiringSilences() : Test 2 PASSED.")
+
+}
+
+func TestWarningChats(t *testing.T) {
+
+	bot := &Bot{admins: []int{1, 2}}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the chat the silence was created from
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []telebot.Chat{{ID: -100}}, bot.warningChats(SilenceWatch{ID: "a", ChatID: -100}))
+	t.Log("warningChats() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: every admin for the silences created outside the bot
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []telebot.Chat{{ID: 1, Type: telebot.ChatPrivate}, {ID: 2, Type: telebot.ChatPrivate}}, bot.warningChats(SilenceWatch{ID: "a"}))
+	t.Log("warningChats() : Test 2 PASSED.")
+
+}
+
+func TestExtendSilence(t *testing.T) {
+
+	silences, err := ioutil.ReadFile("../test/silences.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/silences", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(silences)
+	})
+	mux.HandleFunc("/api/v2/silences", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"silenceID":"a5e1a0f4-6f0c-4b5e-9a1e-0c1f3b0a7d21"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	alertmanagerURL, _ := url.Parse(server.URL)
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "silences"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, _ := NewSilenceStore(kvStore)
+	bot := &Bot{logger: log.NewNopLogger(), alertmanager: alertmanagerURL, silenceStore: s}
+	chat := &telebot.Chat{ID: 1036}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an expired silence is created again from now and watched for the chat
+	// ---------------------------------------------------------------------------
+	silence, err := bot.extendSilence("acf620d5-0239-4f7b-ab83-249b4da88d43", 4*time.Hour, chat)
+	assert.NoError(t, err)
+	assert.Equal(t, "a5e1a0f4-6f0c-4b5e-9a1e-0c1f3b0a7d21", silence.ID)
+	assert.WithinDuration(t, time.Now().Add(4*time.Hour), silence.EndsAt, time.Minute)
+	w, err := s.Get(silence.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, &SilenceWatch{ID: silence.ID, ChatID: chat.ID}, w)
+	t.Log("extendSilence() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: unknown silence
+	// ---------------------------------------------------------------------------
+	_, err = bot.extendSilence("unknown", time.Hour, chat)
+	assert.Equal(t, errSilenceNotFound, err)
+	t.Log("extendSilence() : Test 2 PASSED.")
+
+	assert.NoError(t, s.Remove(silence.ID))
+
+}
+
+func TestDiffSilences(t *testing.T) {
+
+	now := time.Now()
+	active := vendor.SilenceStatus{State: vendor.SilenceStateActive}
+	expired := vendor.SilenceStatus{State: vendor.SilenceStateExpired}
+
+	seen := map[string]vendor.Silence{
+		"same":    {ID: "same", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+		"updated": {ID: "updated", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+		"expired": {ID: "expired", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+		"gone":    {ID: "gone", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+	}
+	silences := []vendor.Silence{
+		{ID: "same", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+		{ID: "updated", UpdatedAt: now.Add(time.Minute), EndsAt: now.Add(2 * time.Hour), Status: active},
+		{ID: "expired", UpdatedAt: now.Add(time.Minute), EndsAt: now.Add(time.Minute), Status: expired},
+		{ID: "new", UpdatedAt: now, EndsAt: now.Add(time.Hour), Status: active},
+		{ID: "old", UpdatedAt: now, EndsAt: now.Add(-time.Hour), Status: expired},
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: created, updated and expired silences
+	// ---------------------------------------------------------------------------
+	var kinds []string
+	for _, e := range diffSilences(seen, silences) {
+		kinds = append(kinds, e.Kind+" "+e.Silence.ID)
+	}
+	assert.Equal(t, []string{"updated updated", "expired expired", "created new", "expired gone"}, kinds)
+	t.Log("diffSilences() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing changed
+	// ---------------------------------------------------------------------------
+	assert.Empty(t, diffSilences(seen, []vendor.Silence{seen["same"], seen["updated"], seen["expired"], seen["gone"]}))
+	t.Log("diffSilences() : Test 2 PASSED.")
+
+}
+
+func TestSeenSilences(t *testing.T) {
+
+	kvStore, err := boltdb.New([]string{"../test/kv.boltdb"}, &store.Config{Bucket: "seen_silences"})
+	if err != nil {
+		t.Fatalf("boltdb.New() : Test 1 FAILED, got error: %s", err)
+	}
+	defer kvStore.Close()
+
+	s, _ := NewSilenceStore(kvStore)
+	assert.NoError(t, s.SetSeen(nil))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: nothing seen yet
+	// ---------------------------------------------------------------------------
+	seen, err := s.Seen()
+	assert.NoError(t, err)
+	assert.Nil(t, seen)
+	t.Log("SilenceStore.Seen() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the silences seen are read back with their matchers
+	// ---------------------------------------------------------------------------
+	m, _ := vendor.NewMatcher(vendor.MatchRegexp, "alertname", "Node.*")
+	silence := vendor.Silence{ID: "a", Matchers: vendor.Matchers{m}, CreatedBy: "alice"}
+	assert.NoError(t, s.SetSeen(map[string]vendor.Silence{"a": silence}))
+	seen, err = s.Seen()
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", seen["a"].CreatedBy)
+	assert.True(t, seen["a"].Matchers.Matches(labelSet(vendor.KV{"alertname": "NodeDown"})))
+	t.Log("SilenceStore.Seen() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an empty set is not the same as nothing seen
+	// ---------------------------------------------------------------------------
+	assert.NoError(t, s.SetSeen(map[string]vendor.Silence{}))
+	seen, err = s.Seen()
+	assert.NoError(t, err)
+	assert.NotNil(t, seen)
+	assert.NoError(t, s.SetSeen(nil))
+	t.Log("SilenceStore.Seen() : Test 3 PASSED.")
+
+}
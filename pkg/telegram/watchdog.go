@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/alertmanager"
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	telebot "gopkg.in/tucnak/telebot.v2"
+)
+
+// heartbeats records when a heartbeat alert was last received and returns the other alerts,
+// heartbeats are neither sent to the chats nor recorded
+func (b *Bot) heartbeats(alerts vendor.Alerts, now time.Time) vendor.Alerts {
+
+	if len(b.heartbeatMatchers) == 0 {
+		return alerts
+	}
+
+	var rest vendor.Alerts
+	for _, alert := range alerts {
+		if !b.heartbeatMatchers.Matches(labelSet(alert.Labels)) {
+			rest = append(rest, alert)
+			continue
+		}
+		if alert.Status == string(model.AlertFiring) {
+			b.heartbeat = now
+		}
+	}
+
+	return rest
+}
+
+// checkHeartbeat returns whether the heartbeat alert is missing and whether that changed since the last check
+func (b *Bot) checkHeartbeat(now time.Time) (missing bool, changed bool) {
+
+	if len(b.heartbeatMatchers) == 0 || b.heartbeatInterval <= 0 {
+		return false, false
+	}
+
+	// The heartbeat is given an interval to arrive after the bot started
+	if b.heartbeat.IsZero() {
+		b.heartbeat = now
+	}
+
+	missing = now.Sub(b.heartbeat) > b.heartbeatInterval
+	changed = missing != b.heartbeatMissing
+	b.heartbeatMissing = missing
+
+	return missing, changed
+}
+
+// probeAlertmanager probes the status of Alertmanager once per probe interval, it returns
+// whether its reachability changed since the last probe and the error if it is unreachable
+func (b *Bot) probeAlertmanager(now time.Time) (changed bool, err error) {
+
+	if b.probeInterval <= 0 || now.Sub(b.probed) < b.probeInterval {
+		return false, nil
+	}
+	b.probed = now
+
+	err = alertmanager.Probe(b.logger, b.alertmanager.String())
+
+	switch {
+	case err != nil && b.unreachable.IsZero():
+		b.unreachable = now
+		return true, err
+	case err == nil && !b.unreachable.IsZero():
+		b.unreachable = time.Time{}
+		return true, nil
+	}
+
+	return false, err
+}
+
+// watch probes Alertmanager and checks the heartbeat alert, the admins are alarmed
+// when a check fails and told when it recovers
+func (b *Bot) watch(now time.Time) {
+
+	unreachable := b.unreachable
+	if changed, err := b.probeAlertmanager(now); changed {
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "alertmanager is unreachable", "err", err)
+			b.alarmAdmins("responseWatchdogDown", b.alertmanager.String(), err.Error())
+		} else {
+			level.Info(b.logger).Log("msg", "alertmanager is reachable again")
+			b.alarmAdmins("responseWatchdogUp", b.alertmanager.String(), now.Sub(unreachable).Round(time.Second).String())
+		}
+	}
+
+	if missing, changed := b.checkHeartbeat(now); changed {
+		since := now.Sub(b.heartbeat).Round(time.Second).String()
+		if missing {
+			level.Warn(b.logger).Log("msg", "heartbeat alert is missing", "last", b.heartbeat)
+			b.alarmAdmins("responseWatchdogHeartbeatMissing", b.heartbeatMatchers.String(), since)
+		} else {
+			level.Info(b.logger).Log("msg", "heartbeat alert is received again")
+			b.alarmAdmins("responseWatchdogHeartbeatBack", b.heartbeatMatchers.String())
+		}
+	}
+}
+
+// alarmAdmins sends the translated message to every admin
+func (b *Bot) alarmAdmins(key string, args ...interface{}) {
+	for _, id := range b.admins {
+		p := b.printer(&telebot.Chat{ID: int64(id)}, nil)
+		if _, err := b.telegram.Send(&telebot.User{ID: id}, p.Sprintf(key, args...)); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to send watchdog message to admin", "admin", id, "err", err)
+		}
+	}
+}
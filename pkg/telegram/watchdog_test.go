@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/NobleD5/alertmanager-bot/pkg/vendor"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestHeartbeat(t *testing.T) {
+
+	matchers, _ := vendor.ParseMatchers(`alertname="Watchdog"`)
+	bot := &Bot{logger: log.NewNopLogger(), heartbeatMatchers: matchers, heartbeatInterval: 10 * time.Minute}
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	watchdog := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "Watchdog"}}
+	other := vendor.Alert{Status: "firing", Labels: vendor.KV{"alertname": "HighLoad"}}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the bot waits an interval for the first heartbeat after starting
+	// ---------------------------------------------------------------------------
+	missing, changed := bot.checkHeartbeat(now)
+	assert.False(t, missing)
+	assert.False(t, changed)
+	t.Log("checkHeartbeat() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: heartbeats are recorded and kept from the other alerts
+	// ---------------------------------------------------------------------------
+	alerts := bot.heartbeats(vendor.Alerts{watchdog, other}, now.Add(5*time.Minute))
+	assert.Equal(t, vendor.Alerts{other}, alerts)
+	assert.Equal(t, now.Add(5*time.Minute), bot.heartbeat)
+	t.Log("heartbeats() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the heartbeat goes missing once, then comes back
+	// ---------------------------------------------------------------------------
+	missing, changed = bot.checkHeartbeat(now.Add(16 * time.Minute))
+	assert.True(t, missing)
+	assert.True(t, changed)
+	missing, changed = bot.checkHeartbeat(now.Add(20 * time.Minute))
+	assert.True(t, missing)
+	assert.False(t, changed)
+	bot.heartbeats(vendor.Alerts{watchdog}, now.Add(21*time.Minute))
+	missing, changed = bot.checkHeartbeat(now.Add(21 * time.Minute))
+	assert.False(t, missing)
+	assert.True(t, changed)
+	t.Log("checkHeartbeat() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a resolved heartbeat doesn't count
+	// ---------------------------------------------------------------------------
+	resolved := watchdog
+	resolved.Status = "resolved"
+	alerts = bot.heartbeats(vendor.Alerts{resolved}, now.Add(30*time.Minute))
+	assert.Empty(t, alerts)
+	assert.Equal(t, now.Add(21*time.Minute), bot.heartbeat)
+	t.Log("heartbeats() : Test 4 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: without matchers every alert is kept and nothing is checked
+	// ---------------------------------------------------------------------------
+	disabled := &Bot{logger: log.NewNopLogger()}
+	assert.Equal(t, vendor.Alerts{watchdog}, disabled.heartbeats(vendor.Alerts{watchdog}, now))
+	missing, changed = disabled.checkHeartbeat(now.Add(time.Hour))
+	assert.False(t, missing)
+	assert.False(t, changed)
+	t.Log("heartbeats() : Test 5 PASSED.")
+
+}
+
+func TestProbeAlertmanager(t *testing.T) {
+
+	up := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !up {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"uptime":"2021-03-10T12:00:00Z"}}`))
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	bot := &Bot{logger: log.NewNopLogger(), alertmanager: u, probeInterval: time.Minute}
+	now := time.Date(2021, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: a reachable Alertmanager changes nothing
+	// ---------------------------------------------------------------------------
+	changed, err := bot.probeAlertmanager(now)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	t.Log("probeAlertmanager() : Test 1 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: probes are skipped within the interval
+	// ---------------------------------------------------------------------------
+	up = false
+	changed, err = bot.probeAlertmanager(now.Add(30 * time.Second))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	t.Log("probeAlertmanager() : Test 2 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: an unreachable Alertmanager is reported once
+	// ---------------------------------------------------------------------------
+	changed, err = bot.probeAlertmanager(now.Add(time.Minute))
+	assert.Error(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, now.Add(time.Minute), bot.unreachable)
+	changed, err = bot.probeAlertmanager(now.Add(2 * time.Minute))
+	assert.Error(t, err)
+	assert.False(t, changed)
+	t.Log("probeAlertmanager() : Test 3 PASSED.")
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the recovery is reported
+	// ---------------------------------------------------------------------------
+	up = true
+	changed, err = bot.probeAlertmanager(now.Add(3 * time.Minute))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, bot.unreachable.IsZero())
+	t.Log("probeAlertmanager() : Test 4 PASSED.")
+
+}
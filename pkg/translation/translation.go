@@ -5,31 +5,223 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/text/feature/plural"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 	"golang.org/x/text/message/catalog"
 	"gopkg.in/yaml.v2"
 )
 
-type dictionary struct {
-	Data map[string]string
+// pluralForms are the CLDR plural categories in the order they are tried
+var pluralForms = []string{"zero", "one", "two", "few", "many", "other"}
+
+// Message is a single translated message, either a plain string or its plural
+// forms (zero, one, two, few, many, other, =N or <N) selected by the first argument
+type Message struct {
+	Text   string
+	Plural map[string]string
+}
+
+// UnmarshalYAML accepts both a plain string and a map of plural forms
+func (m *Message) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&m.Text); err == nil {
+		return nil
+	}
+	return unmarshal(&m.Plural)
+}
+
+// Dictionary holds all messages of a single language by key
+type Dictionary map[string]Message
+
+// Catalog holds the messages of all languages and resolves missing keys
+// through the fallback chain
+type Catalog struct {
+	builder   *catalog.Builder
+	keys      map[language.Tag]map[string]bool
+	languages []language.Tag
+	fallbacks []language.Tag
+	matcher   language.Matcher
+	logger    log.Logger
+	missing   *prometheus.CounterVec
+}
+
+// CatalogOption passed to NewCatalog to change the default instance
+type CatalogOption func(c *Catalog)
+
+// WithFallbacks sets the chain of languages tried in order when a key is missing
+func WithFallbacks(tags ...language.Tag) CatalogOption {
+	return func(c *Catalog) {
+		c.fallbacks = tags
+	}
+}
+
+// WithLogger sets the logger used to report missing keys
+func WithLogger(l log.Logger) CatalogOption {
+	return func(c *Catalog) {
+		c.logger = l
+	}
+}
+
+// WithMissingCounter sets the counter of missing keys, it must have a "language" label
+func WithMissingCounter(counter *prometheus.CounterVec) CatalogOption {
+	return func(c *Catalog) {
+		c.missing = counter
+	}
+}
+
+// NewCatalog compiles the given dictionaries keyed by language into a Catalog
+func NewCatalog(dicts map[string]Dictionary, opts ...CatalogOption) (*Catalog, error) {
+
+	c := &Catalog{
+		builder:   catalog.NewBuilder(),
+		keys:      map[language.Tag]map[string]bool{},
+		fallbacks: []language.Tag{language.English},
+		logger:    log.NewNopLogger(),
+		missing: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "translation_missing_total",
+		}, []string{"language"}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	for lang, dict := range dicts {
+		tag, err := language.Parse(lang)
+		if err != nil {
+			return nil, fmt.Errorf("invalid language %q: %s", lang, err.Error())
+		}
+
+		c.keys[tag] = map[string]bool{}
+		for key, msg := range dict {
+			if err := c.set(tag, key, msg); err != nil {
+				return nil, fmt.Errorf("err compiling message %q for language %q: %s", key, lang, err.Error())
+			}
+			c.keys[tag][key] = true
+		}
+	}
+
+	// The first fallback having a dictionary goes first, so it is matched by default
+	for _, tag := range c.fallbacks {
+		if _, ok := c.keys[tag]; ok {
+			c.languages = append(c.languages, tag)
+			break
+		}
+	}
+	var others []language.Tag
+	for tag := range c.keys {
+		if len(c.languages) == 0 || tag != c.languages[0] {
+			others = append(others, tag)
+		}
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i].String() < others[j].String() })
+	c.languages = append(c.languages, others...)
+
+	if len(c.languages) > 0 {
+		c.matcher = language.NewMatcher(c.languages)
+	}
+
+	return c, nil
+}
+
+// set compiles a message into the builder
+func (c *Catalog) set(tag language.Tag, key string, msg Message) error {
+
+	if msg.Plural == nil {
+		return c.builder.SetString(tag, key, msg.Text)
+	}
+
+	// Exact matches (=N, <N) have to be tried before the CLDR categories, "other" last
+	var cases []interface{}
+	for selector, text := range msg.Plural {
+		if strings.HasPrefix(selector, "=") || strings.HasPrefix(selector, "<") {
+			cases = append(cases, selector, text)
+		}
+	}
+	for _, form := range pluralForms {
+		if text, ok := msg.Plural[form]; ok {
+			cases = append(cases, form, text)
+		}
+	}
+
+	return c.builder.Set(tag, key, plural.Selectf(1, "%d", cases...))
 }
 
-func (d *dictionary) Lookup(key string) (data string, ok bool) {
+// Languages returns all languages having a dictionary, the default one first
+func (c *Catalog) Languages() []language.Tag {
+	return c.languages
+}
+
+// Printer returns a Printer for the best match of the preferred languages,
+// the default language if none of them is supported
+func (c *Catalog) Printer(preferred ...language.Tag) *Printer {
+
+	if c.matcher == nil {
+		tag := language.English
+		if len(c.fallbacks) > 0 {
+			tag = c.fallbacks[0]
+		}
+		return &Printer{catalog: c, tag: tag}
+	}
+
+	_, index, confidence := c.matcher.Match(preferred...)
+	if confidence == language.No {
+		index = 0
+	}
+
+	return &Printer{catalog: c, tag: c.languages[index]}
+}
+
+// chain returns the languages to try in order for the given one
+func (c *Catalog) chain(tag language.Tag) []language.Tag {
+
+	chain := []language.Tag{tag}
+	for i, fallback := range c.fallbacks {
+		if fallback == tag {
+			return append(chain, c.fallbacks[i+1:]...)
+		}
+	}
+
+	return append(chain, c.fallbacks...)
+}
+
+// Printer formats messages in a single language
+type Printer struct {
+	catalog *Catalog
+	tag     language.Tag
+}
+
+// Language returns the language of the printer
+func (p *Printer) Language() language.Tag {
+	return p.tag
+}
+
+// Sprintf formats the message of the given key, falling back through the chain
+// when the key is missing. The key itself is returned if no language has it.
+func (p *Printer) Sprintf(key string, a ...interface{}) string {
+
+	for _, tag := range p.catalog.chain(p.tag) {
+		if p.catalog.keys[tag][key] {
+			return message.NewPrinter(tag, message.Catalog(p.catalog.builder)).Sprintf(key, a...)
+		}
 
-	if value, ok := d.Data[key]; ok {
-		return "\x02" + value, true
+		p.catalog.missing.WithLabelValues(tag.String()).Inc()
+		level.Warn(p.catalog.logger).Log("msg", "missing translation", "language", tag, "key", key)
 	}
 
-	return "\x02" + d.Data[key], true
+	return key
 }
 
 // ParseYAMLDict is parsing available dicts
-func ParseYAMLDict(dirname string, logger log.Logger) (map[string]catalog.Dictionary, error) {
+func ParseYAMLDict(dirname string, logger log.Logger) (map[string]Dictionary, error) {
 
-	translations := map[string]catalog.Dictionary{}
+	translations := map[string]Dictionary{}
 
 	fi, error := os.Stat(dirname)
 	if error != nil {
@@ -61,14 +253,14 @@ func ParseYAMLDict(dirname string, logger log.Logger) (map[string]catalog.Dictio
 				return nil, fmt.Errorf("err reading given filename (%s): %s", yamlFile, err.Error())
 			}
 
-			data := map[string]string{}
+			data := Dictionary{}
 			err = yaml.Unmarshal(yamlFile, &data)
 			if err != nil {
 				return nil, fmt.Errorf("err unmarshaling given yaml input: %s", err.Error())
 			}
 
 			lang := strings.Split(file.Name(), ".")[0]
-			translations[lang] = &dictionary{Data: data}
+			translations[lang] = data
 		}
 
 	case mode.IsRegular():
@@ -78,7 +270,7 @@ func ParseYAMLDict(dirname string, logger log.Logger) (map[string]catalog.Dictio
 			return nil, fmt.Errorf("err reading given filename (%s): %s", yamlFile, err.Error())
 		}
 
-		data := map[string]string{}
+		data := Dictionary{}
 		err = yaml.Unmarshal(yamlFile, &data)
 		if err != nil {
 			return nil, fmt.Errorf("err unmarshaling given yaml input: %s", err.Error())
@@ -86,7 +278,7 @@ func ParseYAMLDict(dirname string, logger log.Logger) (map[string]catalog.Dictio
 
 		_, file := filepath.Split(dirname)
 		lang := strings.Split(file, ".")[0]
-		translations[lang] = &dictionary{Data: data}
+		translations[lang] = data
 	}
 
 	return translations, nil
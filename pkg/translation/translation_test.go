@@ -0,0 +1,116 @@
+package translation
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+)
+
+////////////////////////////////////////////////////////////////////////////////
+// TESTING
+////////////////////////////////////////////////////////////////////////////////
+
+func TestParseYAMLDict(t *testing.T) {
+
+	logger := log.NewNopLogger()
+
+	dict, err := ParseYAMLDict("../../ru.yaml", logger)
+	if err != nil {
+		t.Fatalf("ParseYAMLDict() : Test 1 FAILED, got error: %s", err)
+	}
+	assert.NotEmpty(t, dict["ru"]["responseStart"].Text)
+	assert.Len(t, dict["ru"]["responseSilenceAllCreated"].Plural, 4)
+
+	_, err = ParseYAMLDict("../../nonexistent.yaml", logger)
+	assert.Error(t, err)
+}
+
+func TestCatalog(t *testing.T) {
+
+	missing := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "missing"}, []string{"language"})
+
+	dicts := map[string]Dictionary{
+		"en": {
+			"hello": {Text: "Hello, %s!"},
+			"bye":   {Text: "Bye!"},
+			"hours": {Plural: map[string]string{"one": "%d hour", "other": "%d hours", "=0": "no hours"}},
+		},
+		"ru": {
+			"hello": {Text: "Привет, %s!"},
+			"hours": {Plural: map[string]string{"one": "%d час", "few": "%d часа", "many": "%d часов", "other": "%d часа"}},
+		},
+		"uk": {},
+	}
+
+	cat, err := NewCatalog(
+		dicts,
+		WithFallbacks(language.Russian, language.English),
+		WithMissingCounter(missing),
+	)
+	if err != nil {
+		t.Fatalf("NewCatalog() : Test 1 FAILED, got error: %s", err)
+	}
+
+	// ---------------------------------------------------------------------------
+	//  CASE: the first fallback is the default language
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, []language.Tag{language.Russian, language.English, language.Ukrainian}, cat.Languages())
+	assert.Equal(t, language.Russian, cat.Printer().Language())
+	assert.Equal(t, language.Russian, cat.Printer(language.German).Language())
+	assert.Equal(t, language.English, cat.Printer(language.MustParse("en-GB")).Language())
+
+	// ---------------------------------------------------------------------------
+	//  CASE: keys found in the language itself
+	// ---------------------------------------------------------------------------
+	en := cat.Printer(language.English)
+	assert.Equal(t, "Hello, Bob!", en.Sprintf("hello", "Bob"))
+	assert.Equal(t, "1 hour", en.Sprintf("hours", 1))
+	assert.Equal(t, "5 hours", en.Sprintf("hours", 5))
+	assert.Equal(t, "no hours", en.Sprintf("hours", 0))
+
+	ru := cat.Printer(language.Russian)
+	assert.Equal(t, "Привет, Bob!", ru.Sprintf("hello", "Bob"))
+	assert.Equal(t, "1 час", ru.Sprintf("hours", 1))
+	assert.Equal(t, "3 часа", ru.Sprintf("hours", 3))
+	assert.Equal(t, "8 часов", ru.Sprintf("hours", 8))
+	assert.Equal(t, 0.0, testutil.ToFloat64(missing.WithLabelValues("ru")))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: keys missing fall back through the chain and are counted
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "Bye!", ru.Sprintf("bye"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(missing.WithLabelValues("ru")))
+
+	uk := cat.Printer(language.Ukrainian)
+	assert.Equal(t, "Привет, Bob!", uk.Sprintf("hello", "Bob"))
+	assert.Equal(t, "Bye!", uk.Sprintf("bye"))
+	assert.Equal(t, 2.0, testutil.ToFloat64(missing.WithLabelValues("uk")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(missing.WithLabelValues("ru")))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: keys missing everywhere are printed as is
+	// ---------------------------------------------------------------------------
+	assert.Equal(t, "unknown", en.Sprintf("unknown"))
+	assert.Equal(t, 1.0, testutil.ToFloat64(missing.WithLabelValues("en")))
+
+	// ---------------------------------------------------------------------------
+	//  CASE: plural forms not existing in a language are rejected
+	// ---------------------------------------------------------------------------
+	_, err = NewCatalog(map[string]Dictionary{
+		"en": {"hours": {Plural: map[string]string{"few": "%d hours"}}},
+	})
+	assert.Error(t, err)
+
+	// ---------------------------------------------------------------------------
+	//  CASE: empty catalog
+	// ---------------------------------------------------------------------------
+	empty, err := NewCatalog(nil)
+	if err != nil {
+		t.Fatalf("NewCatalog() : Test 2 FAILED, got error: %s", err)
+	}
+	assert.Equal(t, "hello", empty.Printer(language.Russian).Sprintf("hello"))
+}